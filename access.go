@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/goFlipMouse/vdev"
+)
+
+// udevRulesPath is where -install-udev-rules writes udevRules
+const udevRulesPath = "/etc/udev/rules.d/70-goflipmouse.rules"
+
+// udevRules give the input group the device access the daemon needs, so it
+// can run as an ordinary user in that group
+const udevRules = `# goFlipMouse: let the input group create virtual devices and grab keypads
+KERNEL=="uinput", SUBSYSTEM=="misc", MODE="0660", GROUP="input", OPTIONS+="static_node=uinput"
+SUBSYSTEM=="input", KERNEL=="event*", MODE="0660", GROUP="input"
+`
+
+// capDacOverride is the CapEff bit that lets a process ignore file
+// permissions, which is all root gives the daemon
+const capDacOverride = 1
+
+// checkAccess lists the device nodes the process cannot open. fatal is set
+// when nothing useful can be done: no uinput or no input device at all.
+func checkAccess() (problems []string, fatal bool) {
+	if f, err := os.OpenFile(vdev.DefaultPath, os.O_WRONLY, 0); err != nil {
+		problems = append(problems, fmt.Sprintf("cannot open %s: %v", vdev.DefaultPath, err))
+		fatal = true
+	} else {
+		f.Close()
+	}
+
+	nodes, _ := filepath.Glob("/dev/input/event*")
+	readable := 0
+	for _, node := range nodes {
+		f, err := os.Open(node)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("cannot open %s: %v", node, err))
+			continue
+		}
+		f.Close()
+		readable++
+	}
+	if readable == 0 {
+		fatal = true
+	}
+	return problems, fatal
+}
+
+// printAccessHelp explains how to give the daemon the access it lacks
+func printAccessHelp(problems []string) {
+	fmt.Println("Missing device access:")
+	for _, p := range problems {
+		fmt.Println(" -", p)
+	}
+
+	if os.Geteuid() == 0 {
+		fmt.Println("Running as root; check the SELinux or AppArmor policy for these nodes.")
+		return
+	}
+	fmt.Printf("Running as uid %d, which needs no root or capabilities if either:\n", os.Geteuid())
+	fmt.Println(" - it is in the group owning the nodes (usually input), e.g. usermod -aG input $USER,")
+	fmt.Printf("   with udev rules granting it uinput: goflipmouse -install-udev-rules writes %s\n", udevRulesPath)
+	fmt.Println(" - or it has CAP_DAC_OVERRIDE, e.g. AmbientCapabilities=CAP_DAC_OVERRIDE in a systemd unit")
+	if hasCapability(capDacOverride) {
+		fmt.Println("CAP_DAC_OVERRIDE is already effective, so a security module is denying access.")
+	}
+	fmt.Println("log_path, pid_path and control_socket must also point somewhere writable.")
+}
+
+// hasCapability reports whether a capability is in the effective set
+func hasCapability(bit uint) bool {
+	data, err := os.ReadFile("/proc/self/status")
+	if err != nil {
+		return false
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if hex, ok := strings.CutPrefix(line, "CapEff:"); ok {
+			caps, err := strconv.ParseUint(strings.TrimSpace(hex), 16, 64)
+			return err == nil && caps&(1<<bit) != 0
+		}
+	}
+	return false
+}
+
+// installUdevRules writes udevRules and asks udev to apply them
+func installUdevRules() error {
+	if err := os.WriteFile(udevRulesPath, []byte(udevRules), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %v", udevRulesPath, err)
+	}
+	fmt.Printf("Wrote %s\n", udevRulesPath)
+
+	for _, args := range [][]string{{"control", "--reload"}, {"trigger", "--subsystem-match=input", "--subsystem-match=misc"}} {
+		if out, err := exec.Command("udevadm", args...).CombinedOutput(); err != nil {
+			return fmt.Errorf("udevadm %s failed: %v: %s", args[0], err, out)
+		}
+	}
+	fmt.Println("Rules applied; add your user to the input group and log in again.")
+	return nil
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/goFlipMouse/vdev"
+)
+
+// Patterns for the focused activity in dumpsys output, e.g.
+// "mResumedActivity: ActivityRecord{1a2b u0 com.android.chrome/.Main t12}"
+var (
+	resumedActivity = regexp.MustCompile(`mResumedActivity: ActivityRecord\{\S+ \S+ ([\w.]+)/`)
+	focusedWindow   = regexp.MustCompile(`mCurrentFocus=Window\{\S+ \S+ ([\w.]+)/`)
+)
+
+// ForegroundApp returns the package name of the app in the foreground
+func ForegroundApp() (string, error) {
+	if out, err := exec.Command("dumpsys", "activity", "activities").Output(); err == nil {
+		if m := resumedActivity.FindSubmatch(out); m != nil {
+			return string(m[1]), nil
+		}
+	}
+	if out, err := exec.Command("dumpsys", "window", "windows").Output(); err == nil {
+		if m := focusedWindow.FindSubmatch(out); m != nil {
+			return string(m[1]), nil
+		}
+	}
+	return "", errors.New("could not determine the foreground app")
+}
+
+// watchForegroundApp polls the foreground app and switches to the profile
+// mapped to it in Config.AppProfiles, or back to Config.Profile for others
+func (app *Application) watchForegroundApp() {
+	ticker := time.NewTicker(app.Config.AppPollInterval.Duration)
+	defer ticker.Stop()
+
+	current := ""
+	for range ticker.C {
+		pkg, err := ForegroundApp()
+		if err != nil || pkg == current {
+			continue
+		}
+		current = pkg
+
+		app.mu.Lock()
+		profile, ok := app.Config.AppProfiles[pkg]
+		if !ok {
+			profile = app.Config.Profile
+		}
+		app.mu.Unlock()
+		if profile == "" || profile == app.Profile() {
+			continue
+		}
+
+		app.Logger.Debug("Foreground app %s\n", pkg)
+		if err := app.SetProfile(profile); err != nil {
+			app.Logger.Printf("Failed to switch profile for %s: %v", pkg, err)
+		}
+	}
+}
+
+// BroadcastConfig makes state changes fire Android broadcast intents, so
+// Tasker, MacroDroid or companion apps can react to them
+type BroadcastConfig struct {
+	Enabled bool `json:"enabled"`
+	// Mode changes carry the boolean extra "on", profile changes the string extra "profile"
+	ModeAction    string `json:"mode_action"`
+	ProfileAction string `json:"profile_action"`
+	// Package limits delivery to one app; "" lets any receiver have them
+	Package string `json:"package"`
+}
+
+// broadcastChanges sends a broadcast for every mode or profile change until
+// the subscription ends
+func (app *Application) broadcastChanges(notices <-chan Notice) {
+	config := app.Config.Broadcasts
+	for n := range notices {
+		var args []string
+		switch n.Kind {
+		case "mode":
+			args = []string{"broadcast", "-a", config.ModeAction, "--ez", "on", strconv.FormatBool(n.On)}
+		case "profile":
+			args = []string{"broadcast", "-a", config.ProfileAction, "--es", "profile", n.Profile}
+		default:
+			continue
+		}
+		if config.Package != "" {
+			args = append(args, "-p", config.Package)
+		}
+
+		if out, err := exec.Command("am", args...).CombinedOutput(); err != nil {
+			app.Logger.Printf("Broadcast %s failed: %v: %s", args[2], err, out)
+		}
+	}
+}
+
+// Android mode, for running from init.rc or a Magisk service.sh at boot
+const (
+	// How long to wait for ueventd to create the uinput node
+	uinputWaitTimeout = 30 * time.Second
+	// AID_SHELL, so adb shell scripts can use the control socket and fifo
+	androidShellGID = 2000
+)
+
+// androidDirs are writable from init and root services under the stock
+// SELinux policy, in order of preference
+var androidDirs = []string{"/cache", "/data/local/tmp"}
+
+// applyAndroid moves the log, pid, socket, fifo and status paths whose
+// directory is not writable into the first writable Android directory
+func (c *Config) applyAndroid() {
+	dir := ""
+	for _, d := range androidDirs {
+		if writable(d) {
+			dir = d
+			break
+		}
+	}
+	if dir == "" {
+		return
+	}
+
+	for _, path := range []*string{&c.LogPath, &c.PidPath, &c.ControlSocket, &c.CommandFIFO, &c.StatusPath} {
+		if *path != "" && !writable(filepath.Dir(*path)) {
+			*path = filepath.Join(dir, filepath.Base(*path))
+		}
+	}
+}
+
+// writable reports whether files can be created in dir
+func writable(dir string) bool {
+	return syscall.Access(dir, 2 /* W_OK */) == nil
+}
+
+// waitForUinput waits until the uinput node exists; early in boot ueventd
+// may not have created it yet
+func waitForUinput() error {
+	deadline := time.Now().Add(uinputWaitTimeout)
+	for {
+		_, err := os.Stat(vdev.DefaultPath)
+		if err == nil {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("%s did not appear: %v", vdev.DefaultPath, err)
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+}
+
+// shareWithShell hands a socket or fifo to the shell group
+func shareWithShell(path string) error {
+	if err := os.Chown(path, -1, androidShellGID); err != nil {
+		return fmt.Errorf("failed to share %s with the shell: %v", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"math"
+	"time"
+)
+
+// Cursor animations selectable with AnimationConfig.Style
+const (
+	AnimationWiggle = "wiggle"
+	AnimationCircle = "circle"
+	AnimationSpiral = "spiral"
+	AnimationNone   = "none"
+)
+
+// animationFrame is how often a smooth animation moves the cursor
+const animationFrame = 16 * time.Millisecond
+
+// AnimationConfig is the movement that draws attention to the cursor when
+// mouse mode turns on and with the find cursor key
+type AnimationConfig struct {
+	Style string `json:"style"`
+	// Amplitude in pixels; 0 uses the current maximum speed
+	Amplitude float64  `json:"amplitude"`
+	Duration  Duration `json:"duration"`
+}
+
+type point struct{ X, Y float64 }
+
+// animationPath lists the offsets from the start the cursor passes
+// through. Every path ends back at the start.
+func animationPath(style string, amplitude float64, frames int) []point {
+	var path []point
+	switch style {
+	case AnimationWiggle, "":
+		return []point{{amplitude, 0}, {0, 0}}
+	case AnimationCircle:
+		for i := 1; i <= frames; i++ {
+			angle := 2 * math.Pi * float64(i) / float64(frames)
+			path = append(path, point{amplitude * (math.Cos(angle) - 1), amplitude * math.Sin(angle)})
+		}
+	case AnimationSpiral:
+		// Two turns outwards, then straight back
+		for i := 1; i < frames; i++ {
+			t := float64(i) / float64(frames)
+			angle := 4 * math.Pi * t
+			path = append(path, point{amplitude * t * math.Cos(angle), amplitude * t * math.Sin(angle)})
+		}
+		path = append(path, point{0, 0})
+	}
+	return path
+}
+
+// Animate plays the configured animation without blocking; it takes mu for
+// each step, so the caller may hold it
+func (mc *MouseController) Animate() {
+	config := mc.Animation
+	amplitude := config.Amplitude
+	if amplitude <= 0 {
+		amplitude = mc.State.MaxSpeed
+	}
+	duration := config.Duration.Duration
+	if duration <= 0 {
+		duration = 50 * time.Millisecond
+	}
+
+	path := animationPath(config.Style, amplitude, max(8, int(duration/animationFrame)))
+	if len(path) == 0 {
+		return
+	}
+	interval := duration / time.Duration(max(1, len(path)-1))
+
+	go func() {
+		var x, y int32
+		for i, p := range path {
+			if i > 0 {
+				time.Sleep(interval)
+			}
+			// Rounding the position, not each step, keeps the cursor from drifting
+			nx, ny := int32(math.Round(p.X)), int32(math.Round(p.Y))
+			if nx == x && ny == y {
+				continue
+			}
+			mc.mu.Lock()
+			if !mc.State.MouseMode {
+				mc.mu.Unlock()
+				return
+			}
+			mc.MoveBy(nx-x, ny-y)
+			mc.mu.Unlock()
+			x, y = nx, ny
+		}
+	}()
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/goFlipMouse/vdev"
+)
+
+// Backend kinds selectable with Config.PointerBackend and Config.KeyboardBackend
+const (
+	// BackendUinput is the keyboard's default, a uinput device
+	BackendUinput = "uinput"
+
+	BackendRelative = "relative"
+	BackendAbsolute = "absolute"
+	// BackendNetwork sends the pointer to a peer's network server, see netclient.go
+	BackendNetwork = "network"
+	// BackendWayland asks a wlroots-based compositor for a virtual pointer, see wayland.go
+	BackendWayland = "wayland"
+	// BackendX11 fakes input on the X server with XTEST, see x11.go
+	BackendX11 = "x11"
+)
+
+// PointerOutput is the virtual pointer a MouseController drives. uinput,
+// the Wayland and X11 backends and network peers all implement it.
+type PointerOutput interface {
+	// Move moves the cursor relative to its current position
+	Move(x, y int32) error
+	Wheel(horizontal bool, delta int32) error
+
+	LeftPress() error
+	LeftRelease() error
+	RightPress() error
+	RightRelease() error
+	MiddlePress() error
+	MiddleRelease() error
+	// ButtonPress and ButtonRelease drive any other button, e.g. BTN_SIDE
+	ButtonPress(code uint16) error
+	ButtonRelease(code uint16) error
+
+	Close() error
+}
+
+// AbsolutePointerOutput is a PointerOutput that can also place the cursor at exact coordinates
+type AbsolutePointerOutput interface {
+	PointerOutput
+	MoveTo(x, y int32) error
+}
+
+// KeyboardOutput is where key events that are not consumed end up: text
+// entry, remaps, pass-through frames and commands all write to it
+type KeyboardOutput interface {
+	vdev.KeySender
+	// TypeString types text with the output's layout
+	TypeString(text string) error
+	// SendFrame forwards a frame read from an input device, ending in SYN_REPORT
+	SendFrame(events []vdev.Event) error
+
+	Close() error
+}
+
+// NewKeyboardOutput creates the virtual keyboard selected by kind, typing
+// text with layout: "uinput" (the default) or "x11" for XTEST. forwarded
+// lists the misc, switch and LED codes a uinput keyboard passes on, see
+// forwardedCapabilities.
+func NewKeyboardOutput(kind string, identity vdev.Identity, layout vdev.Layout, forwarded vdev.Capabilities) (KeyboardOutput, error) {
+	switch kind {
+	case BackendUinput, "":
+		keyboard, err := vdev.CreateKeyboard(vdev.DefaultPath, identity, forwarded)
+		if err != nil {
+			return nil, err
+		}
+		keyboard.Layout = layout
+		return keyboard, nil
+	case BackendX11:
+		return ConnectXTestKeyboard(layout)
+	default:
+		return nil, fmt.Errorf("unknown keyboard backend %q", kind)
+	}
+}
+
+// NewPointerOutput creates the virtual pointer selected by kind. peer is the
+// address network pointers connect to.
+func NewPointerOutput(kind string, identity vdev.Identity, screen ScreenConfig, peer string) (PointerOutput, error) {
+	switch kind {
+	case BackendRelative, "":
+		return vdev.CreateMouse(vdev.DefaultPath, identity)
+	case BackendAbsolute:
+		return vdev.CreateAbsMouse(vdev.DefaultPath, identity, int32(screen.Width), int32(screen.Height))
+	case BackendNetwork:
+		return DialNetMouse(peer)
+	case BackendWayland:
+		return ConnectWayland()
+	case BackendX11:
+		return ConnectXTest()
+	default:
+		return nil, fmt.Errorf("unknown pointer backend %q", kind)
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// sysPowerSupply lists batteries and chargers
+const sysPowerSupply = "/sys/class/power_supply"
+
+// PowerSaveConfig slows the pointer down to save a low battery
+type PowerSaveConfig struct {
+	Enabled bool `json:"enabled"`
+	// Threshold in percent at or below which power saving starts (15)
+	Threshold int `json:"threshold"`
+	// Battery in /sys/class/power_supply; the first one found by default
+	Battery string `json:"battery"`
+	// TickRate caps the movement and scroll rates in Hz while saving (30).
+	// Speeds are per tick, so the pointer gets slower too.
+	TickRate int `json:"tick_rate"`
+	// PollInterval is how often the battery is read (1m)
+	PollInterval Duration `json:"poll_interval"`
+}
+
+// Battery is a power supply of type Battery in sysfs
+type Battery struct {
+	path string
+}
+
+// OpenBattery finds the named battery, or the first one
+func OpenBattery(name string) (*Battery, error) {
+	if name != "" {
+		path := filepath.Join(sysPowerSupply, name)
+		if _, err := os.Stat(filepath.Join(path, "capacity")); err != nil {
+			return nil, fmt.Errorf("no battery %s: %v", name, err)
+		}
+		return &Battery{path: path}, nil
+	}
+
+	paths, _ := filepath.Glob(filepath.Join(sysPowerSupply, "*"))
+	for _, path := range paths {
+		if kind, err := os.ReadFile(filepath.Join(path, "type")); err == nil && strings.TrimSpace(string(kind)) == "Battery" {
+			return &Battery{path: path}, nil
+		}
+	}
+	return nil, fmt.Errorf("no battery in %s", sysPowerSupply)
+}
+
+// Level returns the charge in percent
+func (b *Battery) Level() (int, error) {
+	return readSysfsInt(filepath.Join(b.path, "capacity"))
+}
+
+// Charging reports whether the battery is charging or full on the charger
+func (b *Battery) Charging() bool {
+	status, err := os.ReadFile(filepath.Join(b.path, "status"))
+	if err != nil {
+		return false
+	}
+	switch strings.TrimSpace(string(status)) {
+	case "Charging", "Full":
+		return true
+	}
+	return false
+}
+
+// watchBattery switches power saving with the battery level until the
+// program exits
+func (app *Application) watchBattery(battery *Battery) {
+	config := app.Config.PowerSave
+	interval := config.PollInterval.Duration
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	saving := false
+	for ; ; <-ticker.C {
+		level, err := battery.Level()
+		if err != nil {
+			app.Logger.Printf("Failed to read battery: %v", err)
+			continue
+		}
+		low := level <= config.Threshold && !battery.Charging()
+		if low != saving {
+			saving = low
+			app.Logger.Printf("Battery at %d%%, power saving %s", level, onOff(saving))
+			app.setPowerSave(saving)
+		}
+	}
+}
+
+// setPowerSave caps the tick rates and makes every pointer skip the speed
+// ramp and glide, so the loop goes idle as soon as keys are released
+func (app *Application) setPowerSave(on bool) {
+	rate := 0
+	if on {
+		rate = app.Config.PowerSave.TickRate
+	}
+	app.DeviceManager.SetRateCap(rate)
+	for _, mc := range app.DeviceManager.Controllers {
+		mc.mu.Lock()
+		mc.State.PowerSave = on
+		mc.mu.Unlock()
+	}
+	app.Notices.Publish(Notice{Kind: "power", On: on})
+}
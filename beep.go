@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"github.com/goFlipMouse/vdev"
+)
+
+// Sound output of rendered beeps
+const (
+	beepRate      = 48000
+	beepChannels  = 2
+	beepAmplitude = 0.3 * math.MaxInt16
+	beepFade      = 5 * time.Millisecond
+)
+
+// PC speaker tones, see Documentation/input/event-codes.rst
+const (
+	evSnd   = 0x12
+	sndTone = 0x02
+	// pcspkrPath is the PC speaker's input device on desktops
+	pcspkrPath = "/dev/input/by-path/platform-pcspkr-event-spkr"
+)
+
+// tone is a beep of Freq Hz; a Freq of 0 is a pause
+type tone struct {
+	Freq     int
+	Duration time.Duration
+}
+
+// beepPatterns are the sounds for the events named by feedbackEvent
+var beepPatterns = map[string][]tone{
+	"mode_on":    {{880, 60 * time.Millisecond}, {1320, 80 * time.Millisecond}},
+	"mode_off":   {{1320, 60 * time.Millisecond}, {880, 80 * time.Millisecond}},
+	"drag_start": {{1760, 40 * time.Millisecond}},
+	"drag_stop":  {{660, 40 * time.Millisecond}},
+	"speed":      {{1100, 25 * time.Millisecond}},
+	"edge":       {{330, 60 * time.Millisecond}},
+}
+
+// countTone is repeated to count out a number, see Count
+var countTone = []tone{{1100, 60 * time.Millisecond}, {0, 140 * time.Millisecond}}
+
+// maxCount caps how many tones Count plays
+const maxCount = 20
+
+// BeepConfig makes mode, drag and speed changes audible
+type BeepConfig struct {
+	Enabled bool `json:"enabled"`
+	// Player is a command playing the WAV file appended to it, e.g.
+	// ["tinyplay"] or ["aplay", "-q"], or ["pcspkr"] for the PC speaker.
+	// By default tinyplay is used on Android and aplay elsewhere.
+	Player []string `json:"player"`
+}
+
+// Beeper plays beepPatterns
+type Beeper struct {
+	player  []string
+	dir     string
+	files   map[string]string
+	speaker *os.File
+}
+
+// NewBeeper prepares the sounds: WAV files in dir for a player command, or
+// the PC speaker device
+func NewBeeper(config BeepConfig, android bool, dir string) (*Beeper, error) {
+	player := config.Player
+	if len(player) == 0 {
+		player = []string{"aplay", "-q"}
+		if android {
+			player = []string{"tinyplay"}
+		}
+	}
+
+	if player[0] == "pcspkr" {
+		speaker, err := os.OpenFile(pcspkrPath, os.O_WRONLY, 0)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open the PC speaker: %v", err)
+		}
+		return &Beeper{speaker: speaker}, nil
+	}
+
+	b := &Beeper{player: player, dir: dir, files: map[string]string{}}
+	for name, tones := range beepPatterns {
+		path := filepath.Join(dir, "goFlipMouse-"+name+".wav")
+		if err := os.WriteFile(path, renderWAV(tones), 0644); err != nil {
+			return nil, fmt.Errorf("failed to write beep %s: %v", path, err)
+		}
+		b.files[name] = path
+	}
+	return b, nil
+}
+
+// Beep plays the pattern for an event and returns once it is over
+func (b *Beeper) Beep(event string) error {
+	tones, ok := beepPatterns[event]
+	if !ok {
+		return nil
+	}
+	if b.speaker != nil {
+		return b.speak(tones)
+	}
+	return b.play(b.files[event])
+}
+
+// Count plays n short tones, up to maxCount, and returns once they are over
+func (b *Beeper) Count(n int) error {
+	var tones []tone
+	for i := 0; i < min(n, maxCount); i++ {
+		tones = append(tones, countTone...)
+	}
+	if len(tones) == 0 {
+		return nil
+	}
+	if b.speaker != nil {
+		return b.speak(tones)
+	}
+
+	path := filepath.Join(b.dir, "goFlipMouse-count.wav")
+	if err := os.WriteFile(path, renderWAV(tones), 0644); err != nil {
+		return fmt.Errorf("failed to write beep %s: %v", path, err)
+	}
+	return b.play(path)
+}
+
+// play runs the player command on a WAV file
+func (b *Beeper) play(path string) error {
+	args := append(append([]string{}, b.player[1:]...), path)
+	if out, err := exec.Command(b.player[0], args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("%s failed: %v: %s", b.player[0], err, bytes.TrimSpace(out))
+	}
+	return nil
+}
+
+// speak plays tones on the PC speaker
+func (b *Beeper) speak(tones []tone) error {
+	for _, t := range tones {
+		if err := b.tone(t.Freq); err != nil {
+			return err
+		}
+		time.Sleep(t.Duration)
+	}
+	return b.tone(0)
+}
+
+func (b *Beeper) tone(freq int) error {
+	event := vdev.Event{Type: evSnd, Code: sndTone, Value: int32(freq)}
+	if err := binary.Write(b.speaker, binary.NativeEndian, event); err != nil {
+		return fmt.Errorf("failed to write to the PC speaker: %v", err)
+	}
+	return nil
+}
+
+// Close releases the PC speaker
+func (b *Beeper) Close() error {
+	if b.speaker != nil {
+		b.tone(0)
+		return b.speaker.Close()
+	}
+	return nil
+}
+
+// renderWAV renders tones as 16 bit PCM, fading each in and out so they
+// do not click
+func renderWAV(tones []tone) []byte {
+	var samples []int16
+	for _, t := range tones {
+		n := int(t.Duration.Seconds() * beepRate)
+		fade := int(beepFade.Seconds() * beepRate)
+		for i := 0; i < n; i++ {
+			gain := min(1, float64(i)/float64(fade), float64(n-i)/float64(fade))
+			v := gain * beepAmplitude * math.Sin(2*math.Pi*float64(t.Freq)*float64(i)/beepRate)
+			for c := 0; c < beepChannels; c++ {
+				samples = append(samples, int16(v))
+			}
+		}
+	}
+
+	size := len(samples) * 2
+	var buf bytes.Buffer
+	buf.WriteString("RIFF")
+	binary.Write(&buf, binary.LittleEndian, uint32(36+size))
+	buf.WriteString("WAVEfmt ")
+	binary.Write(&buf, binary.LittleEndian, struct {
+		Size             uint32
+		Format, Channels uint16
+		Rate, ByteRate   uint32
+		Align, Bits      uint16
+	}{16, 1, beepChannels, beepRate, beepRate * beepChannels * 2, beepChannels * 2, 16})
+	buf.WriteString("data")
+	binary.Write(&buf, binary.LittleEndian, uint32(size))
+	binary.Write(&buf, binary.LittleEndian, samples)
+	return buf.Bytes()
+}
+
+// beepChanges plays a beep for each notice that has one, until the
+// subscription ends. A readout, and with SpeedTicks a speed change, counts
+// out the speed.
+func (app *Application) beepChanges(notices <-chan Notice) {
+	for first := range notices {
+		for _, n := range queuedNotices(notices, first) {
+			if !app.routed(n, SinkBeep) {
+				continue
+			}
+			var err error
+			if n.Kind == "readout" || (n.Kind == "speed" && app.Config.SpeedTicks) {
+				err = app.Beeper.Count(int(math.Round(n.Speed)))
+			} else {
+				err = app.Beeper.Beep(feedbackEvent(n))
+			}
+			if err != nil {
+				app.Logger.Printf("Failed to beep: %v", err)
+			}
+		}
+	}
+}
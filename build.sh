@@ -6,12 +6,12 @@ API=30
 ## For ARM64 (64-bit)
 # TARGET=aarch64-linux-android
 # TOOLCHAIN=$NDK/toolchains/llvm/prebuilt/linux-x86_64
-# CC="$TOOLCHAIN/bin/clang --target=$TARGET$API" CXX="$TOOLCHAIN/bin/clang --target=$TARGET$API" GOOS=android GOARCH=arm64 CGO_ENABLED=1 go build -ldflags="-s -w" -o build/mouse main.go
+# CC="$TOOLCHAIN/bin/clang --target=$TARGET$API" CXX="$TOOLCHAIN/bin/clang --target=$TARGET$API" GOOS=android GOARCH=arm64 CGO_ENABLED=1 go build -ldflags="-s -w" -o build/mouse .
 
 # Or for ARMv7 (32-bit)
  TARGET=armv7a-linux-androideabi
  TOOLCHAIN=$NDK/toolchains/llvm/prebuilt/linux-x86_64
- CC="$TOOLCHAIN/bin/clang --target=$TARGET$API" CXX="$TOOLCHAIN/bin/clang --target=$TARGET$API" GOOS=android GOARCH=arm GOARM=7 CGO_ENABLED=1 go build -ldflags="-s -w" -o build/mouse main.go
+ CC="$TOOLCHAIN/bin/clang --target=$TARGET$API" CXX="$TOOLCHAIN/bin/clang --target=$TARGET$API" GOOS=android GOARCH=arm GOARM=7 CGO_ENABLED=1 go build -ldflags="-s -w" -o build/mouse .
 
 cd build
 upx mouse
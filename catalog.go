@@ -0,0 +1,124 @@
+package main
+
+// Catalog maps message IDs to the text shown in one language. Texts are
+// fmt formats: speed takes the speed, profile the profile, feel the feel,
+// readout the speed and scroll speed (and the profile in readout_profile),
+// error the error and bindings the list of keys. A "spoken_" entry
+// replaces the lower-cased text when said aloud.
+type Catalog map[string]string
+
+// catalogs are the built-in languages by ISO 639-1 code. Config.Messages
+// can add or correct entries without rebuilding.
+var catalogs = map[string]Catalog{
+	"en": {
+		"mode_on":                "Mouse ON",
+		"mode_off":               "Mouse OFF",
+		"speed":                  "Speed %g",
+		"drag_on":                "Drag",
+		"drag_off":               "Drag OFF",
+		"profile":                "Profile %s",
+		"feel":                   "Feel %s",
+		"readout":                "Speed %g Scroll %g",
+		"readout_profile":        "Speed %g Scroll %g %s",
+		"spoken_readout":         "speed %g, scroll %g",
+		"spoken_readout_profile": "speed %g, scroll %g, profile %s",
+		"power_on":               "Power saving",
+		"power_off":              "Power saving OFF",
+		"error":                  "Error: %s",
+		"spoken_error":           "error",
+		"error_title":            "goFlipMouse error",
+		"edge_left":              "Left edge",
+		"edge_right":             "Right edge",
+		"edge_top":               "Top edge",
+		"edge_bottom":            "Bottom edge",
+		"bindings":               "%s",
+	},
+	"es": {
+		"mode_on":                "Ratón ACTIVADO",
+		"mode_off":               "Ratón DESACTIVADO",
+		"speed":                  "Velocidad %g",
+		"drag_on":                "Arrastrar",
+		"drag_off":               "Arrastrar DESACTIVADO",
+		"profile":                "Perfil %s",
+		"feel":                   "Estilo %s",
+		"readout":                "Velocidad %g Desplazamiento %g",
+		"readout_profile":        "Velocidad %g Desplazamiento %g %s",
+		"spoken_readout":         "velocidad %g, desplazamiento %g",
+		"spoken_readout_profile": "velocidad %g, desplazamiento %g, perfil %s",
+		"power_on":               "Ahorro de energía",
+		"power_off":              "Ahorro de energía DESACTIVADO",
+		"error":                  "Error: %s",
+		"spoken_error":           "error",
+		"error_title":            "Error de goFlipMouse",
+		"edge_left":              "Borde izquierdo",
+		"edge_right":             "Borde derecho",
+		"edge_top":               "Borde superior",
+		"edge_bottom":            "Borde inferior",
+	},
+	"pt": {
+		"mode_on":                "Mouse LIGADO",
+		"mode_off":               "Mouse DESLIGADO",
+		"speed":                  "Velocidade %g",
+		"drag_on":                "Arrastar",
+		"drag_off":               "Arrastar DESLIGADO",
+		"profile":                "Perfil %s",
+		"feel":                   "Estilo %s",
+		"readout":                "Velocidade %g Rolagem %g",
+		"readout_profile":        "Velocidade %g Rolagem %g %s",
+		"spoken_readout":         "velocidade %g, rolagem %g",
+		"spoken_readout_profile": "velocidade %g, rolagem %g, perfil %s",
+		"power_on":               "Economia de energia",
+		"power_off":              "Economia de energia DESLIGADA",
+		"error":                  "Erro: %s",
+		"spoken_error":           "erro",
+		"error_title":            "Erro do goFlipMouse",
+		"edge_left":              "Borda esquerda",
+		"edge_right":             "Borda direita",
+		"edge_top":               "Borda superior",
+		"edge_bottom":            "Borda inferior",
+	},
+	"fr": {
+		"mode_on":                "Souris ACTIVÉE",
+		"mode_off":               "Souris DÉSACTIVÉE",
+		"speed":                  "Vitesse %g",
+		"drag_on":                "Glisser",
+		"drag_off":               "Glisser DÉSACTIVÉ",
+		"profile":                "Profil %s",
+		"feel":                   "Style %s",
+		"readout":                "Vitesse %g Défilement %g",
+		"readout_profile":        "Vitesse %g Défilement %g %s",
+		"spoken_readout":         "vitesse %g, défilement %g",
+		"spoken_readout_profile": "vitesse %g, défilement %g, profil %s",
+		"power_on":               "Économie d'énergie",
+		"power_off":              "Économie d'énergie DÉSACTIVÉE",
+		"error":                  "Erreur : %s",
+		"spoken_error":           "erreur",
+		"error_title":            "Erreur de goFlipMouse",
+		"edge_left":              "Bord gauche",
+		"edge_right":             "Bord droit",
+		"edge_top":               "Bord haut",
+		"edge_bottom":            "Bord bas",
+	},
+	"de": {
+		"mode_on":                "Maus AN",
+		"mode_off":               "Maus AUS",
+		"speed":                  "Geschwindigkeit %g",
+		"drag_on":                "Ziehen",
+		"drag_off":               "Ziehen AUS",
+		"profile":                "Profil %s",
+		"feel":                   "Stil %s",
+		"readout":                "Geschwindigkeit %g Scrollen %g",
+		"readout_profile":        "Geschwindigkeit %g Scrollen %g %s",
+		"spoken_readout":         "Geschwindigkeit %g, Scrollen %g",
+		"spoken_readout_profile": "Geschwindigkeit %g, Scrollen %g, Profil %s",
+		"power_on":               "Energiesparen",
+		"power_off":              "Energiesparen AUS",
+		"error":                  "Fehler: %s",
+		"spoken_error":           "Fehler",
+		"error_title":            "goFlipMouse-Fehler",
+		"edge_left":              "Linker Rand",
+		"edge_right":             "Rechter Rand",
+		"edge_top":               "Oberer Rand",
+		"edge_bottom":            "Unterer Rand",
+	},
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+)
+
+// clipboardCommands are tried in order until one prints the clipboard
+var clipboardCommands = [][]string{
+	{"wl-paste", "--no-newline"},
+	{"xclip", "-selection", "clipboard", "-o"},
+}
+
+// ReadClipboard returns the system clipboard using the configured command,
+// the Wayland or X11 tools, or Android's clipboard service, whichever works
+func ReadClipboard(command []string) (string, error) {
+	commands := clipboardCommands
+	if len(command) > 0 {
+		commands = [][]string{command}
+	}
+
+	for _, args := range commands {
+		if _, err := exec.LookPath(args[0]); err != nil {
+			continue
+		}
+		out, err := exec.Command(args[0], args[1:]...).Output()
+		if err == nil {
+			return string(out), nil
+		}
+	}
+
+	if len(command) > 0 {
+		return "", fmt.Errorf("clipboard command %s failed", command[0])
+	}
+	return readAndroidClipboard()
+}
+
+// parcelWord matches the hex words of a `service call` parcel dump
+var parcelWord = regexp.MustCompile(`\b[0-9a-f]{8}\b`)
+
+// readAndroidClipboard asks the clipboard service for the primary clip and
+// digs the text out of the parcel dump
+func readAndroidClipboard() (string, error) {
+	out, err := exec.Command("service", "call", "clipboard", "2", "s16", "com.android.shell").Output()
+	if err != nil {
+		return "", fmt.Errorf("no clipboard backend available: %v", err)
+	}
+
+	var parcel []byte
+	for _, line := range strings.Split(string(out), "\n") {
+		// Drop the offset and the ASCII column
+		if i := strings.Index(line, ":"); i >= 0 {
+			line = line[i+1:]
+		}
+		if i := strings.Index(line, "'"); i >= 0 {
+			line = line[:i]
+		}
+		for _, word := range parcelWord.FindAllString(line, -1) {
+			v, _ := strconv.ParseUint(word, 16, 32)
+			parcel = binary.LittleEndian.AppendUint32(parcel, uint32(v))
+		}
+	}
+
+	// The clip's text is the last string after its label and mime types
+	var text string
+	for _, s := range parcelStrings(parcel) {
+		if !strings.Contains(s, "/") || strings.ContainsAny(s, " \n") {
+			text = s
+		}
+	}
+	if text == "" {
+		return "", errors.New("clipboard is empty or not readable")
+	}
+	return text, nil
+}
+
+// parcelStrings finds the length prefixed UTF-16 strings in a parcel
+func parcelStrings(parcel []byte) []string {
+	var found []string
+	for off := 0; off+4 <= len(parcel); off += 4 {
+		n := int(int32(binary.LittleEndian.Uint32(parcel[off:])))
+		end := off + 4 + 2*n
+		if n <= 0 || end+2 > len(parcel) || binary.LittleEndian.Uint16(parcel[end:]) != 0 {
+			continue
+		}
+
+		units := make([]uint16, n)
+		for i := range units {
+			units[i] = binary.LittleEndian.Uint16(parcel[off+4+2*i:])
+		}
+		s := string(utf16.Decode(units))
+		if strings.TrimSpace(s) == "" ||
+			strings.IndexFunc(s, func(r rune) bool { return !unicode.IsPrint(r) && !unicode.IsSpace(r) }) >= 0 {
+			continue
+		}
+		found = append(found, s)
+	}
+	return found
+}
+
+// Paste types the clipboard through the virtual keyboard. Reading the
+// clipboard may take a while, so it happens off the event loop.
+func (ep *EventProcessor) Paste() {
+	go func() {
+		text, err := ReadClipboard(ep.Config.ClipboardCommand)
+		if err != nil {
+			ep.Logger.Printf("Paste failed: %v", err)
+			return
+		}
+		kbd := ep.VirtualKeyboard
+		ep.Emitter.Do(func() error {
+			return kbd.TypeString(text)
+		})
+	}()
+}
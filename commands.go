@@ -0,0 +1,263 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/goFlipMouse/keymaps"
+	"github.com/goFlipMouse/vdev"
+)
+
+// Execute runs a text command against the primary pointer and returns a
+// one-line reply. Leader sequences and the control interfaces share it.
+//
+//	toggle                     switch mouse mode
+//	speed [+N|-N|N]            change or show the maximum speed
+//	profile NAME               switch profile
+//	feel [NAME]                pick a pointer feel, or list them
+//	click [left|right|middle]  click a button
+//	move DX DY                 move the cursor
+//	type TEXT                  type text on the virtual keyboard
+//	key CODE                   tap a key on the virtual keyboard
+//	find                       play the cursor animation
+//	paste                      type the clipboard
+//	rescan                     look for new input devices
+//	status                     show the current state
+//	debug [on|off]             switch debug logging, or toggle it
+//	statusbar                  show the state as waybar JSON
+//	readout                    report speed and profile through feedback
+//	bindings                   list the keypad's keys, also through feedback
+//	load-config JSON           apply a pushed config, see PushConfig
+func (app *Application) Execute(line string) (string, error) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return "", errors.New("empty command")
+	}
+	cmd, args := fields[0], fields[1:]
+	mc := app.MouseController
+
+	switch cmd {
+	case "toggle":
+		mc.mu.Lock()
+		mc.ToggleMouseMode()
+		on := mc.State.MouseMode
+		mc.mu.Unlock()
+		app.EventProcessor.ReleaseModifiers()
+		app.DeviceManager.Wake()
+		return fmt.Sprintf("mouse mode %s", onOff(on)), nil
+
+	case "speed":
+		mc.mu.Lock()
+		defer mc.mu.Unlock()
+		if len(args) == 1 {
+			value, err := strconv.ParseFloat(args[0], 64)
+			if err != nil {
+				return "", fmt.Errorf("invalid speed %q", args[0])
+			}
+			if strings.HasPrefix(args[0], "+") || strings.HasPrefix(args[0], "-") {
+				value += mc.State.MaxSpeed
+			}
+			mc.SetSpeed(value)
+		}
+		return fmt.Sprintf("speed %.1f", mc.State.MaxSpeed), nil
+
+	case "profile":
+		if len(args) != 1 {
+			return "", errors.New("usage: profile NAME")
+		}
+		if err := app.SetProfile(args[0]); err != nil {
+			return "", err
+		}
+		return "profile " + args[0], nil
+
+	case "feel":
+		if len(args) == 0 {
+			return "feels: " + strings.Join(feelNames(), ", "), nil
+		}
+		if err := app.SetFeel(args[0]); err != nil {
+			return "", err
+		}
+		return "feel " + args[0], nil
+
+	case "click":
+		button := uint16(vdev.BtnLeft)
+		if len(args) == 1 {
+			switch args[0] {
+			case "left":
+			case "right":
+				button = vdev.BtnRight
+			case "middle":
+				button = vdev.BtnMiddle
+			default:
+				return "", fmt.Errorf("unknown button %q", args[0])
+			}
+		}
+		mc.mu.Lock()
+		mc.Mouse.ButtonPress(button)
+		mc.Mouse.ButtonRelease(button)
+		mc.mu.Unlock()
+		return "ok", nil
+
+	case "move":
+		if len(args) != 2 {
+			return "", errors.New("usage: move DX DY")
+		}
+		dx, errX := strconv.Atoi(args[0])
+		dy, errY := strconv.Atoi(args[1])
+		if errX != nil || errY != nil {
+			return "", errors.New("move takes two integers")
+		}
+		mc.mu.Lock()
+		mc.MoveBy(int32(dx), int32(dy))
+		mc.mu.Unlock()
+		return "ok", nil
+
+	case "type":
+		text := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), cmd))
+		kbd := app.VirtualKeyboard
+		app.Emitter.Do(func() error {
+			return kbd.TypeString(text)
+		})
+		return "ok", nil
+
+	case "key":
+		if len(args) != 1 {
+			return "", errors.New("usage: key CODE")
+		}
+		code, err := strconv.Atoi(args[0])
+		if err != nil || code <= 0 || code > vdev.KeyMax {
+			return "", fmt.Errorf("invalid key code %q", args[0])
+		}
+		kbd := app.VirtualKeyboard
+		app.Emitter.Do(func() error {
+			return kbd.KeyPress(code)
+		})
+		return "ok", nil
+
+	case "find":
+		mc.mu.Lock()
+		mc.Animate()
+		mc.mu.Unlock()
+		return "ok", nil
+
+	case "paste":
+		app.EventProcessor.Paste()
+		return "ok", nil
+
+	case "rescan":
+		added, err := app.DeviceManager.Rescan()
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("attached %d devices", added), nil
+
+	case "status":
+		return app.Status(), nil
+
+	case "debug":
+		on := !app.Logger.Debugging()
+		if len(args) == 1 {
+			switch args[0] {
+			case "on":
+				on = true
+			case "off":
+				on = false
+			default:
+				return "", errors.New("usage: debug [on|off]")
+			}
+		}
+		app.Logger.SetDebug(on)
+		app.Logger.Printf("Debug logging %s", onOff(on))
+		return "debug " + onOff(on), nil
+
+	case "statusbar":
+		return app.StatusBar().JSON(), nil
+
+	case "readout":
+		mc.mu.Lock()
+		defer mc.mu.Unlock()
+		mc.Readout()
+		return fmt.Sprintf("speed %.1f scroll %.2f profile %s", mc.State.MaxSpeed, mc.State.ScrollMaxSpeed, mc.Profile), nil
+
+	case "bindings":
+		km := app.keyMapping()
+		mc.mu.Lock()
+		mc.ShowBindings(km)
+		mc.mu.Unlock()
+		return strings.ReplaceAll(bindingsText(km), "\n", ", "), nil
+
+	case "load-config":
+		blob := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), cmd))
+		if err := app.PushConfig([]byte(blob)); err != nil {
+			return "", err
+		}
+		return "config loaded", nil
+	}
+	return "", fmt.Errorf("unknown command %q", cmd)
+}
+
+// executeOnLoop runs a command bound to keys. Those run on the event loop,
+// which load-config waits for, so it is refused there.
+func (app *Application) executeOnLoop(line string) (string, error) {
+	if fields := strings.Fields(line); len(fields) > 0 && fields[0] == "load-config" {
+		return "", errors.New("load-config cannot be bound to keys")
+	}
+	return app.Execute(line)
+}
+
+// keyMapping returns the keymap of the first keypad, which commands
+// describe as they have no device of their own
+func (app *Application) keyMapping() keymaps.KeyMapping {
+	kbdType := keymaps.KBD_TYPE_PHONE
+	dm := app.DeviceManager
+	dm.mu.Lock()
+	for _, dev := range dm.Devices {
+		if !dev.Passive {
+			kbdType = dev.KeyboardType
+			break
+		}
+	}
+	dm.mu.Unlock()
+	return app.EventProcessor.KeyMappingProvider.GetMapping(kbdType)
+}
+
+// StatusReport is a snapshot of the primary pointer's state
+type StatusReport struct {
+	MouseMode bool    `json:"mouse_mode"`
+	Speed     float64 `json:"speed"`
+	Profile   string  `json:"profile"`
+	X         float64 `json:"x"`
+	Y         float64 `json:"y"`
+}
+
+// Report takes a snapshot of the primary pointer's state
+func (app *Application) Report() StatusReport {
+	mc := app.MouseController
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	x, y := mc.Position()
+	return StatusReport{
+		MouseMode: mc.State.MouseMode,
+		Speed:     mc.State.MaxSpeed,
+		Profile:   app.Profile(),
+		X:         x,
+		Y:         y,
+	}
+}
+
+// Status summarizes the primary pointer's state on one line
+func (app *Application) Status() string {
+	r := app.Report()
+	return fmt.Sprintf("mode=%s speed=%.1f profile=%s position=%.0f,%.0f",
+		onOff(r.MouseMode), r.Speed, r.Profile, r.X, r.Y)
+}
+
+func onOff(on bool) string {
+	if on {
+		return "on"
+	}
+	return "off"
+}
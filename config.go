@@ -0,0 +1,321 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/goFlipMouse/keymaps"
+	"github.com/goFlipMouse/vdev"
+)
+
+// DefaultConfigPath is where the config file is looked up when no -config flag is given
+const DefaultConfigPath = "/cache/goFlipMouse.json"
+
+// Duration is a time.Duration written as a string such as "225ms" in config files
+type Duration struct {
+	time.Duration
+}
+
+// UnmarshalJSON parses a duration string
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duration must be a string like \"225ms\": %v", err)
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	d.Duration = parsed
+	return nil
+}
+
+// MarshalJSON formats the duration as a string
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// PointerConfig describes an additional virtual pointer and the input
+// devices (by name or /dev/input path) that drive it
+type PointerConfig struct {
+	Mouse   vdev.Identity `json:"mouse"`
+	Devices []string      `json:"devices"`
+}
+
+// Config holds application configuration
+type Config struct {
+	LogPath           string   `json:"log_path"`
+	PidPath           string   `json:"pid_path"`
+	LongPressDuration Duration `json:"long_press_duration"`
+	// Quiet keeps the console free of status output, which only goes to the log
+	Quiet bool `json:"quiet"`
+	// DebugMode logs every key and decision; the debug command and -debug
+	// switch it without editing the config
+	DebugMode bool `json:"debug_mode"`
+	// ExitConfirm guards ExitKey in mouse mode against accidental presses:
+	// "double" leaves on a second press within ExitConfirmWindow, "long" on
+	// a long press; "" leaves on any press
+	ExitConfirm       string   `json:"exit_confirm"`
+	ExitConfirmWindow Duration `json:"exit_confirm_window"`
+	// Pause between the two clicks of DoubleClickKey
+	DoubleClickDelay Duration `json:"double_click_delay"`
+	// How long HoldClickKey keeps the left button down
+	HoldClickDuration Duration `json:"hold_click_duration"`
+	// Held keys that see no repeat or release for this long are released.
+	// Keypads without auto-repeat need a larger value; "0s" disables it.
+	StuckKeyTimeout Duration `json:"stuck_key_timeout"`
+	// JSON status file for status bars and scripts, rewritten on changes and
+	// every StatusInterval; "" disables it
+	StatusPath     string   `json:"status_path"`
+	StatusInterval Duration `json:"status_interval"`
+	// Android runs with SELinux-safe paths and waits for uinput at boot, see -android
+	Android bool `json:"android"`
+	// UNIX socket taking commands from scripts and companion apps; "" disables it
+	ControlSocket string `json:"control_socket"`
+	// Named pipe taking one command per line, e.g. "/cache/goFlipMouse.cmd"; "" disables it
+	CommandFIFO string `json:"command_fifo"`
+	// Publish the control interface on the "session" or "system" D-Bus; "" disables it
+	DBus string `json:"dbus"`
+	// Address of the HTTP API, e.g. "127.0.0.1:8377"; "" disables it. It has
+	// no authentication, so keep it on localhost.
+	HTTPAddr string `json:"http_addr"`
+	// Where overlay apps drawing the cursor connect (see overlay.go), e.g.
+	// "@goflipmouse-overlay"; "" disables it
+	OverlayAddr string `json:"overlay_addr"`
+	// Address of the gRPC API (see goflipmouse.proto); "" disables it
+	GRPCAddr string `json:"grpc_addr"`
+	// Remote control through an MQTT broker
+	MQTT MQTTConfig `json:"mqtt"`
+	// Address receiving network remote packets over TCP and UDP, e.g.
+	// "0.0.0.0:4747"; "" disables it. NetAllow limits senders to IPs or CIDRs.
+	NetListen string   `json:"net_listen"`
+	NetAllow  []string `json:"net_allow"`
+
+	// Identity of the virtual devices as seen by the OS
+	VirtualMouse    vdev.Identity `json:"virtual_mouse"`
+	VirtualKeyboard vdev.Identity `json:"virtual_keyboard"`
+
+	// PointerBackend selects "relative" (REL_X/REL_Y) or "absolute" (ABS_X/ABS_Y)
+	// pointers, "wayland" for a compositor's virtual pointer, "x11" for XTEST,
+	// or "network" to drive the pointer of the peer at NetPeer
+	PointerBackend string       `json:"pointer_backend"`
+	Screen         ScreenConfig `json:"screen"`
+	NetPeer        string       `json:"net_peer"`
+	// KeyboardBackend selects "uinput" or "x11" for the virtual keyboard
+	KeyboardBackend string `json:"keyboard_backend"`
+
+	// Movement drawing attention to the cursor: "wiggle", "circle", "spiral" or "none"
+	CursorAnimation AnimationConfig `json:"cursor_animation"`
+
+	// Additional independent cursors. Devices not listed here drive VirtualMouse.
+	ExtraPointers []PointerConfig `json:"extra_pointers"`
+
+	// Rates of the movement and scroll loops in Hz. Lower rates save CPU on
+	// weak phones; speeds are per tick, so they may need retuning too.
+	MoveRate   int `json:"move_rate"`
+	ScrollRate int `json:"scroll_rate"`
+
+	// Profiles are named tuning sets; Profile selects the one active at startup
+	Profiles []Profile `json:"profiles"`
+	Profile  string    `json:"profile"`
+	// AppProfiles maps Android package names to the profile used while
+	// that app is in the foreground, checked every AppPollInterval
+	AppProfiles     map[string]string `json:"app_profiles"`
+	AppPollInterval Duration          `json:"app_poll_interval"`
+	// Shell commands run on mode_on, mode_off, drag_start, drag_stop,
+	// device_attach, device_detach, start and stop
+	Hooks map[string]string `json:"hooks"`
+	// Android broadcasts sent when mouse mode or the profile changes
+	Broadcasts BroadcastConfig `json:"broadcasts"`
+	// Feedback routes event classes to the sinks below; classes left out
+	// keep their default routes, see feedback.go
+	Feedback FeedbackRoutes `json:"feedback"`
+	// SpeedTicks counts out the new speed on speed changes with one beep or
+	// buzz per step, instead of a single tick
+	SpeedTicks bool `json:"speed_ticks"`
+	// Beeps on mode, drag and speed changes
+	Beep BeepConfig `json:"beep"`
+	// Vibration on mode and drag changes and errors
+	Vibrate VibrateConfig `json:"vibrate"`
+	// LEDs lit while mouse mode is on
+	LEDs LEDConfig `json:"leds"`
+	// Keypad backlight kept on while mouse mode is on
+	KeyBacklight KeyBacklightConfig `json:"key_backlight"`
+	// Slower ticks on a low battery
+	PowerSave PowerSaveConfig `json:"power_save"`
+	// Leave mouse mode when the screen turns off
+	ScreenOff ScreenOffConfig `json:"screen_off"`
+	// How often a held drag is pointed out (see "dragging" in feedback.go);
+	// "0s" disables it
+	DragReminder Duration `json:"drag_reminder"`
+	// On-screen messages for mode, speed, drag and profile changes
+	OSD OSDConfig `json:"osd"`
+	// Desktop notifications for mode changes and errors
+	Notify NotifyConfig `json:"notify"`
+	// Spoken announcements of changes
+	Speech SpeechConfig `json:"speech"`
+	// Language of the messages above, e.g. "es" or "pt_BR"; by default the
+	// one in LC_ALL, LC_MESSAGES or LANG, or Android's system language.
+	// Messages adds or corrects entries of its catalog, see catalog.go.
+	Locale   string            `json:"locale"`
+	Messages map[string]string `json:"messages"`
+	// Discrete steps and arrow keys for TalkBack and Orca users
+	ScreenReader ScreenReaderConfig `json:"screen_reader"`
+
+	// Per device debounce windows, keyed by device name or path. Worn keypads
+	// that double click need a few tens of milliseconds.
+	Debounce map[string]Duration `json:"debounce"`
+
+	// Key remaps applied while mouse mode is off, turning the keypad into a
+	// general remapper
+	Remaps []RemapRule `json:"remaps"`
+	// Text typed on a long press of a key, outside mouse mode
+	Snippets []Snippet `json:"snippets"`
+	// Leader sequences: LeaderKey followed by a sequence's keys runs its
+	// command; giving up if the next key takes longer than LeaderTimeout
+	LeaderKey       uint16           `json:"leader_key"`
+	LeaderTimeout   Duration         `json:"leader_timeout"`
+	LeaderSequences []LeaderSequence `json:"leader_sequences"`
+	// Lua script taking over keys with on_key handlers, see lua.go
+	LuaScript string `json:"lua_script"`
+	// External programs deciding about key events over stdio, see plugins.go
+	Plugins []PluginConfig `json:"plugins"`
+	// Modifier key codes that latch for the next key when tapped on their own
+	StickyModifiers []uint16 `json:"sticky_modifiers"`
+	// Command printing the clipboard for the paste key; by default
+	// wl-paste, xclip and Android's clipboard service are tried
+	ClipboardCommand []string `json:"clipboard_command"`
+	// Taps of the same digit closer together than this cycle its letters in text mode
+	MultiTapTimeout Duration `json:"multi_tap_timeout"`
+	// Characters added to or changed on the US layout used to type text,
+	// e.g. {"ä": {"code": 40, "altgr": true}}
+	KeyboardLayout map[string]vdev.KeyStroke `json:"keyboard_layout"`
+
+	// Keypads grabbed besides the built-in ones, by name or /dev/input path
+	Devices []string `json:"devices"`
+	// JSON file of KeyMapping fields laid over the built-in keymaps, e.g.
+	// {"ToggleMouseKey": 138}; the setup assistant writes one
+	Keymap string `json:"keymap"`
+
+	// Switch devices (e.g. a hall sensor) watched for SW_LID without being grabbed
+	LidDevices []string `json:"lid_devices"`
+	// Release the keypad grab while the flip is closed
+	PauseGrabsWhenClosed bool `json:"pause_grabs_when_closed"`
+}
+
+// Ways of confirming ExitKey, see Config.ExitConfirm
+const (
+	ExitConfirmDouble = "double"
+	ExitConfirmLong   = "long"
+)
+
+// Default configuration
+var defaultConfig = Config{
+	LogPath:           "/cache/goFlipMouse.log",
+	PidPath:           "/cache/goFlipMouse.pid",
+	ControlSocket:     "/cache/goFlipMouse.sock",
+	LongPressDuration: Duration{225 * time.Millisecond},
+	DoubleClickDelay:  Duration{50 * time.Millisecond},
+	ExitConfirmWindow: Duration{500 * time.Millisecond},
+	HoldClickDuration: Duration{800 * time.Millisecond},
+	StuckKeyTimeout:   Duration{5 * time.Second},
+	MultiTapTimeout:   Duration{800 * time.Millisecond},
+	LeaderTimeout:     Duration{time.Second},
+	AppPollInterval:   Duration{2 * time.Second},
+	StatusInterval:    Duration{time.Second},
+	Broadcasts: BroadcastConfig{
+		ModeAction:    "org.goflipmouse.MODE_CHANGED",
+		ProfileAction: "org.goflipmouse.PROFILE_CHANGED",
+	},
+
+	VirtualMouse: vdev.Identity{
+		Name:    "goFlipMouse",
+		Bustype: vdev.BusUSB,
+		Vendor:  0x4711,
+		Product: 0x0816,
+		Version: 1,
+	},
+	VirtualKeyboard: vdev.Identity{
+		Name:    "goFlipKeyboard",
+		Bustype: vdev.BusUSB,
+		Vendor:  0x4711,
+		Product: 0x0815,
+		Version: 1,
+	},
+
+	MoveRate:   60,
+	ScrollRate: 30,
+
+	Profiles: []Profile{{Name: "default"}},
+	Profile:  "default",
+
+	PointerBackend: BackendRelative,
+	Screen:         ScreenConfig{Width: 240, Height: 320, AutoDetect: true},
+
+	CursorAnimation: AnimationConfig{Style: AnimationWiggle, Duration: Duration{50 * time.Millisecond}},
+	PowerSave:       PowerSaveConfig{Threshold: 15, TickRate: 30, PollInterval: Duration{time.Minute}},
+	DragReminder:    Duration{3 * time.Second},
+	ScreenReader:    ScreenReaderConfig{Step: 20},
+}
+
+// Layout is the US layout with KeyboardLayout laid over it; keys that are
+// not a single character are ignored
+func (c Config) Layout() vdev.Layout {
+	layout := vdev.USLayout()
+	for char, stroke := range c.KeyboardLayout {
+		runes := []rune(char)
+		if len(runes) == 1 {
+			layout[runes[0]] = stroke
+		}
+	}
+	return layout
+}
+
+// defaults returns defaultConfig with its own copy of the default profiles,
+// so decoding into it never writes through to defaultConfig
+func defaults() Config {
+	config := defaultConfig
+	config.Profiles = slices.Clone(defaultConfig.Profiles)
+	config.Feedback = defaultFeedbackRoutes.clone()
+	return config
+}
+
+// LoadConfig reads a JSON config file on top of the defaults.
+// A missing file is not an error; the defaults are returned unchanged.
+func LoadConfig(path string) (Config, error) {
+	config := defaults()
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return config, nil
+	}
+	if err != nil {
+		return config, fmt.Errorf("failed to read config %s: %v", path, err)
+	}
+
+	if err := json.Unmarshal(data, &config); err != nil {
+		return config, fmt.Errorf("failed to parse config %s: %v", path, err)
+	}
+	keepGivenScreen(data, &config)
+	return config, nil
+}
+
+// LoadKeymap lays the keys in a keymap file over the mapping of every
+// keyboard type
+func LoadKeymap(provider *keymaps.KeyMappingProvider, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read keymap %s: %v", path, err)
+	}
+	for _, kbdType := range []int{keymaps.KBD_TYPE_PHONE, keymaps.KBD_TYPE_LAPTOP, keymaps.KBD_TYPE_EXTERNAL} {
+		mapping := provider.GetMapping(kbdType)
+		if err := json.Unmarshal(data, &mapping); err != nil {
+			return fmt.Errorf("failed to parse keymap %s: %v", path, err)
+		}
+		provider.RegisterMapping(kbdType, mapping)
+	}
+	return nil
+}
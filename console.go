@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// isTerminal reports whether f is a terminal rather than a pipe, a file or
+// /dev/null as under init systems
+func isTerminal(f *os.File) bool {
+	var termios syscall.Termios
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), syscall.TCGETS, uintptr(unsafe.Pointer(&termios)))
+	return errno == 0
+}
+
+// runConsole takes commands typed while running in the foreground: every
+// control command (see Execute), plus dump for the full pointer state and
+// quit. It returns at the end of input.
+func (app *Application) runConsole(in io.Reader, out io.Writer) {
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(nil, maxCommandLine)
+	for scanner.Scan() {
+		switch line := scanner.Text(); line {
+		case "":
+		case "help":
+			fmt.Fprintln(out, "Commands: toggle, speed N, profile NAME, click, move DX DY, status, dump, quit; see Execute in commands.go")
+		case "dump":
+			app.dumpState(out)
+		case "quit", "exit":
+			fmt.Fprintln(out, "Shutting down...")
+			app.DeviceManager.Stop()
+			return
+		default:
+			reply, err := app.Execute(line)
+			if err != nil {
+				fmt.Fprintf(out, "error: %v\n", err)
+			} else {
+				fmt.Fprintln(out, reply)
+			}
+		}
+	}
+}
+
+// dumpState prints the primary pointer's whole state
+func (app *Application) dumpState(out io.Writer) {
+	mc := app.MouseController
+	mc.mu.Lock()
+	data, err := json.MarshalIndent(mc.State, "", "  ")
+	mc.mu.Unlock()
+	if err != nil {
+		fmt.Fprintf(out, "error: %v\n", err)
+		return
+	}
+	fmt.Fprintf(out, "%s\nprofile %q\n", data, app.Profile())
+}
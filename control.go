@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"syscall"
+)
+
+// maxCommandLine bounds a command line, which has to fit a pushed config
+const maxCommandLine = 1 << 20
+
+// ControlServer accepts commands on a UNIX socket. Each line is run with
+// Application.Execute and answered with "ok REPLY" or "error MESSAGE".
+type ControlServer struct {
+	app      *Application
+	path     string
+	listener net.Listener
+}
+
+// ListenControl opens the control socket, replacing a stale one left by a
+// previous run. The instance lock guarantees no live daemon owns it. A
+// socket passed by systemd socket activation is used instead when present.
+func ListenControl(app *Application, path string) (*ControlServer, error) {
+	if listener := activatedListener(); listener != nil {
+		return &ControlServer{app: app, listener: listener}, nil
+	}
+
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to remove stale socket %s: %v", path, err)
+	}
+
+	// Companion apps run as other users; restrict access with the group (0660).
+	// The socket is created with these permissions, as a chmod afterwards
+	// would leave a moment in which anyone could connect.
+	umask := syscall.Umask(0117)
+	listener, err := net.Listen("unix", path)
+	syscall.Umask(umask)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %v", path, err)
+	}
+
+	return &ControlServer{app: app, path: path, listener: listener}, nil
+}
+
+// Serve accepts clients until Close
+func (s *ControlServer) Serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				s.app.Logger.Printf("Control socket accept failed: %v", err)
+			}
+			return
+		}
+		go func() {
+			defer conn.Close()
+			s.app.serveCommands(conn)
+		}()
+	}
+}
+
+// Close stops accepting clients and removes the socket, unless systemd owns it
+func (s *ControlServer) Close() error {
+	err := s.listener.Close()
+	if s.path != "" {
+		os.Remove(s.path)
+	}
+	return err
+}
+
+// serveCommands runs commands read line by line from a client and writes
+// one reply line for each
+func (app *Application) serveCommands(rw io.ReadWriter) {
+	scanner := bufio.NewScanner(rw)
+	scanner.Buffer(nil, maxCommandLine)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if len(line) == 0 {
+			continue
+		}
+
+		reply, err := app.Execute(line)
+		if err != nil {
+			reply = "error " + err.Error()
+		} else {
+			reply = "ok " + reply
+		}
+		if _, err := fmt.Fprintln(rw, reply); err != nil {
+			return
+		}
+	}
+}
+
+// runCtl implements the ctl subcommand: it sends one command to a running
+// instance's control socket and prints the reply. "load-config FILE" sends
+// the config in FILE, or on stdin for "-". -statusbar prints the statusbar
+// reply for waybar, which gets a "stopped" status if nothing is running.
+func runCtl(args []string) error {
+	flags := flag.NewFlagSet("ctl", flag.ExitOnError)
+	configPath := flags.String("config", DefaultConfigPath, "path to the JSON config file")
+	socket := flags.String("socket", "", "control socket path (default from the config)")
+	statusbar := flags.Bool("statusbar", false, "print the status as waybar JSON")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: goflipmouse ctl [-config path] [-socket path] command [args...]")
+		fmt.Fprintln(flags.Output(), "       goflipmouse ctl [-config path] [-socket path] load-config file|-")
+		fmt.Fprintln(flags.Output(), "       goflipmouse ctl [-config path] [-socket path] -statusbar")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() == 0 && !*statusbar {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	if *socket == "" {
+		config, err := LoadConfig(*configPath)
+		if err != nil {
+			return err
+		}
+		*socket = config.ControlSocket
+	}
+	if *socket == "" {
+		return errors.New("the control socket is disabled in the config")
+	}
+
+	conn, err := net.Dial("unix", *socket)
+	if err != nil {
+		if *statusbar {
+			fmt.Println(stoppedStatusBar.JSON())
+			return nil
+		}
+		return fmt.Errorf("failed to connect to %s: %v", *socket, err)
+	}
+	defer conn.Close()
+
+	line := strings.Join(flags.Args(), " ")
+	if *statusbar {
+		line = "statusbar"
+	}
+	if flags.Arg(0) == "load-config" && flags.NArg() == 2 {
+		if line, err = configCommand(flags.Arg(1)); err != nil {
+			return err
+		}
+	}
+	if _, err := fmt.Fprintln(conn, line); err != nil {
+		return fmt.Errorf("failed to send command: %v", err)
+	}
+	reply, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("failed to read reply: %v", err)
+	}
+
+	reply = strings.TrimSuffix(reply, "\n")
+	if msg, failed := strings.CutPrefix(reply, "error "); failed {
+		return errors.New(msg)
+	}
+	fmt.Println(strings.TrimPrefix(reply, "ok "))
+	return nil
+}
+
+// configCommand reads a config file, or stdin for "-", into a one-line
+// load-config command
+func configCommand(path string) (string, error) {
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read config: %v", err)
+	}
+
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, data); err != nil {
+		return "", fmt.Errorf("failed to parse config: %v", err)
+	}
+	return "load-config " + compact.String(), nil
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+)
+
+// Well-known name, object path and interface of the D-Bus service
+const (
+	dbusName      = "org.goflipmouse"
+	dbusPath      = dbus.ObjectPath("/org/goflipmouse")
+	dbusInterface = "org.goflipmouse.Control"
+)
+
+// dbusService exports the control commands on D-Bus. Its methods mirror
+// the control socket; ModeChanged is signalled when mouse mode toggles.
+type dbusService struct {
+	app *Application
+}
+
+// Execute runs any control command and returns its reply
+func (s dbusService) Execute(command string) (string, *dbus.Error) {
+	reply, err := s.app.Execute(command)
+	if err != nil {
+		return "", dbus.MakeFailedError(err)
+	}
+	return reply, nil
+}
+
+// Toggle switches mouse mode and returns the new mode
+func (s dbusService) Toggle() (bool, *dbus.Error) {
+	if _, err := s.app.Execute("toggle"); err != nil {
+		return false, dbus.MakeFailedError(err)
+	}
+	return s.MouseMode()
+}
+
+// MouseMode reports whether mouse mode is on
+func (s dbusService) MouseMode() (bool, *dbus.Error) {
+	mc := s.app.MouseController
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	return mc.State.MouseMode, nil
+}
+
+// SetProfile switches to the named profile
+func (s dbusService) SetProfile(name string) *dbus.Error {
+	if err := s.app.SetProfile(name); err != nil {
+		return dbus.MakeFailedError(err)
+	}
+	return nil
+}
+
+// Status returns the one-line status summary
+func (s dbusService) Status() (string, *dbus.Error) {
+	return s.app.Status(), nil
+}
+
+// ExportDBus publishes the control interface on the "session" or "system" bus
+func (app *Application) ExportDBus(bus string) (*dbus.Conn, error) {
+	var conn *dbus.Conn
+	var err error
+	switch bus {
+	case "session":
+		conn, err = dbus.ConnectSessionBus()
+	case "system":
+		conn, err = dbus.ConnectSystemBus()
+	default:
+		return nil, fmt.Errorf("unknown D-Bus bus %q", bus)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to the %s bus: %v", bus, err)
+	}
+
+	service := dbusService{app: app}
+	node := &introspect.Node{
+		Name: string(dbusPath),
+		Interfaces: []introspect.Interface{
+			introspect.IntrospectData,
+			{
+				Name:    dbusInterface,
+				Methods: introspect.Methods(service),
+				Signals: []introspect.Signal{{
+					Name: "ModeChanged",
+					Args: []introspect.Arg{{Name: "on", Type: "b"}},
+				}},
+			},
+		},
+	}
+	if err := conn.Export(service, dbusPath, dbusInterface); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to export D-Bus object: %v", err)
+	}
+	if err := conn.Export(introspect.NewIntrospectable(node), dbusPath, "org.freedesktop.DBus.Introspectable"); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to export D-Bus introspection: %v", err)
+	}
+
+	reply, err := conn.RequestName(dbusName, dbus.NameFlagDoNotQueue)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to request D-Bus name: %v", err)
+	}
+	if reply != dbus.RequestNameReplyPrimaryOwner {
+		conn.Close()
+		return nil, fmt.Errorf("D-Bus name %s is already taken", dbusName)
+	}
+
+	notices, _ := app.Notices.SubscribeChanges()
+	go func() {
+		for n := range notices {
+			if n.Kind == "mode" {
+				conn.Emit(dbusPath, dbusInterface+".ModeChanged", n.On)
+			}
+		}
+	}()
+	return conn, nil
+}
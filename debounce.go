@@ -0,0 +1,46 @@
+package main
+
+import (
+	"time"
+
+	evdev "github.com/grafov/evdev"
+)
+
+// keyChange is the last key state accepted from a device
+type keyChange struct {
+	value int32
+	at    time.Time
+}
+
+// debounceFor returns the debounce window configured for a device, matched
+// by name or path like the other device lists
+func (c Config) debounceFor(name, path string) time.Duration {
+	if d, ok := c.Debounce[name]; ok {
+		return d.Duration
+	}
+	return c.Debounce[path].Duration
+}
+
+// debounce is a Middleware muting contact bounce: a press that comes within
+// the device's debounce window of the key's accepted release, or a repeat of
+// a state that is already in effect. A release following an accepted press is
+// never muted, however soon, or a quick tap would leave the key held.
+func (ep *EventProcessor) debounce(event *evdev.InputEvent, device *InputDevice) Decision {
+	if device.Debounce <= 0 || event.Type != EvKey || event.Value == KeyRepeated {
+		return Continue
+	}
+
+	if device.lastChange == nil {
+		device.lastChange = map[uint16]keyChange{}
+	}
+	last, seen := device.lastChange[event.Code]
+	now := time.Now()
+	bounced := event.Value == KeyPressed && now.Sub(last.at) < device.Debounce
+	if seen && (event.Value == last.value || bounced) {
+		ep.Logger.Debug("Debounced %d=%d on %s\n", event.Code, event.Value, device.Name)
+		return Mute
+	}
+
+	device.lastChange[event.Code] = keyChange{value: event.Value, at: now}
+	return Continue
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Ways of telling whether the screen is on
+const (
+	DisplayBacklight = "backlight"
+	DisplayDRM       = "drm"
+	DisplayDumpsys   = "dumpsys"
+)
+
+// Where the kernel reports backlights and display connectors
+const (
+	sysBacklight = "/sys/class/backlight"
+	sysDRM       = "/sys/class/drm"
+)
+
+// wakefulness finds the power manager state in "dumpsys power"
+var wakefulness = regexp.MustCompile(`mWakefulness=(\w+)`)
+
+// ScreenOffConfig leaves mouse mode when the screen turns off, so keys
+// pressed in a pocket do not click
+type ScreenOffConfig struct {
+	Enabled bool `json:"enabled"`
+	// Source is "dumpsys" (the default on Android), "backlight" or "drm";
+	// elsewhere a backlight is used if there is one, otherwise DRM
+	Source string `json:"source"`
+	// PollInterval is how often the screen is checked (1s)
+	PollInterval Duration `json:"poll_interval"`
+}
+
+// displayProbe reports whether the screen is on
+type displayProbe func() (bool, error)
+
+// newDisplayProbe picks the configured way to check the screen
+func newDisplayProbe(source string, android bool) (displayProbe, error) {
+	if source == "" {
+		switch {
+		case android:
+			source = DisplayDumpsys
+		case hasEntries(sysBacklight):
+			source = DisplayBacklight
+		default:
+			source = DisplayDRM
+		}
+	}
+	switch source {
+	case DisplayBacklight:
+		return backlightOn, nil
+	case DisplayDRM:
+		return drmOn, nil
+	case DisplayDumpsys:
+		return dumpsysOn, nil
+	}
+	return nil, fmt.Errorf("unknown screen source %q", source)
+}
+
+func hasEntries(dir string) bool {
+	entries, _ := os.ReadDir(dir)
+	return len(entries) > 0
+}
+
+// backlightOn reports whether any backlight is powered and lit
+func backlightOn() (bool, error) {
+	paths, _ := filepath.Glob(filepath.Join(sysBacklight, "*"))
+	if len(paths) == 0 {
+		return false, fmt.Errorf("no backlight in %s", sysBacklight)
+	}
+	for _, path := range paths {
+		// bl_power is 0 when powered; not every driver has it
+		if power, err := readSysfsInt(filepath.Join(path, "bl_power")); err == nil && power != 0 {
+			continue
+		}
+		brightness, err := readSysfsInt(filepath.Join(path, "brightness"))
+		if err != nil {
+			return false, err
+		}
+		if brightness > 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// drmOn reports whether any connected display is not in a DPMS power
+// saving state
+func drmOn() (bool, error) {
+	paths, _ := filepath.Glob(filepath.Join(sysDRM, "card*-*"))
+	connected := false
+	for _, path := range paths {
+		status, err := os.ReadFile(filepath.Join(path, "status"))
+		if err != nil || strings.TrimSpace(string(status)) != "connected" {
+			continue
+		}
+		connected = true
+		if dpms, err := os.ReadFile(filepath.Join(path, "dpms")); err == nil && strings.TrimSpace(string(dpms)) == "On" {
+			return true, nil
+		}
+	}
+	if !connected {
+		return false, fmt.Errorf("no connected display in %s", sysDRM)
+	}
+	return false, nil
+}
+
+// dumpsysOn asks Android's power manager whether the device is awake
+func dumpsysOn() (bool, error) {
+	out, err := exec.Command("dumpsys", "power").Output()
+	if err != nil {
+		return false, fmt.Errorf("dumpsys power failed: %v", err)
+	}
+	m := wakefulness.FindSubmatch(out)
+	if m == nil {
+		return false, fmt.Errorf("no wakefulness in dumpsys power")
+	}
+	return string(m[1]) == "Awake", nil
+}
+
+// watchScreen leaves mouse mode on every pointer when the screen goes off,
+// until the program exits
+func (app *Application) watchScreen(probe displayProbe) {
+	interval := app.Config.ScreenOff.PollInterval.Duration
+	if interval <= 0 {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	wasOn := true
+	for range ticker.C {
+		on, err := probe()
+		if err != nil {
+			app.Logger.Debug("Failed to check the screen: %v\n", err)
+			continue
+		}
+		if !on && wasOn {
+			app.Logger.Printf("Screen off, leaving mouse mode")
+			app.DeviceManager.OnLoop(func() {
+				for _, mc := range app.DeviceManager.Controllers {
+					mc.mu.Lock()
+					mc.ExitMouseMode()
+					mc.ResetButtons()
+					mc.mu.Unlock()
+				}
+				app.EventProcessor.ReleaseModifiers()
+			})
+		}
+		wasOn = on
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/goFlipMouse/keymaps"
+	evdev "github.com/grafov/evdev"
+)
+
+// dumpIdle ends a dump of grabbed devices after this long without events,
+// in case the grab took the keyboard Ctrl+C would be typed on
+const dumpIdle = 30 * time.Second
+
+// dumpEvent is an event, or the error that ended reading, from a device
+// being dumped
+type dumpEvent struct {
+	dev   *evdev.InputDevice
+	event evdev.InputEvent
+	err   error
+}
+
+// DumpEvents prints every event from the devices named, by name or path, or
+// without names from the keypads goFlipMouse would use, with the kernel's
+// names and the keymap actions bound to each key. When none of those is
+// present it reads every device with keys, to show where the keys come from.
+// Nothing is created or forwarded; with grab, keys only reach the dump. It
+// runs until interrupted.
+func DumpEvents(config Config, names []string, grab bool, out io.Writer) error {
+	keyMappingProvider := keymaps.CreateDefaultKeyMappingProvider()
+	if config.Keymap != "" {
+		if err := LoadKeymap(keyMappingProvider, config.Keymap); err != nil {
+			return err
+		}
+	}
+
+	wanted := append(slices.Clone(builtinDevices), config.Devices...)
+	for _, extra := range config.ExtraPointers {
+		wanted = append(wanted, extra.Devices...)
+	}
+
+	all := keyDevices()
+	defer func() {
+		for _, dev := range all {
+			dev.File.Close()
+		}
+	}()
+	if len(all) == 0 {
+		return fmt.Errorf("no input devices with keys; is this running as root? see -install-udev-rules")
+	}
+
+	selected := names
+	if len(selected) == 0 {
+		selected = wanted
+	}
+	var devices []*evdev.InputDevice
+	for _, dev := range all {
+		if matchesDevice(dev.Name, dev.Fn, selected) {
+			devices = append(devices, dev)
+		}
+	}
+	if len(devices) == 0 {
+		if len(names) > 0 {
+			return fmt.Errorf("no input device with keys is called %s", strings.Join(names, " or "))
+		}
+		fmt.Fprintln(out, "None of the keypads goFlipMouse uses is present, reading every device with keys.")
+		devices = all
+	}
+
+	actions := map[*evdev.InputDevice]map[uint16][]string{}
+	fmt.Fprintln(out, "Reading:")
+	for _, dev := range devices {
+		note := ""
+		if !matchesDevice(dev.Name, dev.Fn, wanted) {
+			note = ` (not used, add its name to "devices")`
+		}
+		fmt.Fprintf(out, "  %s  %s%s\n", dev.Fn, dev.Name, note)
+		actions[dev] = keyActions(keyMappingProvider.GetMapping(keymaps.GetKeyboardType(dev.Name)))
+
+		if grab {
+			if err := dev.Grab(); err != nil {
+				return fmt.Errorf("failed to grab %s: %v", dev.Name, err)
+			}
+			defer dev.Release()
+		}
+	}
+
+	events := make(chan dumpEvent)
+	for _, dev := range devices {
+		go func() {
+			for {
+				read, err := dev.Read()
+				if err != nil {
+					events <- dumpEvent{dev: dev, err: err}
+					return
+				}
+				for _, event := range read {
+					events <- dumpEvent{dev: dev, event: event}
+				}
+			}
+		}()
+	}
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(interrupt)
+
+	// Without a grab Ctrl+C always gets through
+	var idle <-chan time.Time
+	var idleTimer *time.Timer
+	if grab {
+		idleTimer = time.NewTimer(dumpIdle)
+		defer idleTimer.Stop()
+		idle = idleTimer.C
+		fmt.Fprintf(out, "Press keys; Ctrl+C or %v without keys stops.\n", dumpIdle)
+	} else {
+		fmt.Fprintln(out, "Press keys; Ctrl+C stops.")
+	}
+
+	for {
+		select {
+		case <-interrupt:
+			return nil
+		case <-idle:
+			fmt.Fprintf(out, "No keys for %v, stopping.\n", dumpIdle)
+			return nil
+		case e := <-events:
+			if e.err != nil {
+				fmt.Fprintf(out, "Stopped reading %s: %v\n", e.dev.Name, e.err)
+				continue
+			}
+			if idleTimer != nil {
+				idleTimer.Reset(dumpIdle)
+			}
+			// Frames end in SYN_REPORT, which says nothing about the keys
+			if e.event.Type == EvSyn && e.event.Code == SynReport {
+				continue
+			}
+			fmt.Fprintln(out, dumpLine(e.dev.Name, e.event, actions[e.dev]))
+		}
+	}
+}
+
+// keyActions lists the actions bound to each key of a keymap
+func keyActions(km keymaps.KeyMapping) map[uint16][]string {
+	actions := map[uint16][]string{}
+	for _, b := range km.Bindings() {
+		actions[b.Code] = append(actions[b.Code], b.Action)
+	}
+	return actions
+}
+
+// dumpLine describes an event as "time device: EV_KEY KEY_UP 1 -> up"
+func dumpLine(name string, event evdev.InputEvent, actions map[uint16][]string) string {
+	line := fmt.Sprintf("%d.%06d %s: %s", int64(event.Time.Sec), int64(event.Time.Usec), name, eventName(event.Type, event.Code, event.Value))
+	if event.Type != EvKey {
+		return line
+	}
+	if bound := actions[event.Code]; len(bound) > 0 {
+		return line + " -> " + strings.Join(bound, ", ")
+	}
+	return line + " -> not bound, passed through"
+}
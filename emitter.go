@@ -0,0 +1,110 @@
+package main
+
+// emitQueueSize bounds the writes waiting for the emitter. Producers block
+// when it is full, which pushes back on the event loop.
+const emitQueueSize = 256
+
+// Emitter serializes writes to every virtual device on one goroutine, so
+// output leaves in the order it was produced whichever device it targets,
+// and slow uinput writes never hold up reading and processing
+type Emitter struct {
+	queue  chan func() error
+	logger *Logger
+	// Notices receives an "error" notice for each failed write; nil for none
+	Notices *NoticeHub
+}
+
+// NewEmitter creates an emitter; Run must be started for queued writes to happen
+func NewEmitter(logger *Logger) *Emitter {
+	return &Emitter{
+		queue:  make(chan func() error, emitQueueSize),
+		logger: logger,
+	}
+}
+
+// Run performs queued writes in order
+func (e *Emitter) Run() {
+	for write := range e.queue {
+		if err := write(); err != nil {
+			e.logger.Printf("Failed to emit: %v", err)
+			if e.Notices != nil {
+				e.Notices.Publish(Notice{Kind: "error", Message: "Failed to emit: " + err.Error()})
+			}
+		}
+	}
+}
+
+// Do queues a write
+func (e *Emitter) Do(write func() error) {
+	e.queue <- write
+}
+
+// Flush waits until every write queued so far has been performed
+func (e *Emitter) Flush() {
+	done := make(chan struct{})
+	e.Do(func() error {
+		close(done)
+		return nil
+	})
+	<-done
+}
+
+// Mouse wraps a backend so its output is queued on the emitter. Errors are
+// logged by the emitter instead of being returned.
+func (e *Emitter) Mouse(backend PointerOutput) PointerOutput {
+	m := orderedMouse{backend: backend, emitter: e}
+	if abs, ok := backend.(AbsolutePointerOutput); ok {
+		return orderedAbsMouse{orderedMouse: m, abs: abs}
+	}
+	return m
+}
+
+// orderedMouse is a PointerOutput that writes through an Emitter
+type orderedMouse struct {
+	backend PointerOutput
+	emitter *Emitter
+}
+
+func (m orderedMouse) queue(write func() error) error {
+	m.emitter.Do(write)
+	return nil
+}
+
+func (m orderedMouse) Move(x, y int32) error {
+	return m.queue(func() error { return m.backend.Move(x, y) })
+}
+
+func (m orderedMouse) Wheel(horizontal bool, delta int32) error {
+	return m.queue(func() error { return m.backend.Wheel(horizontal, delta) })
+}
+
+func (m orderedMouse) LeftPress() error     { return m.queue(m.backend.LeftPress) }
+func (m orderedMouse) LeftRelease() error   { return m.queue(m.backend.LeftRelease) }
+func (m orderedMouse) RightPress() error    { return m.queue(m.backend.RightPress) }
+func (m orderedMouse) RightRelease() error  { return m.queue(m.backend.RightRelease) }
+func (m orderedMouse) MiddlePress() error   { return m.queue(m.backend.MiddlePress) }
+func (m orderedMouse) MiddleRelease() error { return m.queue(m.backend.MiddleRelease) }
+
+func (m orderedMouse) ButtonPress(code uint16) error {
+	return m.queue(func() error { return m.backend.ButtonPress(code) })
+}
+
+func (m orderedMouse) ButtonRelease(code uint16) error {
+	return m.queue(func() error { return m.backend.ButtonRelease(code) })
+}
+
+// Close lets queued output drain before destroying the device
+func (m orderedMouse) Close() error {
+	m.emitter.Flush()
+	return m.backend.Close()
+}
+
+// orderedAbsMouse is an AbsolutePointerOutput that writes through an Emitter
+type orderedAbsMouse struct {
+	orderedMouse
+	abs AbsolutePointerOutput
+}
+
+func (m orderedAbsMouse) MoveTo(x, y int32) error {
+	return m.queue(func() error { return m.abs.MoveTo(x, y) })
+}
@@ -0,0 +1,334 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sync/atomic"
+	"syscall"
+	"time"
+	"unsafe"
+
+	evdev "github.com/grafov/evdev"
+)
+
+// timerfd and eventfd flags from the kernel headers
+const (
+	clockMonotonic = 1
+	tfdFlags       = syscall.O_CLOEXEC | syscall.O_NONBLOCK
+	efdFlags       = syscall.O_CLOEXEC | syscall.O_NONBLOCK
+)
+
+// Events read per epoll wakeup and per read from a device
+const (
+	maxEpollEvents = 16
+	readBatch      = 64
+)
+
+// inputEventSize is the size of struct input_event, which depends on the
+// width of the timestamp on this architecture
+var inputEventSize = binary.Size(evdev.InputEvent{})
+
+// translated to go from time.h
+type itimerspec struct {
+	Interval syscall.Timespec
+	Value    syscall.Timespec
+}
+
+// eventLoop multiplexes every device, the movement and scroll ticks and
+// wakeups from other goroutines onto one thread with epoll
+type eventLoop struct {
+	epfd        int
+	wakeFd      int
+	moveTimer   int
+	scrollTimer int
+	// watchdogTimer pets the systemd watchdog; -1 when it is off
+	watchdogTimer int
+
+	// devices maps watched file descriptors to their device; guarded by DeviceManager.mu
+	devices map[int32]*InputDevice
+
+	stopping atomic.Bool
+	// open is set once openLoop has created the file descriptors; Wake does
+	// nothing before
+	open atomic.Bool
+	// calls are run by the loop between events, see OnLoop
+	calls chan func()
+
+	// Periods the timers are armed with, 0 while disarmed. Only the loop touches these.
+	movePeriod   time.Duration
+	scrollPeriod time.Duration
+
+	buf []byte
+}
+
+// openLoop creates the epoll instance, the wakeup eventfd and the tick timers
+func (dm *DeviceManager) openLoop() error {
+	l := &dm.loop
+	l.devices = map[int32]*InputDevice{}
+	l.buf = make([]byte, inputEventSize*readBatch)
+
+	var err error
+	if l.epfd, err = syscall.EpollCreate1(syscall.EPOLL_CLOEXEC); err != nil {
+		return fmt.Errorf("failed to create epoll instance: %v", err)
+	}
+	if l.wakeFd, err = eventfd(); err != nil {
+		return fmt.Errorf("failed to create wakeup eventfd: %v", err)
+	}
+	if l.moveTimer, err = timerfd(); err != nil {
+		return fmt.Errorf("failed to create movement timer: %v", err)
+	}
+	if l.scrollTimer, err = timerfd(); err != nil {
+		return fmt.Errorf("failed to create scroll timer: %v", err)
+	}
+
+	for _, fd := range []int{l.wakeFd, l.moveTimer, l.scrollTimer} {
+		if err := l.add(fd); err != nil {
+			return err
+		}
+	}
+
+	// Petting from the loop itself means a stuck loop lets systemd restart us
+	l.watchdogTimer = -1
+	if period := sdWatchdogPeriod(); period > 0 {
+		if l.watchdogTimer, err = timerfd(); err != nil {
+			return fmt.Errorf("failed to create watchdog timer: %v", err)
+		}
+		armTimer(l.watchdogTimer, period)
+		if err := l.add(l.watchdogTimer); err != nil {
+			return err
+		}
+	}
+	l.open.Store(true)
+	return nil
+}
+
+func (l *eventLoop) add(fd int) error {
+	event := syscall.EpollEvent{Events: syscall.EPOLLIN, Fd: int32(fd)}
+	if err := syscall.EpollCtl(l.epfd, syscall.EPOLL_CTL_ADD, fd, &event); err != nil {
+		return fmt.Errorf("failed to watch fd %d: %v", fd, err)
+	}
+	return nil
+}
+
+// watch adds a device to the loop
+func (dm *DeviceManager) watch(device *InputDevice) error {
+	fd := int(device.Device.File.Fd())
+	// Fd leaves the file in blocking mode; a spurious wakeup must not stall the loop
+	if err := syscall.SetNonblock(fd, true); err != nil {
+		return fmt.Errorf("failed to make %s non-blocking: %v", device.Name, err)
+	}
+
+	dm.mu.Lock()
+	dm.loop.devices[int32(fd)] = device
+	dm.mu.Unlock()
+
+	if err := dm.loop.add(fd); err != nil {
+		return err
+	}
+	dm.notify(Notice{Kind: "device", On: true, Device: device.Name})
+	return nil
+}
+
+// unwatch removes a device from the loop before its file is closed
+func (dm *DeviceManager) unwatch(device *InputDevice) {
+	fd := int(device.Device.File.Fd())
+	syscall.EpollCtl(dm.loop.epfd, syscall.EPOLL_CTL_DEL, fd, nil)
+
+	dm.mu.Lock()
+	delete(dm.loop.devices, int32(fd))
+	dm.mu.Unlock()
+
+	dm.notify(Notice{Kind: "device", Device: device.Name})
+}
+
+// Wake makes the loop re-evaluate its tick timers. It is safe to call from
+// any goroutine, e.g. after a rate change or a state change made outside
+// the loop. Before the loop is open there is nothing to wake; it catches up
+// when it starts.
+func (dm *DeviceManager) Wake() {
+	if !dm.loop.open.Load() {
+		return
+	}
+	one := uint64(1)
+	syscall.Write(dm.loop.wakeFd, (*[8]byte)(unsafe.Pointer(&one))[:])
+}
+
+// OnLoop runs fn on the event loop between two events and waits for it,
+// so state only the loop touches can be changed in one step. Calls made
+// before the loop runs wait for it to start.
+func (dm *DeviceManager) OnLoop(fn func()) {
+	done := make(chan struct{})
+	dm.loop.calls <- func() {
+		fn()
+		close(done)
+	}
+	dm.Wake()
+	<-done
+}
+
+// runCalls runs the functions queued with OnLoop
+func (l *eventLoop) runCalls() {
+	for {
+		select {
+		case fn := <-l.calls:
+			fn()
+		default:
+			return
+		}
+	}
+}
+
+// Stop ends the loop; Run returns once it has
+func (dm *DeviceManager) Stop() {
+	dm.loop.stopping.Store(true)
+	dm.Wake()
+}
+
+// runLoop reads devices and runs movement and scroll ticks until Stop
+func (dm *DeviceManager) runLoop() error {
+	l := &dm.loop
+	events := make([]syscall.EpollEvent, maxEpollEvents)
+
+	// Nothing could wake the loop before it opened: run the calls queued
+	// meanwhile and arm the timers for the current state
+	l.runCalls()
+	dm.updateTimers()
+
+	for !l.stopping.Load() {
+		n, err := syscall.EpollWait(l.epfd, events, -1)
+		if err == syscall.EINTR {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("epoll wait failed: %v", err)
+		}
+
+		for _, event := range events[:n] {
+			switch int(event.Fd) {
+			case l.wakeFd:
+				drain(l.wakeFd)
+				l.runCalls()
+			case l.moveTimer:
+				drain(l.moveTimer)
+				for _, mc := range dm.Controllers {
+					dm.moveController(mc)
+				}
+			case l.scrollTimer:
+				drain(l.scrollTimer)
+				for _, mc := range dm.Controllers {
+					dm.scrollController(mc)
+				}
+			case l.watchdogTimer:
+				drain(l.watchdogTimer)
+				sdNotify("WATCHDOG=1")
+			default:
+				dm.mu.Lock()
+				device := l.devices[event.Fd]
+				dm.mu.Unlock()
+				if device != nil {
+					dm.readEvents(device)
+				}
+			}
+		}
+
+		dm.updateTimers()
+	}
+	return nil
+}
+
+// readEvents handles every event currently queued on a device
+func (dm *DeviceManager) readEvents(device *InputDevice) {
+	buf := dm.loop.buf
+	n, err := syscall.Read(int(device.Device.File.Fd()), buf)
+	if err == syscall.EAGAIN {
+		return
+	}
+	if err != nil || n == 0 {
+		dm.reportError("Device %s went away: %v", device.Name, err)
+		dm.unwatch(device)
+		dm.detach(device)
+		return
+	}
+
+	events := make([]evdev.InputEvent, n/inputEventSize)
+	if err := binary.Read(bytes.NewReader(buf[:n]), binary.LittleEndian, events); err != nil {
+		dm.reportError("Error decoding events from %s: %v", device.Name, err)
+		return
+	}
+	for i := range events {
+		dm.handleEvent(device, &events[i])
+	}
+}
+
+// updateTimers arms the tick timers while some pointer moves or scrolls and
+// disarms them otherwise, so an idle loop sleeps in epoll_wait
+func (dm *DeviceManager) updateTimers() {
+	moving, scrolling, moveRate, scrollRate := dm.ticksNeeded()
+	l := &dm.loop
+	l.movePeriod = setTimer(l.moveTimer, l.movePeriod, moving, moveRate)
+	l.scrollPeriod = setTimer(l.scrollTimer, l.scrollPeriod, scrolling, scrollRate)
+}
+
+// ticksNeeded reports whether some pointer moves or scrolls and the
+// rates to tick at
+func (dm *DeviceManager) ticksNeeded() (moving, scrolling bool, moveRate, scrollRate int) {
+	for _, mc := range dm.Controllers {
+		mc.mu.Lock()
+		moving = moving || mc.IsMoving()
+		scrolling = scrolling || mc.IsScrolling()
+		mc.mu.Unlock()
+	}
+
+	dm.mu.Lock()
+	moveRate, scrollRate = dm.moveRate, dm.scrollRate
+	if dm.rateCap > 0 {
+		moveRate, scrollRate = min(moveRate, dm.rateCap), min(scrollRate, dm.rateCap)
+	}
+	dm.mu.Unlock()
+	return moving, scrolling, moveRate, scrollRate
+}
+
+// setTimer (re)arms or disarms a timerfd if its period changed and returns the new period
+func setTimer(fd int, current time.Duration, active bool, hz int) time.Duration {
+	var period time.Duration
+	if active {
+		period = tickInterval(hz)
+	}
+	if period == current {
+		return current
+	}
+	armTimer(fd, period)
+	return period
+}
+
+// armTimer makes a timerfd fire every period, or disarms it for 0
+func armTimer(fd int, period time.Duration) {
+	spec := itimerspec{
+		Interval: syscall.NsecToTimespec(period.Nanoseconds()),
+		Value:    syscall.NsecToTimespec(period.Nanoseconds()),
+	}
+	syscall.Syscall6(syscall.SYS_TIMERFD_SETTIME, uintptr(fd), 0, uintptr(unsafe.Pointer(&spec)), 0, 0, 0)
+}
+
+func timerfd() (int, error) {
+	fd, _, errno := syscall.Syscall(syscall.SYS_TIMERFD_CREATE, clockMonotonic, tfdFlags, 0)
+	if errno != 0 {
+		return -1, errno
+	}
+	return int(fd), nil
+}
+
+func eventfd() (int, error) {
+	fd, _, errno := syscall.Syscall(syscall.SYS_EVENTFD2, 0, efdFlags, 0)
+	if errno != 0 {
+		return -1, errno
+	}
+	return int(fd), nil
+}
+
+// drain consumes the counter of an eventfd or timerfd
+func drain(fd int) {
+	var buf [8]byte
+	syscall.Read(fd, buf[:])
+}
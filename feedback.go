@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+)
+
+// Feedback sinks a notice can be routed to
+const (
+	SinkBeep    = "beep"
+	SinkVibrate = "vibrate"
+	SinkLED     = "led"
+	SinkOSD     = "osd"
+	SinkNotify  = "notify"
+	SinkSpeak   = "speak"
+	SinkLog     = "log"
+)
+
+var feedbackSinks = []string{SinkBeep, SinkVibrate, SinkLED, SinkOSD, SinkNotify, SinkSpeak, SinkLog}
+
+// FeedbackRoutes maps an event class, the Kind of a notice such as "mode",
+// "speed", "drag" or "error", to the sinks that show it. A sink still has
+// to be enabled in its own section; LEDs only follow mode.
+type FeedbackRoutes map[string][]string
+
+// defaultFeedbackRoutes keep every sink on what it did before routing
+// existed. Errors are logged where they happen, so they are not routed to
+// the log. "dragging" repeats while a drag holds a button, see remindDrag;
+// "edge" comes from screen reader steps.
+var defaultFeedbackRoutes = FeedbackRoutes{
+	"mode":     {SinkBeep, SinkVibrate, SinkLED, SinkOSD, SinkNotify, SinkSpeak, SinkLog},
+	"speed":    {SinkBeep, SinkVibrate, SinkOSD, SinkSpeak, SinkLog},
+	"drag":     {SinkBeep, SinkVibrate, SinkOSD, SinkSpeak, SinkLog},
+	"profile":  {SinkOSD, SinkSpeak, SinkLog},
+	"feel":     {SinkOSD, SinkSpeak, SinkLog},
+	"readout":  {SinkBeep, SinkOSD, SinkSpeak, SinkLog},
+	"power":    {SinkOSD, SinkSpeak, SinkLog},
+	"error":    {SinkVibrate, SinkOSD, SinkNotify, SinkSpeak},
+	"dragging": {SinkVibrate, SinkLED},
+	"edge":     {SinkBeep, SinkVibrate, SinkSpeak},
+	"bindings": {SinkOSD, SinkSpeak, SinkLog},
+}
+
+// clone copies the routes, so decoding a config into them leaves the
+// defaults alone
+func (r FeedbackRoutes) clone() FeedbackRoutes {
+	return maps.Clone(r)
+}
+
+// validate reports sinks that do not exist
+func (r FeedbackRoutes) validate() []string {
+	var problems []string
+	for class, sinks := range r {
+		for _, sink := range sinks {
+			if !slices.Contains(feedbackSinks, sink) {
+				problems = append(problems, fmt.Sprintf("feedback for %q goes to unknown sink %q", class, sink))
+			}
+		}
+	}
+	return problems
+}
+
+// routed reports whether a notice is to be shown on sink
+func (app *Application) routed(n Notice, sink string) bool {
+	app.mu.Lock()
+	defer app.mu.Unlock()
+	return slices.Contains(app.Config.Feedback[n.Kind], sink)
+}
+
+// remindDrag publishes a "dragging" notice every interval while any
+// pointer holds a drag, so a forgotten drag does not select things by
+// accident. It runs until the program exits.
+func (app *Application) remindDrag(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		for _, mc := range app.DeviceManager.Controllers {
+			mc.mu.Lock()
+			button := mc.State.DragButton
+			mc.mu.Unlock()
+			if button != 0 {
+				app.Notices.Publish(Notice{Kind: "dragging", On: true, Code: button})
+			}
+		}
+	}
+}
+
+// latestMessage returns the message of the last notice already queued that
+// has one, or text if none has. Slow sinks use it to catch up.
+func latestMessage(notices <-chan Notice, text string, message func(Notice) string) string {
+	for {
+		select {
+		case n, ok := <-notices:
+			if !ok {
+				return text
+			}
+			if msg := message(n); msg != "" {
+				text = msg
+			}
+		default:
+			return text
+		}
+	}
+}
+
+// queuedNotices returns n and the notices already queued behind it, with
+// runs of speed changes cut down to the last one, so a sink counting out
+// the speed does not lag behind a held key
+func queuedNotices(notices <-chan Notice, n Notice) []Notice {
+	queue := []Notice{n}
+	for {
+		select {
+		case next, ok := <-notices:
+			if !ok {
+				return queue
+			}
+			if last := &queue[len(queue)-1]; last.Kind == "speed" && next.Kind == "speed" {
+				*last = next
+			} else {
+				queue = append(queue, next)
+			}
+		default:
+			return queue
+		}
+	}
+}
+
+// logChanges prints notices routed to the log, until the subscription ends
+func (app *Application) logChanges(notices <-chan Notice) {
+	for n := range notices {
+		if !app.routed(n, SinkLog) {
+			continue
+		}
+		if msg := app.Messages.Notice(n); msg != "" {
+			app.Logger.Info("%s\n", msg)
+		}
+	}
+}
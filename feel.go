@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// Feel is a preset bundling the pointer physics and tick rates, for users
+// who would rather pick how the pointer feels than tune numbers
+type Feel struct {
+	Acceleration float64
+	Friction     float64
+	// SpeedMulti scales the movement of each tick, so a feel with a lower
+	// MoveRate keeps the pace of the speed setting
+	SpeedMulti float64
+	MoveRate   int
+	ScrollRate int
+}
+
+// feels are the presets by name
+var feels = map[string]Feel{
+	// Full speed at once and a quick stop on release
+	"crisp": {Acceleration: 1, Friction: 0.5, SpeedMulti: 1, MoveRate: 60, ScrollRate: 30},
+	// Slow to get going and a long glide
+	"floaty": {Acceleration: 0.1, Friction: 0.95, SpeedMulti: 1, MoveRate: 60, ScrollRate: 30},
+	// Hops a quarter as often and four times as long, easy to follow
+	"stepped": {Acceleration: 1, Friction: 0, SpeedMulti: 4, MoveRate: 15, ScrollRate: 15},
+}
+
+// feelNames lists the presets in order
+func feelNames() []string {
+	return slices.Sorted(maps.Keys(feels))
+}
+
+// findFeel looks up a preset by name
+func findFeel(name string) (Feel, error) {
+	feel, ok := feels[name]
+	if !ok {
+		return Feel{}, fmt.Errorf("unknown feel %q, try %s", name, strings.Join(feelNames(), ", "))
+	}
+	return feel, nil
+}
+
+// applyFeel loads a preset's physics into the mouse state
+func (mc *MouseController) applyFeel(feel Feel) {
+	mc.State.Acceleration = feel.Acceleration
+	mc.State.Friction = feel.Friction
+	mc.State.SpeedMulti = feel.SpeedMulti
+}
+
+// SetFeel applies the named preset to every pointer and the tick rates,
+// until a profile or another feel changes them
+func (app *Application) SetFeel(name string) error {
+	feel, err := findFeel(name)
+	if err != nil {
+		return err
+	}
+	for _, mc := range append([]*MouseController{app.MouseController}, app.ExtraControllers...) {
+		mc.mu.Lock()
+		mc.applyFeel(feel)
+		mc.mu.Unlock()
+	}
+	app.DeviceManager.SetTickRates(feel.MoveRate, feel.ScrollRate)
+	app.Notices.Publish(Notice{Kind: "feel", Message: name})
+	return nil
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// CommandFIFO reads control commands written to a named pipe, one per line.
+// There is no way to reply, so replies and errors go to the log.
+type CommandFIFO struct {
+	app  *Application
+	path string
+	file *os.File
+}
+
+// OpenCommandFIFO creates the pipe if needed and opens it. It is opened for
+// writing too, so the reader never sees end of file between writers.
+func OpenCommandFIFO(app *Application, path string) (*CommandFIFO, error) {
+	info, err := os.Stat(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		if err := syscall.Mkfifo(path, 0620); err != nil {
+			return nil, fmt.Errorf("failed to create fifo %s: %v", path, err)
+		}
+	case err != nil:
+		return nil, fmt.Errorf("failed to stat fifo %s: %v", path, err)
+	case info.Mode()&os.ModeNamedPipe == 0:
+		return nil, fmt.Errorf("%s exists and is not a fifo", path)
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open fifo %s: %v", path, err)
+	}
+	return &CommandFIFO{app: app, path: path, file: file}, nil
+}
+
+// Serve runs commands until Close
+func (f *CommandFIFO) Serve() {
+	scanner := bufio.NewScanner(f.file)
+	scanner.Buffer(nil, maxCommandLine)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if len(line) == 0 {
+			continue
+		}
+
+		reply, err := f.app.Execute(line)
+		if err != nil {
+			f.app.Logger.Printf("FIFO command %q failed: %v", line, err)
+			continue
+		}
+		f.app.Logger.Debug("FIFO command %q: %s\n", line, reply)
+	}
+}
+
+// Close stops reading; the pipe is left in place for the next run
+func (f *CommandFIFO) Close() error {
+	return f.file.Close()
+}
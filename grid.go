@@ -0,0 +1,56 @@
+package main
+
+// GridRect is the screen area grid mode is currently subdividing
+type GridRect struct {
+	X, Y          float64
+	Width, Height float64
+}
+
+// ToggleGridMode switches grid click mode on/off
+func (mc *MouseController) ToggleGridMode() {
+	mc.State.GridMode = !mc.State.GridMode
+	mc.ResetGrid()
+
+	if mc.State.GridMode {
+		mc.Logger.Info("Grid mode activated\n")
+	} else {
+		mc.Logger.Info("Grid mode deactivated\n")
+	}
+}
+
+// ResetGrid makes the next grid selection start from the whole screen again
+func (mc *MouseController) ResetGrid() {
+	w, h := mc.Screen.Logical()
+	mc.State.GridRect = GridRect{
+		Width:  float64(w),
+		Height: float64(h),
+	}
+}
+
+// SelectGridCell narrows the grid to cell n (1-9, laid out like a phone
+// keypad) and moves the cursor to the centre of that cell. Each further
+// selection subdivides the chosen cell again.
+func (mc *MouseController) SelectGridCell(n int) {
+	if n < 1 || n > 9 {
+		return
+	}
+
+	r := mc.State.GridRect
+	cellW, cellH := r.Width/3, r.Height/3
+	col, row := float64((n-1)%3), float64((n-1)/3)
+
+	mc.State.GridRect = GridRect{
+		X:      r.X + col*cellW,
+		Y:      r.Y + row*cellH,
+		Width:  cellW,
+		Height: cellH,
+	}
+
+	x := mc.State.GridRect.X + cellW/2
+	y := mc.State.GridRect.Y + cellH/2
+	if err := mc.MoveTo(int32(x), int32(y)); err != nil {
+		mc.Logger.Printf("Grid move failed: %v", err)
+		return
+	}
+	mc.Logger.Debug("Grid cell %d -> (%.0f, %.0f)\n", n, x, y)
+}
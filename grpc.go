@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// The goflipmouse.Control service described in goflipmouse.proto. Its
+// messages are protobuf well-known types, so it is registered by hand
+// instead of from generated code.
+var controlServiceDesc = grpc.ServiceDesc{
+	ServiceName: "goflipmouse.Control",
+	HandlerType: (*any)(nil),
+	Methods: []grpc.MethodDesc{
+		{MethodName: "Execute", Handler: grpcExecute},
+		{MethodName: "Status", Handler: grpcStatus},
+	},
+	Streams: []grpc.StreamDesc{
+		{StreamName: "Events", Handler: grpcEvents, ServerStreams: true},
+	},
+	Metadata: "goflipmouse.proto",
+}
+
+// GRPCServer serves the gRPC control API
+type GRPCServer struct {
+	app      *Application
+	server   *grpc.Server
+	listener net.Listener
+}
+
+// ListenGRPC starts listening on addr; Serve handles the calls
+func ListenGRPC(app *Application, addr string) (*GRPCServer, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %v", addr, err)
+	}
+
+	s := &GRPCServer{app: app, server: grpc.NewServer(), listener: listener}
+	s.server.RegisterService(&controlServiceDesc, s)
+	return s, nil
+}
+
+// Serve handles calls until Close
+func (s *GRPCServer) Serve() {
+	if err := s.server.Serve(s.listener); err != nil {
+		s.app.Logger.Printf("gRPC API stopped: %v", err)
+	}
+}
+
+// Close stops the server and ends open event streams
+func (s *GRPCServer) Close() {
+	s.server.Stop()
+}
+
+// grpcExecute runs a control command: StringValue in, StringValue reply out
+func grpcExecute(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+	in := new(wrapperspb.StringValue)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	handler := func(ctx context.Context, req any) (any, error) {
+		reply, err := srv.(*GRPCServer).app.Execute(req.(*wrapperspb.StringValue).GetValue())
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		return wrapperspb.String(reply), nil
+	}
+	if interceptor == nil {
+		return handler(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/goflipmouse.Control/Execute"}
+	return interceptor(ctx, in, info, handler)
+}
+
+// grpcStatus returns the StatusReport as a Struct
+func grpcStatus(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+	in := new(emptypb.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	handler := func(ctx context.Context, req any) (any, error) {
+		return toStruct(srv.(*GRPCServer).app.Report())
+	}
+	if interceptor == nil {
+		return handler(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/goflipmouse.Control/Status"}
+	return interceptor(ctx, in, info, handler)
+}
+
+// grpcEvents streams every Notice as a Struct until the client goes away
+func grpcEvents(srv any, stream grpc.ServerStream) error {
+	if err := stream.RecvMsg(new(emptypb.Empty)); err != nil {
+		return err
+	}
+
+	notices, cancel := srv.(*GRPCServer).app.Notices.Subscribe()
+	defer cancel()
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case n, ok := <-notices:
+			if !ok {
+				return nil
+			}
+			msg, err := toStruct(n)
+			if err != nil {
+				return err
+			}
+			if err := stream.SendMsg(msg); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// toStruct converts a value to a Struct through its JSON encoding
+func toStruct(v any) (*structpb.Struct, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	return structpb.NewStruct(fields)
+}
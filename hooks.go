@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// hookTimeout bounds how long a hook script may run
+const hookTimeout = 10 * time.Second
+
+// hookEvent names the hook a notice triggers, "" for none
+func hookEvent(n Notice) string {
+	switch n.Kind {
+	case "mode":
+		if n.On {
+			return "mode_on"
+		}
+		return "mode_off"
+	case "drag":
+		if n.On {
+			return "drag_start"
+		}
+		return "drag_stop"
+	case "device":
+		if n.On {
+			return "device_attach"
+		}
+		return "device_detach"
+	}
+	return ""
+}
+
+// feedbackEvent names the event a notice gives feedback for: the hook
+// events plus "speed", "readout", "dragging", "error" and "edge"
+func feedbackEvent(n Notice) string {
+	switch n.Kind {
+	case "speed", "readout", "dragging", "error", "edge":
+		return n.Kind
+	}
+	return hookEvent(n)
+}
+
+// runHooks runs the hook scripts for notices, one at a time, until the
+// subscription ends
+func (app *Application) runHooks(notices <-chan Notice) {
+	for n := range notices {
+		event := hookEvent(n)
+		if event == "" {
+			continue
+		}
+		app.runHook(event,
+			"GOFLIPMOUSE_DEVICE="+n.Device,
+			fmt.Sprintf("GOFLIPMOUSE_BUTTON=%d", n.Code))
+	}
+}
+
+// runHook runs the script configured for event, if any, with sh and waits
+// for it. The event name and env are added to its environment.
+func (app *Application) runHook(event string, env ...string) {
+	script := app.Config.Hooks[event]
+	if script == "" {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), hookTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", script)
+	cmd.Env = append(os.Environ(), "GOFLIPMOUSE_EVENT="+event)
+	cmd.Env = append(cmd.Env, env...)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		app.Logger.Printf("Hook %s failed: %v: %s", event, err, out)
+		return
+	}
+	app.Logger.Debug("Ran hook %s\n", event)
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// HTTPServer serves the REST/JSON control API:
+//
+//	GET  /status   the current StatusReport
+//	POST /toggle   switch mouse mode, replies with the new StatusReport
+//	POST /move     move the cursor by {"dx": N, "dy": N}
+//	POST /command  run any control command given as {"command": "..."}
+//	GET  /events   a WebSocket streaming every Notice as JSON, see websocket.go
+//	GET  /debug    a page showing that stream live
+//
+// Requests must come from no web page but the debug page, see local, and
+// POST requests must be application/json, see guard.
+type HTTPServer struct {
+	app      *Application
+	server   *http.Server
+	listener net.Listener
+}
+
+// ListenHTTP starts listening on addr; Serve handles the requests
+func ListenHTTP(app *Application, addr string) (*HTTPServer, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %v", addr, err)
+	}
+
+	s := &HTTPServer{app: app, listener: listener}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /status", local(s.status))
+	mux.HandleFunc("POST /toggle", guard(s.toggle))
+	mux.HandleFunc("POST /move", guard(s.move))
+	mux.HandleFunc("POST /command", guard(s.command))
+	mux.HandleFunc("GET /events", local(s.events))
+	mux.HandleFunc("GET /debug", s.debugPage)
+	s.server = &http.Server{Handler: mux}
+	return s, nil
+}
+
+// Serve handles requests until Close
+func (s *HTTPServer) Serve() {
+	if err := s.server.Serve(s.listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		s.app.Logger.Printf("HTTP API stopped: %v", err)
+	}
+}
+
+// Close stops the server
+func (s *HTTPServer) Close() error {
+	return s.server.Close()
+}
+
+// local keeps web pages opened on the device from reading or driving it.
+// The Host must be an address or localhost, since a page's own domain could
+// be rebound to 127.0.0.1, and the Origin a browser adds must be this server.
+func local(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !localHost(r.Host) {
+			writeError(w, http.StatusForbidden, fmt.Errorf("host %q is not an address of this device", r.Host))
+			return
+		}
+		if origin := r.Header.Get("Origin"); origin != "" {
+			u, err := url.Parse(origin)
+			if err != nil || u.Host != r.Host {
+				writeError(w, http.StatusForbidden, fmt.Errorf("requests from %s are not allowed", origin))
+				return
+			}
+		}
+		handler(w, r)
+	}
+}
+
+// guard is local for requests that change something. Browsers send a
+// text/plain POST anywhere without asking, so JSON is required as well.
+func guard(handler http.HandlerFunc) http.HandlerFunc {
+	return local(func(w http.ResponseWriter, r *http.Request) {
+		if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "application/json" {
+			writeError(w, http.StatusUnsupportedMediaType, errors.New("content type must be application/json"))
+			return
+		}
+		handler(w, r)
+	})
+}
+
+// localHost reports whether a Host header names the server by address or as
+// localhost rather than by a domain
+func localHost(host string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	return host == "localhost" || net.ParseIP(host) != nil
+}
+
+func (s *HTTPServer) status(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.app.Report())
+}
+
+func (s *HTTPServer) toggle(w http.ResponseWriter, r *http.Request) {
+	s.run(w, "toggle")
+}
+
+func (s *HTTPServer) move(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		DX int `json:"dx"`
+		DY int `json:"dy"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid body: %v", err))
+		return
+	}
+	s.run(w, fmt.Sprintf("move %d %d", req.DX, req.DY))
+}
+
+func (s *HTTPServer) command(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Command string `json:"command"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid body: %v", err))
+		return
+	}
+	reply, err := s.app.Execute(req.Command)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"reply": reply})
+}
+
+// run executes a command and replies with the resulting status
+func (s *HTTPServer) run(w http.ResponseWriter, command string) {
+	if _, err := s.app.Execute(command); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, s.app.Report())
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, code int, err error) {
+	writeJSON(w, code, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// How long -replace waits for the running instance to shut down
+const replaceTimeout = 5 * time.Second
+
+// InstanceLock guards against two daemons grabbing the same devices
+type InstanceLock struct {
+	file *os.File
+}
+
+// AcquireInstanceLock takes an exclusive flock on the pidfile and records our pid in it.
+// If another instance holds the lock it is asked to exit when replace is set,
+// otherwise an error naming its pid is returned.
+func AcquireInstanceLock(path string, replace bool) (*InstanceLock, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open pidfile %s: %v", path, err)
+	}
+
+	err = tryLock(file)
+	if err == syscall.EWOULDBLOCK {
+		pid := readPid(file)
+		if !replace {
+			file.Close()
+			return nil, fmt.Errorf("another instance is already running (pid %d); use -replace to take over", pid)
+		}
+
+		fmt.Printf("Replacing running instance (pid %d)\n", pid)
+		if pid > 0 {
+			syscall.Kill(pid, syscall.SIGTERM)
+		}
+
+		deadline := time.Now().Add(replaceTimeout)
+		for err == syscall.EWOULDBLOCK && time.Now().Before(deadline) {
+			time.Sleep(100 * time.Millisecond)
+			err = tryLock(file)
+		}
+		if err == syscall.EWOULDBLOCK {
+			file.Close()
+			return nil, fmt.Errorf("instance (pid %d) did not exit within %v", pid, replaceTimeout)
+		}
+	}
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to lock pidfile %s: %v", path, err)
+	}
+
+	if err := file.Truncate(0); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to write pidfile %s: %v", path, err)
+	}
+	if _, err := file.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to write pidfile %s: %v", path, err)
+	}
+
+	return &InstanceLock{file: file}, nil
+}
+
+// Release clears the pidfile and drops the lock
+func (l *InstanceLock) Release() {
+	if l == nil || l.file == nil {
+		return
+	}
+	// The file itself is left in place so a waiting -replace keeps locking the same inode
+	l.file.Truncate(0)
+	l.file.Close()
+	l.file = nil
+}
+
+func tryLock(file *os.File) error {
+	return syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+}
+
+func readPid(file *os.File) int {
+	data, err := io.ReadAll(io.NewSectionReader(file, 0, 32))
+	if err != nil {
+		return 0
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0
+	}
+	return pid
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+)
+
+// keyBacklightNames are the LED names kernels give keypad backlights, most
+// common first
+var keyBacklightNames = []string{
+	"button-backlight",
+	"keyboard-backlight",
+	"kpd_backlight",
+	"keypad-backlight",
+	"*::kbd_backlight",
+}
+
+// KeyBacklightConfig turns the keypad backlight on while mouse mode is on,
+// so the keys can be found in the dark
+type KeyBacklightConfig struct {
+	Enabled bool `json:"enabled"`
+	// Name of the LED in /sys/class/leds; found by its usual names if empty
+	Name string `json:"name"`
+	// Brightness while on; 0 means max_brightness
+	Brightness int `json:"brightness"`
+	// Refresh is how often the backlight is turned on again after the
+	// system's own timeout switched it off
+	Refresh Duration `json:"refresh"`
+}
+
+// findKeyBacklight returns the name of the first keypad backlight found
+func findKeyBacklight() (string, error) {
+	for _, name := range keyBacklightNames {
+		if paths, _ := filepath.Glob(filepath.Join(sysLEDs, name)); len(paths) > 0 {
+			return filepath.Base(paths[0]), nil
+		}
+	}
+	return "", fmt.Errorf("no keypad backlight in %s", sysLEDs)
+}
+
+// NewKeyBacklight finds the keypad backlight and prepares an indicator for it
+func NewKeyBacklight(config KeyBacklightConfig) (*ModeIndicator, error) {
+	name := config.Name
+	if name == "" {
+		var err error
+		if name, err = findKeyBacklight(); err != nil {
+			return nil, err
+		}
+	}
+	return NewModeIndicator(LEDConfig{Names: []string{name}, Brightness: config.Brightness})
+}
+
+// lightKeypad keeps the keypad lit while mouse mode is on, until the
+// subscription ends
+func (app *Application) lightKeypad(notices <-chan Notice) {
+	refresh := app.Config.KeyBacklight.Refresh.Duration
+	if refresh <= 0 {
+		refresh = time.Second
+	}
+	ticker := time.NewTicker(refresh)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case n, ok := <-notices:
+			if !ok {
+				return
+			}
+			if n.Kind != "mode" || !app.routed(n, SinkLED) {
+				continue
+			}
+			if err := app.KeyBacklight.Set(n.On); err != nil {
+				app.Logger.Printf("Failed to set keypad backlight: %v", err)
+			}
+		case <-ticker.C:
+			if err := app.KeyBacklight.Refresh(); err != nil {
+				app.Logger.Printf("Failed to refresh keypad backlight: %v", err)
+			}
+		}
+	}
+}
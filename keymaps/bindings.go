@@ -0,0 +1,52 @@
+package keymaps
+
+import (
+	"reflect"
+	"strings"
+	"unicode"
+)
+
+// Binding is an action and the key bound to it
+type Binding struct {
+	Action string
+	Code   uint16
+}
+
+// Bindings lists the bound keys, named after their fields: ToggleMouseKey
+// becomes "toggle mouse" and ScrollLayer.Up "scroll layer up". Keypad
+// arrays such as GridKeys are left out.
+func (k KeyMapping) Bindings() []Binding {
+	return appendBindings(nil, "", reflect.ValueOf(k))
+}
+
+func appendBindings(bindings []Binding, prefix string, v reflect.Value) []Binding {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := v.Field(i)
+		name := prefix + actionName(t.Field(i).Name)
+		switch field.Kind() {
+		case reflect.Uint16:
+			if code := uint16(field.Uint()); code != Unbound {
+				bindings = append(bindings, Binding{Action: name, Code: code})
+			}
+		case reflect.Struct:
+			bindings = appendBindings(bindings, name+" ", field)
+		}
+	}
+	return bindings
+}
+
+// actionName turns a field name such as "ScrollUpKey" into "scroll up"
+func actionName(field string) string {
+	var b strings.Builder
+	for i, r := range strings.TrimSuffix(field, "Key") {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				b.WriteByte(' ')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
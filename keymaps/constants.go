@@ -6,3 +6,7 @@ const (
 	KBD_TYPE_LAPTOP
 	KBD_TYPE_EXTERNAL
 )
+
+// Unbound marks an action with no key. Code 0 is KEY_RESERVED, which no
+// real key reports, and is never treated as a match.
+const Unbound = 0
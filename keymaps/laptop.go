@@ -7,6 +7,14 @@ func GetLaptopKeyMapping() KeyMapping {
 	n.EnterKey = 28
 	n.ToggleMouseKey = 29
 	n.DragKey = 32        // D key
+	n.RightDragKey = 19   // r key
+	n.DoubleClickKey = 46 // c key
+	n.HoldClickKey = 35   // h key
+	n.PasteKey = 47       // v key
+	n.MiddleClickKey = 50 // m key
+	n.MiddleDragKey = 49  // n key
+	n.BackKey = 14        // backspace
+	n.ForwardKey = 15     // tab
 	n.FasterKey = 13      // = key
 	n.SlowerKey = 12      // - key
 	n.UpKey = 103         // up arrow
@@ -17,6 +25,23 @@ func GetLaptopKeyMapping() KeyMapping {
 	n.ScrollDownKey = 31  // s key
 	n.ScrollLeftKey = 30  // a key
 	n.ScrollRightKey = 32 // d key
+	n.StopScrollKey = 45  // x key
+	n.PrecisionKey = 42   // left shift
+	n.TurboKey = 56       // left alt
+	n.GridModeKey = 34    // g key
+	n.FindCursorKey = 33  // f key
+	n.ReadoutKey = 23     // i key
+	n.BindingsKey = 48    // b key
+
+	// Live tuning
+	n.MoreAccelerationKey = 27 // ] key
+	n.LessAccelerationKey = 26 // [ key
+	n.MoreFrictionKey = 40     // ' key
+	n.LessFrictionKey = 39     // ; key
+
+	// 1-9 on the number row
+	n.GridKeys = [9]uint16{2, 3, 4, 5, 6, 7, 8, 9, 10}
+	n.WarpKeys = n.GridKeys
 	return n
 }
 
@@ -71,17 +71,57 @@ func GetPhoneKeyMapping() KeyMapping {
 	n.ToggleMouseKey = ka.StarKey
 	n.ClickKey = ka.EnterKey
 	n.DragKey = ka.SoftRightKey
+	// The keypad has no spare keys for these
+	n.DoubleClickKey = Unbound
+	n.HoldClickKey = Unbound
+	n.PasteKey = Unbound
+	n.FindCursorKey = Unbound
+	n.ReadoutKey = Unbound
+	n.BindingsKey = Unbound
+	n.RightDragKey = Unbound
+	n.MiddleClickKey = Unbound
+	n.MiddleDragKey = Unbound
+	n.BackKey = Unbound
+	n.ForwardKey = Unbound
 	n.FasterKey = ka.VolumeDownKey
 	n.SlowerKey = ka.VolumeUpKey
+	n.MoreAccelerationKey = Unbound
+	n.LessAccelerationKey = Unbound
+	n.MoreFrictionKey = Unbound
+	n.LessFrictionKey = Unbound
 	n.UpKey = ka.UpKey
 	n.DownKey = ka.DownKey
 	n.LeftKey = ka.LeftKey
 	n.RightKey = ka.RightKey
 	n.ScrollUpKey = ka.SoftLeftKey
 	n.ScrollDownKey = ka.CallKey
-	// Disabled
-	n.ScrollLeftKey = 0
-	n.ScrollRightKey = 0
+	n.PrecisionKey = ka.HashKey
+	n.TurboKey = ka.AsteriskKey
+	n.GridModeKey = ka.MailKey
+	n.GridKeys = [9]uint16{ka.Key1, ka.Key2, ka.Key3, ka.Key4, ka.Key5, ka.Key6, ka.Key7, ka.Key8, ka.Key9}
+	// Outside grid mode the same digits warp to corners, edges and centre
+	n.WarpKeys = n.GridKeys
+	// Horizontal scrolling lives in the scroll layer instead
+	n.ScrollLeftKey = Unbound
+	n.ScrollRightKey = Unbound
+
+	// 0 toggles a layer where 2/8/4/6 scroll up/down/left/right, 5 stops a
+	// fling and 1/3 go back/forward
+	n.ScrollLayerKey = ka.Key0
+	n.ScrollLayer = ScrollKeys{
+		Up:      ka.Key2,
+		Down:    ka.Key8,
+		Left:    ka.Key4,
+		Right:   ka.Key6,
+		Stop:    ka.Key5,
+		Back:    ka.Key1,
+		Forward: ka.Key3,
+	}
+
+	// Long press # for multi-tap text entry, * switches case
+	n.TextModeKey = ka.HashKey
+	n.TextCaseKey = ka.AsteriskKey
+	n.TextKeys = [10]uint16{ka.Key0, ka.Key1, ka.Key2, ka.Key3, ka.Key4, ka.Key5, ka.Key6, ka.Key7, ka.Key8, ka.Key9}
 
 	return n
 }
@@ -1,14 +1,42 @@
 package keymaps
 
+// ScrollKeys are the wheel directions of the scroll layer
+type ScrollKeys struct {
+	Up    uint16
+	Down  uint16
+	Left  uint16
+	Right uint16
+	Stop  uint16 // halts a fling
+
+	// Browser history, handy while reading
+	Back    uint16
+	Forward uint16
+}
+
 // KeyMapping defines keyboard key mappings
 type KeyMapping struct {
 	ExitKey        uint16
 	EnterKey       uint16
 	ToggleMouseKey uint16
 	ClickKey       uint16
+	DoubleClickKey uint16
+	HoldClickKey   uint16
+	PasteKey       uint16 // types the clipboard
 	DragKey        uint16
+	RightDragKey   uint16
+	MiddleClickKey uint16
+	MiddleDragKey  uint16
+	BackKey        uint16
+	ForwardKey     uint16
 	FasterKey      uint16
 	SlowerKey      uint16
+
+	// Live tuning of Acceleration and Friction
+	MoreAccelerationKey uint16
+	LessAccelerationKey uint16
+	MoreFrictionKey     uint16
+	LessFrictionKey     uint16
+
 	UpKey          uint16
 	DownKey        uint16
 	LeftKey        uint16
@@ -17,12 +45,70 @@ type KeyMapping struct {
 	ScrollUpKey    uint16
 	ScrollLeftKey  uint16
 	ScrollRightKey uint16
+	PrecisionKey   uint16
+	TurboKey       uint16
+	GridModeKey    uint16
+	FindCursorKey  uint16     // plays the cursor animation
+	ReadoutKey     uint16     // reports speed, scroll speed and profile
+	BindingsKey    uint16     // reports these bindings
+	GridKeys       [9]uint16  // grid cells 1-9, numbered like a phone keypad
+	WarpKeys       [9]uint16  // corners, edges and centre, laid out like a phone keypad
+	ScrollLayerKey uint16     // toggles ScrollLayer
+	ScrollLayer    ScrollKeys // wheel keys while the scroll layer is on
+	StopScrollKey  uint16     // halts a fling
+	TextModeKey    uint16     // long press toggles multi-tap text entry
+	TextCaseKey    uint16     // toggles upper case in text mode
+	TextKeys       [10]uint16 // digits 0-9 for multi-tap text entry
 	CallKey        uint16
 	LeftSoftKey    uint16
 	RightSoftKey   uint16
 	MessagesKey    uint16
 }
 
+// IsScrollKey reports whether a key drives the wheel, directly or in the scroll layer
+func (k KeyMapping) IsScrollKey(code uint16) bool {
+	if code == Unbound {
+		return false
+	}
+	for _, key := range []uint16{k.ScrollUpKey, k.ScrollDownKey, k.ScrollLeftKey, k.ScrollRightKey, k.ScrollLayerKey,
+		k.ScrollLayer.Up, k.ScrollLayer.Down, k.ScrollLayer.Left, k.ScrollLayer.Right} {
+		if key == code {
+			return true
+		}
+	}
+	return false
+}
+
+// TextDigit returns the digit a key enters in text mode, or -1 if there is none
+func (k KeyMapping) TextDigit(code uint16) int {
+	for digit, key := range k.TextKeys {
+		if key != Unbound && key == code {
+			return digit
+		}
+	}
+	return -1
+}
+
+// GridCell returns the 1-9 grid cell bound to a key code, or 0 if there is none
+func (k KeyMapping) GridCell(code uint16) int {
+	return keypadIndex(k.GridKeys, code)
+}
+
+// WarpTarget returns the 1-9 warp target bound to a key code, or 0 if there is none
+func (k KeyMapping) WarpTarget(code uint16) int {
+	return keypadIndex(k.WarpKeys, code)
+}
+
+// keypadIndex finds code in a 3x3 keypad layout and returns its 1-based position
+func keypadIndex(keys [9]uint16, code uint16) int {
+	for i, key := range keys {
+		if key != Unbound && key == code {
+			return i + 1
+		}
+	}
+	return 0
+}
+
 // KeyMappingProvider provides key mappings for different keyboard types
 type KeyMappingProvider struct {
 	mappings map[int]KeyMapping
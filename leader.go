@@ -0,0 +1,122 @@
+package main
+
+import (
+	"time"
+
+	evdev "github.com/grafov/evdev"
+)
+
+// LeaderSequence runs a command (see Execute) when its keys are pressed
+// after the leader key
+type LeaderSequence struct {
+	Keys    []uint16 `json:"keys"`
+	Command string   `json:"command"`
+}
+
+// Leader implements vim-style leader sequences: after LeaderKey, the next
+// keys are collected until they spell out a sequence, match none, or the
+// timeout passes between two of them. All of these keys are swallowed.
+type Leader struct {
+	app       *Application
+	key       uint16
+	timeout   time.Duration
+	sequences []LeaderSequence
+
+	active  bool
+	typed   []uint16
+	last    time.Time
+	swallow map[uint16]bool
+}
+
+// NewLeader creates the leader middleware from the config
+func NewLeader(app *Application) *Leader {
+	l := &Leader{app: app, swallow: map[uint16]bool{}}
+	l.Configure(app.Config)
+	return l
+}
+
+// Configure takes the leader key and sequences from config and abandons a
+// sequence being typed. Keys already swallowed stay swallowed until released.
+func (l *Leader) Configure(config Config) {
+	l.key = config.LeaderKey
+	l.timeout = config.LeaderTimeout.Duration
+	l.sequences = config.LeaderSequences
+	l.active = false
+}
+
+// Middleware collects leader sequences in and out of mouse mode
+func (l *Leader) Middleware(event *evdev.InputEvent, device *InputDevice) Decision {
+	if event.Type != EvKey {
+		return Continue
+	}
+
+	// Releases and repeats of swallowed keys are swallowed too
+	if event.Value != KeyPressed {
+		if l.swallow[event.Code] {
+			if event.Value == KeyReleased {
+				delete(l.swallow, event.Code)
+			}
+			return Mute
+		}
+		return Continue
+	}
+
+	if l.active && time.Since(l.last) > l.timeout {
+		l.active = false
+	}
+
+	if !l.active {
+		if event.Code != l.key {
+			return Continue
+		}
+		l.active = true
+		l.typed = l.typed[:0]
+		l.last = time.Now()
+		l.swallow[event.Code] = true
+		return Mute
+	}
+
+	l.typed = append(l.typed, event.Code)
+	l.last = time.Now()
+	l.swallow[event.Code] = true
+
+	prefix := false
+	for _, seq := range l.sequences {
+		if !hasPrefix(seq.Keys, l.typed) {
+			continue
+		}
+		if len(seq.Keys) == len(l.typed) {
+			l.active = false
+			l.run(seq.Command)
+			return Mute
+		}
+		prefix = true
+	}
+	if !prefix {
+		l.app.Logger.Debug("No leader sequence for %v\n", l.typed)
+		l.active = false
+	}
+	return Mute
+}
+
+func (l *Leader) run(command string) {
+	reply, err := l.app.executeOnLoop(command)
+	if err != nil {
+		l.app.Logger.Printf("Leader command %q failed: %v", command, err)
+		return
+	}
+	l.app.Logger.Debug("Leader command %q: %s\n", command, reply)
+}
+
+// hasPrefix reports whether keys starts with prefix
+func hasPrefix(keys, prefix []uint16) bool {
+	if len(prefix) > len(keys) {
+		return false
+	}
+	for i, code := range prefix {
+		if keys[i] != code {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// sysLEDs is where the kernel exposes LEDs, including keyboard LEDs as
+// inputN::capslock and the like
+const sysLEDs = "/sys/class/leds"
+
+// ledBlink is how long Blink turns the LEDs off
+const ledBlink = 150 * time.Millisecond
+
+// LEDConfig lights LEDs while mouse mode is on, so it is always clear
+// whether keys will be taken over
+type LEDConfig struct {
+	// Names of LEDs in /sys/class/leds; globs such as "*::capslock" match
+	// LEDs whose number changes between boots
+	Names []string `json:"names"`
+	// Brightness while lit; 0 means each LED's max_brightness
+	Brightness int `json:"brightness"`
+}
+
+// LED is one LED in sysfs
+type LED struct {
+	path string
+	max  int
+}
+
+// OpenLEDs finds the LEDs named in the config
+func OpenLEDs(config LEDConfig) ([]*LED, error) {
+	var leds []*LED
+	for _, name := range config.Names {
+		paths, _ := filepath.Glob(filepath.Join(sysLEDs, name))
+		if len(paths) == 0 {
+			return nil, fmt.Errorf("no LED %s in %s", name, sysLEDs)
+		}
+		for _, path := range paths {
+			max, err := readSysfsInt(filepath.Join(path, "max_brightness"))
+			if err != nil {
+				return nil, err
+			}
+			leds = append(leds, &LED{path: path, max: max})
+		}
+	}
+	return leds, nil
+}
+
+// Brightness reads the current brightness
+func (l *LED) Brightness() (int, error) {
+	return readSysfsInt(filepath.Join(l.path, "brightness"))
+}
+
+// SetBrightness changes the brightness, clamped to max_brightness
+func (l *LED) SetBrightness(value int) error {
+	return writeSysfs(filepath.Join(l.path, "brightness"), int64(min(value, l.max)))
+}
+
+func readSysfsInt(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+	value, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	return value, nil
+}
+
+// ModeIndicator lights LEDs while mouse mode is on and puts back their
+// previous brightness when it goes off
+type ModeIndicator struct {
+	leds       []*LED
+	brightness int
+
+	mu    sync.Mutex
+	saved map[*LED]int
+}
+
+// NewModeIndicator creates an indicator for the configured LEDs
+func NewModeIndicator(config LEDConfig) (*ModeIndicator, error) {
+	leds, err := OpenLEDs(config)
+	if err != nil {
+		return nil, err
+	}
+	return &ModeIndicator{leds: leds, brightness: config.Brightness}, nil
+}
+
+// Set lights or restores the LEDs
+func (m *ModeIndicator) Set(on bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if on == (m.saved != nil) {
+		return nil
+	}
+	var errs []string
+	if on {
+		m.saved = map[*LED]int{}
+		for _, led := range m.leds {
+			if value, err := led.Brightness(); err == nil {
+				m.saved[led] = value
+			}
+			if err := led.SetBrightness(m.lit(led)); err != nil {
+				errs = append(errs, err.Error())
+			}
+		}
+	} else {
+		for led, value := range m.saved {
+			if err := led.SetBrightness(value); err != nil {
+				errs = append(errs, err.Error())
+			}
+		}
+		m.saved = nil
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// Blink turns lit LEDs back to their previous brightness for d, to catch
+// the eye without giving up the mode indication
+func (m *ModeIndicator) Blink(d time.Duration) error {
+	m.mu.Lock()
+	if m.saved == nil {
+		m.mu.Unlock()
+		return nil
+	}
+	for led, value := range m.saved {
+		if err := led.SetBrightness(value); err != nil {
+			m.mu.Unlock()
+			return err
+		}
+	}
+	m.mu.Unlock()
+
+	time.Sleep(d)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.saved == nil {
+		return nil
+	}
+	for _, led := range m.leds {
+		if err := led.SetBrightness(m.lit(led)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Refresh lights LEDs again that something else dimmed while mouse mode is
+// on, such as a keypad backlight timeout
+func (m *ModeIndicator) Refresh() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.saved == nil {
+		return nil
+	}
+	for _, led := range m.leds {
+		value, err := led.Brightness()
+		if err != nil {
+			return err
+		}
+		if value < m.lit(led) {
+			if err := led.SetBrightness(m.lit(led)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// lit is the brightness of an LED while mouse mode is on
+func (m *ModeIndicator) lit(led *LED) int {
+	if m.brightness <= 0 {
+		return led.max
+	}
+	return min(m.brightness, led.max)
+}
+
+// indicateMode follows mouse mode with the LEDs and blinks them for drag
+// reminders until the subscription ends
+func (app *Application) indicateMode(notices <-chan Notice) {
+	for n := range notices {
+		if !app.routed(n, SinkLED) {
+			continue
+		}
+		var err error
+		switch n.Kind {
+		case "mode":
+			err = app.Indicator.Set(n.On)
+		case "dragging":
+			err = app.Indicator.Blink(ledBlink)
+		}
+		if err != nil {
+			app.Logger.Printf("Failed to set mode LEDs: %v", err)
+		}
+	}
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/goFlipMouse/vdev"
+	evdev "github.com/grafov/evdev"
+	lua "github.com/yuin/gopher-lua"
+)
+
+// luaQueueSize bounds the handler calls waiting for the script
+const luaQueueSize = 16
+
+// LuaEngine runs a user script. Keys bound with on_key are taken over by
+// the script: their presses call the handler and never reach the OS.
+//
+//	on_key(KEY_5, function() mouse.click(); sleep(100); mouse.click() end)
+//
+// Handlers run one at a time on their own goroutine, so sleep does not hold
+// up input. The script sees every KEY_* code, plus:
+//
+//	mouse.click([button])  mouse.press(button)  mouse.release(button)
+//	mouse.move(dx, dy)  mouse.scroll(vertical[, horizontal])
+//	mouse.mode()  mouse.toggle()
+//	keyboard.tap(code)  keyboard.type(text)
+//	sleep(ms)  run(command)  log(message)
+//
+// Buttons are "left", "right", "middle" or a BTN_* code.
+type LuaEngine struct {
+	app      *Application
+	state    *lua.LState
+	handlers map[uint16]*lua.LFunction
+	loading  bool
+	calls    chan *lua.LFunction
+}
+
+// LoadLua runs the script at path, which registers its handlers
+func LoadLua(app *Application, path string) (*LuaEngine, error) {
+	e := &LuaEngine{
+		app:      app,
+		state:    lua.NewState(),
+		handlers: map[uint16]*lua.LFunction{},
+		calls:    make(chan *lua.LFunction, luaQueueSize),
+	}
+	e.register()
+
+	// Handlers are only registered while loading; afterwards the event loop reads them
+	e.loading = true
+	err := e.state.DoFile(path)
+	e.loading = false
+	if err != nil {
+		e.state.Close()
+		return nil, fmt.Errorf("failed to run %s: %v", path, err)
+	}
+
+	go e.run()
+	return e, nil
+}
+
+// Middleware hands presses of bound keys to the script and swallows the
+// rest of their events
+func (e *LuaEngine) Middleware(event *evdev.InputEvent, device *InputDevice) Decision {
+	if event.Type != EvKey {
+		return Continue
+	}
+	fn, ok := e.handlers[event.Code]
+	if !ok {
+		return Continue
+	}
+
+	if event.Value == KeyPressed {
+		select {
+		case e.calls <- fn:
+		default:
+			e.app.Logger.Printf("Lua handler for key %d dropped, script is busy", event.Code)
+		}
+	}
+	return Mute
+}
+
+// run calls handlers until Close
+func (e *LuaEngine) run() {
+	defer e.state.Close()
+	for fn := range e.calls {
+		if err := e.state.CallByParam(lua.P{Fn: fn, Protect: true}); err != nil {
+			e.app.Logger.Printf("Lua handler failed: %v", err)
+		}
+	}
+}
+
+// Close stops the script once the queued handlers have run
+func (e *LuaEngine) Close() {
+	close(e.calls)
+}
+
+// register installs the API described on LuaEngine
+func (e *LuaEngine) register() {
+	L := e.state
+	for code, name := range evdev.KEY {
+		if strings.HasPrefix(name, "KEY_") {
+			L.SetGlobal(name, lua.LNumber(code))
+		}
+	}
+
+	L.SetGlobal("on_key", L.NewFunction(e.onKey))
+	L.SetGlobal("sleep", L.NewFunction(func(L *lua.LState) int {
+		time.Sleep(time.Duration(L.CheckInt(1)) * time.Millisecond)
+		return 0
+	}))
+	L.SetGlobal("run", L.NewFunction(func(L *lua.LState) int {
+		reply, err := e.app.Execute(L.CheckString(1))
+		if err != nil {
+			L.Push(lua.LNil)
+			L.Push(lua.LString(err.Error()))
+			return 2
+		}
+		L.Push(lua.LString(reply))
+		return 1
+	}))
+	L.SetGlobal("log", L.NewFunction(func(L *lua.LState) int {
+		e.app.Logger.Printf("Lua: %s", L.CheckString(1))
+		return 0
+	}))
+
+	L.SetGlobal("mouse", L.SetFuncs(L.NewTable(), map[string]lua.LGFunction{
+		"click":   e.mouseClick,
+		"press":   e.mouseButton(true),
+		"release": e.mouseButton(false),
+		"move":    e.mouseMove,
+		"scroll":  e.mouseScroll,
+		"mode":    e.mouseMode,
+		"toggle": func(L *lua.LState) int {
+			e.app.Execute("toggle")
+			return 0
+		},
+	}))
+	L.SetGlobal("keyboard", L.SetFuncs(L.NewTable(), map[string]lua.LGFunction{
+		"tap":  e.keyboardTap,
+		"type": e.keyboardType,
+	}))
+}
+
+// on_key(code, function)
+func (e *LuaEngine) onKey(L *lua.LState) int {
+	code := L.CheckInt(1)
+	fn := L.CheckFunction(2)
+	if !e.loading {
+		L.RaiseError("on_key can only be called while the script loads")
+	}
+	if code <= 0 || code > vdev.KeyMax {
+		L.ArgError(1, "invalid key code")
+	}
+	e.handlers[uint16(code)] = fn
+	return 0
+}
+
+// luaButton reads an optional button argument, left by default
+func luaButton(L *lua.LState, n int) uint16 {
+	switch v := L.Get(n).(type) {
+	case lua.LNumber:
+		if v < vdev.BtnLeft || v > vdev.BtnExtra {
+			L.ArgError(n, "invalid button code")
+		}
+		return uint16(v)
+	case lua.LString:
+		switch v {
+		case "left":
+			return vdev.BtnLeft
+		case "right":
+			return vdev.BtnRight
+		case "middle":
+			return vdev.BtnMiddle
+		}
+		L.ArgError(n, "unknown button "+string(v))
+	}
+	return vdev.BtnLeft
+}
+
+// mouse.click([button])
+func (e *LuaEngine) mouseClick(L *lua.LState) int {
+	button := luaButton(L, 1)
+	mc := e.app.MouseController
+	mc.mu.Lock()
+	mc.setButton(button, true)
+	mc.setButton(button, false)
+	mc.mu.Unlock()
+	return 0
+}
+
+// mouse.press(button) and mouse.release(button)
+func (e *LuaEngine) mouseButton(down bool) lua.LGFunction {
+	return func(L *lua.LState) int {
+		button := luaButton(L, 1)
+		mc := e.app.MouseController
+		mc.mu.Lock()
+		mc.setButton(button, down)
+		mc.mu.Unlock()
+		return 0
+	}
+}
+
+// mouse.move(dx, dy)
+func (e *LuaEngine) mouseMove(L *lua.LState) int {
+	dx, dy := L.CheckInt(1), L.CheckInt(2)
+	mc := e.app.MouseController
+	mc.mu.Lock()
+	mc.MoveBy(int32(dx), int32(dy))
+	mc.mu.Unlock()
+	return 0
+}
+
+// mouse.scroll(vertical[, horizontal])
+func (e *LuaEngine) mouseScroll(L *lua.LState) int {
+	vertical, horizontal := L.CheckInt(1), L.OptInt(2, 0)
+	mc := e.app.MouseController
+	mc.mu.Lock()
+	if vertical != 0 {
+		mc.Mouse.Wheel(false, int32(vertical))
+	}
+	if horizontal != 0 {
+		mc.Mouse.Wheel(true, int32(horizontal))
+	}
+	mc.mu.Unlock()
+	return 0
+}
+
+// mouse.mode() returns whether mouse mode is on
+func (e *LuaEngine) mouseMode(L *lua.LState) int {
+	mc := e.app.MouseController
+	mc.mu.Lock()
+	on := mc.State.MouseMode
+	mc.mu.Unlock()
+	L.Push(lua.LBool(on))
+	return 1
+}
+
+// keyboard.tap(code)
+func (e *LuaEngine) keyboardTap(L *lua.LState) int {
+	code := L.CheckInt(1)
+	if code <= 0 || code > vdev.KeyMax {
+		L.ArgError(1, "invalid key code")
+	}
+	kbd := e.app.VirtualKeyboard
+	e.app.Emitter.Do(func() error {
+		return kbd.KeyPress(code)
+	})
+	return 0
+}
+
+// keyboard.type(text)
+func (e *LuaEngine) keyboardType(L *lua.LState) int {
+	text := L.CheckString(1)
+	kbd := e.app.VirtualKeyboard
+	e.app.Emitter.Do(func() error {
+		return kbd.TypeString(text)
+	})
+	return 0
+}
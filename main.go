@@ -1,17 +1,22 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"log"
 	"math"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"slices"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
-	"github.com/bendahl/uinput"
 	"github.com/goFlipMouse/keymaps"
+	"github.com/goFlipMouse/vdev"
+	"github.com/godbus/dbus/v5"
 	evdev "github.com/grafov/evdev"
 )
 
@@ -20,6 +25,7 @@ const (
 	EvKey         = 0x01
 	EvRel         = 0x02
 	EvMsc         = 0x04
+	EvSw          = 0x05
 	EvSyn         = 0x00
 	KeyPower      = 116
 	KeyHelp       = 138
@@ -33,35 +39,49 @@ const (
 	RelWheel      = 0x08
 	RelHWheel     = 0x06
 	MscScan       = 0x04
+	SwLid         = 0x00
 	SynReport     = 0
 )
 
+// Velocities below which a released pointer or wheel comes to a stop
+const (
+	minMoveVelocity   = 0.1  // pixels per tick
+	minScrollVelocity = 0.01 // notches per tick
+)
+
+// Steps and bounds of the live tuning keys
+const (
+	accelerationStep = 0.05
+	minAcceleration  = 0.05
+	maxAcceleration  = 1
+	frictionStep     = 0.01
+	minFriction      = 0.5
+	maxFriction      = 0.99
+)
+
+// EV_KEY values
+const (
+	KeyReleased = 0
+	KeyPressed  = 1
+	KeyRepeated = 2
+)
+
 // Event processing return values
 const (
 	ChangedToMouse = -2
 	MuteEvent      = 0
 	PassThruEvent  = 1
 	ChangedEvent   = 2
+	// ReplayEvent passes a release through after replaying the key's muted press
+	ReplayEvent = 3
 )
 
-// Config holds application configuration
-type Config struct {
-	LogPath           string
-	DebugMode         bool
-	LongPressDuration time.Duration
-}
-
-// Default configuration
-var defaultConfig = Config{
-	LogPath:           "/cache/goFlipMouse.log",
-	DebugMode:         true,
-	LongPressDuration: 225 * time.Millisecond,
-}
-
 // Logger manages application logging
 type Logger struct {
 	*log.Logger
-	debugMode bool
+	// debugMode can be switched while running, see SetDebug
+	debugMode atomic.Bool
+	quiet     bool
 }
 
 // NewLogger creates a new logger instance
@@ -81,19 +101,37 @@ func NewLogger(config Config) (*Logger, *os.File, error) {
 	}
 
 	logger := &Logger{
-		Logger:    log.New(logFile, "", log.LstdFlags),
-		debugMode: config.DebugMode,
+		Logger: log.New(logFile, "", log.LstdFlags),
+		quiet:  config.Quiet,
 	}
+	logger.debugMode.Store(config.DebugMode)
 
 	return logger, logFile, nil
 }
 
 // Debug logs a message if debug mode is enabled
 func (l *Logger) Debug(format string, v ...interface{}) {
-	if l.debugMode {
+	if l.debugMode.Load() {
+		l.Info(format, v...)
+	}
+}
+
+// SetDebug turns debug messages on or off, e.g. while reproducing a problem
+func (l *Logger) SetDebug(on bool) {
+	l.debugMode.Store(on)
+}
+
+// Debugging reports whether debug messages are logged
+func (l *Logger) Debugging() bool {
+	return l.debugMode.Load()
+}
+
+// Info logs a status message and prints it on the console unless quiet
+func (l *Logger) Info(format string, v ...interface{}) {
+	if !l.quiet {
 		fmt.Printf(format, v...)
-		l.Printf(format, v...)
 	}
+	l.Printf(format, v...)
 }
 
 // Import the KeyMapping and KeyMappingProvider from the keymaps package
@@ -104,6 +142,8 @@ type MouseState struct {
 	VelocityY       float64
 	ScrollVelocityX float64
 	ScrollVelocityY float64
+	MoveRemainderX  float64
+	MoveRemainderY  float64
 	MaxSpeed        float64
 	ScrollMaxSpeed  float64
 	SpeedMulti      float64
@@ -111,11 +151,47 @@ type MouseState struct {
 	Acceleration    float64
 	Friction        float64
 
+	// Scroll has its own, much gentler physics; see AccelerateAndScroll
+	ScrollAcceleration float64
+	ScrollFriction     float64
+	ScrollRemainderX   float64
+	ScrollRemainderY   float64
+	NaturalScroll      bool
+
+	// With Fling on, a released wheel coasts and decays by FlingFriction per tick
+	Fling         bool
+	FlingFriction float64
+
+	// While the precision key is held, MaxSpeed is divided by PrecisionDivisor
+	PrecisionDivisor float64
+	PrecisionActive  bool
+	// While the turbo key is held, MaxSpeed is multiplied by TurboMultiplier
+	TurboMultiplier float64
+	TurboActive     bool
+
+	// PowerSave skips the speed ramp and the glide on a low battery
+	PowerSave bool
+
 	MouseMode bool
 
-	LeftBtnPressed    bool
-	RightBtnPressed   bool
-	DragToggleActive  bool
+	// Estimated cursor position in screen pixels, see MoveBy
+	PosX float64
+	PosY float64
+
+	// Grid click mode: digit keys warp the pointer into GridRect's 3x3 cells
+	GridMode bool
+	GridRect GridRect
+
+	LeftBtnPressed   bool
+	RightBtnPressed  bool
+	MiddleBtnPressed bool
+	SideBtnPressed   bool
+	ExtraBtnPressed  bool
+	// Button held down by a drag toggle, 0 when none
+	DragButton uint16
+	// Pending release of a HoldClick
+	HoldTimer *time.Timer
+
 	UpKeyActive       bool
 	DownKeyActive     bool
 	LeftKeyActive     bool
@@ -124,9 +200,17 @@ type MouseState struct {
 	ScrollDownActive  bool
 	ScrollLeftActive  bool
 	ScrollRightActive bool
+	ScrollLayerActive bool
 
 	ToggleKeyDown     bool
 	ToggleKeyDownTime time.Time
+
+	// Last press of ExitKey, or when it went down, see confirmExit
+	ExitKeyDown     bool
+	ExitKeyDownTime time.Time
+
+	// Last key event seen in mouse mode, see watchStuckKeys
+	LastKeyTime time.Time
 }
 
 // NewMouseState creates a new mouse state with default values
@@ -137,57 +221,81 @@ func NewMouseState() *MouseState {
 		ScrollVelocityX: 0,
 		ScrollVelocityY: 0,
 		MaxSpeed:        4,
-		ScrollMaxSpeed:  30,
+		ScrollMaxSpeed:  0.5,
 		SpeedMulti:      1,
 		ScrollMulti:     1,
 		Acceleration:    0.3,
 		Friction:        0.85,
 
+		ScrollAcceleration: 0.04,
+		ScrollFriction:     0.7,
+		FlingFriction:      0.95,
+
+		PrecisionDivisor: 4,
+		TurboMultiplier:  3,
+
 		MouseMode:       false,
 		LeftBtnPressed:  false,
 		RightBtnPressed: false,
 	}
 }
 
-// MouseController manages mouse movements and actions
+// MouseController manages mouse movements and actions.
+//
+// Its methods expect mu to be held. The event loop takes it around each
+// event and tick; timers, the watchdog and profile switches take it too.
 type MouseController struct {
+	mu sync.Mutex
+
 	State  *MouseState
-	Mouse  uinput.Mouse
+	Screen ScreenConfig
 	Logger *Logger
+
+	// Mouse lives as long as the controller; it only emits while MouseMode is on
+	Mouse PointerOutput
+
+	// Notices receives mode, speed and drag changes; nil for none
+	Notices *NoticeHub
+	// Animation plays when mouse mode turns on and with FindCursorKey
+	Animation AnimationConfig
+	// Profile is the name of the last profile applied
+	Profile string
 }
 
 // NewMouseController creates a new mouse controller
-func NewMouseController(mouse uinput.Mouse, logger *Logger) *MouseController {
-	return &MouseController{
+func NewMouseController(mouse PointerOutput, screen ScreenConfig, logger *Logger) *MouseController {
+	mc := &MouseController{
 		State:  NewMouseState(),
 		Mouse:  mouse,
+		Screen: screen,
 		Logger: logger,
 	}
+	mc.CenterPosition()
+	return mc
 }
 
-func NewVirtualMouse() uinput.Mouse {
-	mouse, err := uinput.CreateMouse("/dev/uinput", []byte("goFlipMouse"))
-	if err != nil {
-		panic(err)
+// notify publishes a state change if anyone listens to this controller
+func (mc *MouseController) notify(n Notice) {
+	if mc.Notices != nil {
+		mc.Notices.Publish(n)
 	}
-	return mouse
 }
 
-func (mc *MouseController) AccelerateVelocity(inputX, inputY float64, maxSpeed float64, velocityX, velocityY float64) (float64, float64) {
+func (mc *MouseController) AccelerateVelocity(inputX, inputY float64, maxSpeed, acceleration, friction float64, velocityX, velocityY float64) (float64, float64) {
 	actualSpeed := maxSpeed
 
 	// Apply acceleration in the input direction
 	if inputX != 0 {
-		velocityX += inputX * mc.State.Acceleration
+		velocityX += inputX * acceleration
 	} else {
 		// Apply friction when no input
-		velocityX *= mc.State.Friction
+		velocityX *= friction
 	}
 
 	if inputY != 0 {
-		velocityY += inputY * mc.State.Acceleration
+		velocityY += inputY * acceleration
 	} else {
-		velocityY *= mc.State.Friction
+		velocityY *= friction
 	}
 
 	// Clamp to maximum speed
@@ -199,72 +307,266 @@ func (mc *MouseController) AccelerateVelocity(inputX, inputY float64, maxSpeed f
 	}
 
 	// Cut off tiny movements
-/*
-	if math.Abs(velocityX) < 0.1 {
-		velocityX = 0
-		}
+	/*
+		if math.Abs(velocityX) < 0.1 {
+			velocityX = 0
+			}
 
-		if math.Abs(velocityY) < 0.1 {
-			velocityY = 0
-	} */
+			if math.Abs(velocityY) < 0.1 {
+				velocityY = 0
+		} */
 
 	return velocityX, velocityY
 }
 
+// EffectiveMaxSpeed returns MaxSpeed adjusted for any held speed modifiers.
+// Precision and turbo compose, so holding both scales by TurboMultiplier/PrecisionDivisor.
+func (mc *MouseController) EffectiveMaxSpeed() float64 {
+	speed := mc.State.MaxSpeed
+	if mc.State.PrecisionActive && mc.State.PrecisionDivisor > 0 {
+		speed /= mc.State.PrecisionDivisor
+	}
+	if mc.State.TurboActive && mc.State.TurboMultiplier > 0 {
+		speed *= mc.State.TurboMultiplier
+	}
+	return speed
+}
+
 // AccelerateAndMove calculates acceleration and applies movement to the mouse
 func (mc *MouseController) AccelerateAndMove(inputX, inputY float64) {
-	mc.State.VelocityX, mc.State.VelocityY = mc.AccelerateVelocity(inputX, inputY, mc.State.MaxSpeed, mc.State.VelocityX, mc.State.VelocityY)
+	maxSpeed := mc.EffectiveMaxSpeed()
+	acceleration, friction := mc.State.Acceleration, mc.State.Friction
+	if mc.State.PowerSave {
+		acceleration, friction = maxSpeed, 0
+	}
+	mc.State.VelocityX, mc.State.VelocityY = mc.AccelerateVelocity(inputX, inputY, maxSpeed, acceleration, friction, mc.State.VelocityX, mc.State.VelocityY)
+
+	// Come to a full stop once released and slowed down, so the loop can go idle
+	if inputX == 0 && math.Abs(mc.State.VelocityX) < minMoveVelocity {
+		mc.State.VelocityX, mc.State.MoveRemainderX = 0, 0
+	}
+	if inputY == 0 && math.Abs(mc.State.VelocityY) < minMoveVelocity {
+		mc.State.VelocityY, mc.State.MoveRemainderY = 0, 0
+	}
 	// Move the mouse if there's any velocity
 	if mc.State.VelocityX != 0 || mc.State.VelocityY != 0 {
-		mc.Mouse.Move(int32(mc.State.VelocityX*mc.State.SpeedMulti), int32(mc.State.VelocityY*mc.State.SpeedMulti))
+		// Carry sub-pixel remainders over so slow and diagonal motion isn't truncated away
+		mc.State.MoveRemainderX += mc.State.VelocityX * mc.State.SpeedMulti
+		mc.State.MoveRemainderY += mc.State.VelocityY * mc.State.SpeedMulti
+		dx, dy := int32(mc.State.MoveRemainderX), int32(mc.State.MoveRemainderY)
+		mc.State.MoveRemainderX -= float64(dx)
+		mc.State.MoveRemainderY -= float64(dy)
+
+		if dx != 0 || dy != 0 {
+			mc.MoveBy(dx, dy)
+		}
 	}
 }
 
-// AccelerateAndScroll can be used for scrolling with acceleration physics
+// AccelerateAndScroll applies scroll physics and emits whole wheel notches.
+// Scroll velocities are in notches per scroll tick; fractions accumulate
+// between ticks so slow scrolling still moves.
 func (mc *MouseController) AccelerateAndScroll(inputX, inputY float64) {
-	// We'll use the input for the Y direction only
-	mc.State.ScrollVelocityX, mc.State.ScrollVelocityY = mc.AccelerateVelocity(inputX, inputY, mc.State.ScrollMaxSpeed, mc.State.ScrollVelocityX, mc.State.ScrollVelocityY)
-	// Scroll if there's any velocity (only vertical)
-	if mc.State.ScrollVelocityY != 0 {
-		mc.Mouse.Wheel(false, int32(mc.State.ScrollVelocityY*mc.State.ScrollMulti))
+	state := mc.State
+	startX := inputX != 0 && state.ScrollVelocityX == 0
+	startY := inputY != 0 && state.ScrollVelocityY == 0
+
+	friction := state.ScrollFriction
+	if state.Fling {
+		friction = state.FlingFriction
+	}
+	state.ScrollVelocityX, state.ScrollVelocityY = mc.AccelerateVelocity(inputX, inputY, state.ScrollMaxSpeed, state.ScrollAcceleration, friction, state.ScrollVelocityX, state.ScrollVelocityY)
+
+	// Stop dead once released and slowed down, so no stray notch trails behind
+	if inputX == 0 && math.Abs(state.ScrollVelocityX) < minScrollVelocity {
+		state.ScrollVelocityX, state.ScrollRemainderX = 0, 0
+	}
+	if inputY == 0 && math.Abs(state.ScrollVelocityY) < minScrollVelocity {
+		state.ScrollVelocityY, state.ScrollRemainderY = 0, 0
+	}
+
+	// A fresh press scrolls one notch right away instead of waiting to accelerate
+	if startX {
+		state.ScrollRemainderX += math.Copysign(1, inputX)
+	}
+	if startY {
+		state.ScrollRemainderY += math.Copysign(1, inputY)
+	}
+
+	state.ScrollRemainderX += state.ScrollVelocityX * state.ScrollMulti
+	state.ScrollRemainderY += state.ScrollVelocityY * state.ScrollMulti
+	notchesX, notchesY := int32(state.ScrollRemainderX), int32(state.ScrollRemainderY)
+	state.ScrollRemainderX -= float64(notchesX)
+	state.ScrollRemainderY -= float64(notchesY)
+
+	if state.NaturalScroll {
+		notchesX, notchesY = -notchesX, -notchesY
 	}
-	if mc.State.ScrollVelocityX != 0 {
-		mc.Mouse.Wheel(true, int32(mc.State.ScrollVelocityX*mc.State.ScrollMulti))
+
+	if notchesY != 0 {
+		mc.Mouse.Wheel(false, notchesY)
+	}
+	if notchesX != 0 {
+		mc.Mouse.Wheel(true, notchesX)
 	}
 }
 
+// SetSpeed sets the maximum movement speed, which is at least 1
+func (mc *MouseController) SetSpeed(speed float64) {
+	mc.State.MaxSpeed = max(speed, 1)
+	mc.notify(Notice{Kind: "speed", Speed: mc.State.MaxSpeed})
+}
+
 // IncreaseSpeed increases the mouse movement speed
 func (mc *MouseController) IncreaseSpeed() {
-	mc.State.MaxSpeed++
-	fmt.Printf("Mouse speed increased to %.1f\n", mc.State.MaxSpeed)
+	mc.SetSpeed(mc.State.MaxSpeed + 1)
 }
 
 // DecreaseSpeed decreases the mouse movement speed
 func (mc *MouseController) DecreaseSpeed() {
-	mc.State.MaxSpeed--
-	if mc.State.MaxSpeed < 1 {
-		mc.State.MaxSpeed = 1
-	}
-	fmt.Printf("Mouse speed decreased to %.1f\n", mc.State.MaxSpeed)
+	mc.SetSpeed(mc.State.MaxSpeed - 1)
+}
+
+// Readout reports the speed, scroll speed and profile, so keypad speed
+// changes can be checked without a screen
+func (mc *MouseController) Readout() {
+	mc.notify(Notice{Kind: "readout", Speed: mc.State.MaxSpeed, Scroll: mc.State.ScrollMaxSpeed, Profile: mc.Profile})
+}
+
+// ShowBindings publishes a "bindings" notice listing km's keys
+func (mc *MouseController) ShowBindings(km keymaps.KeyMapping) {
+	mc.notify(Notice{Kind: "bindings", Message: bindingsText(km)})
+}
+
+// AdjustAcceleration changes how quickly the pointer reaches full speed
+func (mc *MouseController) AdjustAcceleration(delta float64) {
+	mc.State.Acceleration = math.Max(minAcceleration, math.Min(maxAcceleration, mc.State.Acceleration+delta))
+	mc.Logger.Info("Acceleration set to %.2f\n", mc.State.Acceleration)
+}
+
+// AdjustFriction changes how long the pointer glides after a key is released
+func (mc *MouseController) AdjustFriction(delta float64) {
+	mc.State.Friction = math.Max(minFriction, math.Min(maxFriction, mc.State.Friction+delta))
+	mc.Logger.Info("Friction set to %.2f\n", mc.State.Friction)
 }
 
 // ToggleMouseMode toggles mouse mode on/off
 func (mc *MouseController) ToggleMouseMode() {
 	mc.State.MouseMode = !mc.State.MouseMode
 
-	// Wiggle mouse to show it's active
+	// Move the cursor about to show it's active
 	if mc.State.MouseMode {
-mc.Mouse = NewVirtualMouse()
-		mc.Mouse.Move(int32(mc.State.MaxSpeed), 0)
-		time.Sleep(50 * time.Millisecond)
-		mc.Mouse.Move(int32(-mc.State.MaxSpeed), 0)
+		mc.Animate()
 	}
 
 	// Reset button states when toggling
 	if !mc.State.MouseMode {
 		mc.ResetButtons()
-mc.Mouse.Close()
+		// Keys held across the switch must not resume on re-entry
+		mc.clearHeldFlags()
+		mc.State.GridMode = false
+		mc.State.ScrollLayerActive = false
+	}
+
+	mc.notify(Notice{Kind: "mode", On: mc.State.MouseMode})
+}
+
+// MoveTo places the cursor at screen coordinates. Absolute backends do this
+// exactly; relative ones are warped using the configured screen geometry.
+func (mc *MouseController) MoveTo(x, y int32) error {
+	var err error
+	if abs, ok := mc.Mouse.(AbsolutePointerOutput); ok {
+		err = abs.MoveTo(mc.Screen.ToPanel(x, y))
+	} else {
+		err = mc.warpRelative(x, y)
+	}
+	mc.setPosition(float64(x), float64(y))
+	return err
+}
+
+// StopScroll halts any scrolling in progress, including a coasting fling
+func (mc *MouseController) StopScroll() {
+	mc.State.ScrollVelocityX = 0
+	mc.State.ScrollVelocityY = 0
+	mc.State.ScrollRemainderX = 0
+	mc.State.ScrollRemainderY = 0
+}
+
+// ToggleScrollLayer switches the scroll layer on/off
+func (mc *MouseController) ToggleScrollLayer() {
+	mc.State.ScrollLayerActive = !mc.State.ScrollLayerActive
+
+	// Don't leave a wheel spinning if a layer key was held during the switch
+	mc.State.ScrollUpActive = false
+	mc.State.ScrollDownActive = false
+	mc.State.ScrollLeftActive = false
+	mc.State.ScrollRightActive = false
+
+	if mc.State.ScrollLayerActive {
+		mc.Logger.Info("Scroll layer activated\n")
+	} else {
+		mc.Logger.Info("Scroll layer deactivated\n")
+	}
+}
+
+// IsMoving reports whether the pointer still needs movement ticks
+func (mc *MouseController) IsMoving() bool {
+	s := mc.State
+	return s.MouseMode && (s.UpKeyActive || s.DownKeyActive || s.LeftKeyActive || s.RightKeyActive ||
+		s.VelocityX != 0 || s.VelocityY != 0)
+}
+
+// IsScrolling reports whether the wheel still needs scroll ticks
+func (mc *MouseController) IsScrolling() bool {
+	s := mc.State
+	return s.MouseMode && (s.ScrollUpActive || s.ScrollDownActive || s.ScrollLeftActive || s.ScrollRightActive ||
+		s.ScrollVelocityX != 0 || s.ScrollVelocityY != 0)
+}
+
+// ExitMouseMode leaves mouse mode if it is active
+func (mc *MouseController) ExitMouseMode() {
+	if mc.State.MouseMode {
+		mc.ToggleMouseMode()
+	}
+}
+
+// confirmExit returns what becomes of an ExitKey event in mouse mode under
+// Config.ExitConfirm, and whether it leaves mouse mode. Presses that do not
+// confirm are muted, so they neither leave nor reach the phone.
+func (ep *EventProcessor) confirmExit(event *evdev.InputEvent, state *MouseState) (int, bool) {
+	switch ep.Config.ExitConfirm {
+	case ExitConfirmDouble:
+		if event.Value != KeyPressed {
+			return MuteEvent, false
+		}
+		if time.Since(state.ExitKeyDownTime) <= ep.Config.ExitConfirmWindow.Duration {
+			state.ExitKeyDownTime = time.Time{}
+			return PassThruEvent, true
+		}
+		state.ExitKeyDownTime = time.Now()
+		return MuteEvent, false
+	case ExitConfirmLong:
+		if event.Value == KeyPressed || (event.Value == KeyRepeated && !state.ExitKeyDown) {
+			state.ExitKeyDownTime = time.Now()
+			state.ExitKeyDown = true
+			return MuteEvent, false
+		}
+		if event.Value == KeyRepeated {
+			return MuteEvent, false
+		}
+		if !state.ExitKeyDown {
+			return PassThruEvent, false
+		}
+		held := time.Since(state.ExitKeyDownTime)
+		state.ExitKeyDownTime = time.Time{}
+		state.ExitKeyDown = false
+		if held > ep.Config.LongPressDuration.Duration {
+			// The press was muted, so replay the whole key
+			return ReplayEvent, true
+		}
+		return MuteEvent, false
 	}
+	return PassThruEvent, true
 }
 
 // ResetButtons resets button states and releases any pressed buttons
@@ -279,22 +581,129 @@ func (mc *MouseController) ResetButtons() {
 		mc.State.RightBtnPressed = false
 	}
 
-	mc.State.DragToggleActive = false
+	if mc.State.MiddleBtnPressed {
+		mc.Mouse.MiddleRelease()
+		mc.State.MiddleBtnPressed = false
+	}
+
+	if mc.State.SideBtnPressed {
+		mc.setButton(vdev.BtnSide, false)
+	}
+
+	if mc.State.ExtraBtnPressed {
+		mc.setButton(vdev.BtnExtra, false)
+	}
+
+	mc.State.DragButton = 0
+
+	if mc.State.HoldTimer != nil {
+		mc.State.HoldTimer.Stop()
+		mc.State.HoldTimer = nil
+	}
+}
+
+// setButton presses or releases a button and keeps the pressed flags in sync
+func (mc *MouseController) setButton(code uint16, down bool) {
+	if down {
+		mc.Mouse.ButtonPress(code)
+	} else {
+		mc.Mouse.ButtonRelease(code)
+	}
+
+	switch code {
+	case vdev.BtnLeft:
+		mc.State.LeftBtnPressed = down
+	case vdev.BtnRight:
+		mc.State.RightBtnPressed = down
+	case vdev.BtnMiddle:
+		mc.State.MiddleBtnPressed = down
+	case vdev.BtnSide:
+		mc.State.SideBtnPressed = down
+	case vdev.BtnExtra:
+		mc.State.ExtraBtnPressed = down
+	}
+}
+
+// ToggleDrag holds a button down until toggled again. Only one button drags
+// at a time; starting a drag with another button ends the current one.
+func (mc *MouseController) ToggleDrag(code uint16) {
+	current := mc.State.DragButton
+	if current != 0 {
+		mc.setButton(current, false)
+		mc.State.DragButton = 0
+		mc.notify(Notice{Kind: "drag", Code: current})
+		if current == code {
+			return
+		}
+	}
+
+	mc.setButton(code, true)
+	mc.State.DragButton = code
+	mc.notify(Notice{Kind: "drag", On: true, Code: code})
 }
 
-// ToggleDragMode toggles drag mode on/off
+// ToggleDragMode toggles a left button drag on/off
 func (mc *MouseController) ToggleDragMode() {
-	mc.State.DragToggleActive = !mc.State.DragToggleActive
+	mc.ToggleDrag(vdev.BtnLeft)
+}
+
+// HoldButton mirrors a key's press (1) and release (0) onto a pointer button;
+// auto-repeat is ignored
+func (mc *MouseController) HoldButton(code uint16, value int32) {
+	switch value {
+	case KeyPressed:
+		mc.setButton(code, true)
+	case KeyReleased:
+		mc.setButton(code, false)
+	}
+}
 
-	if mc.State.DragToggleActive {
+// DoubleClick clicks the left button twice, delay apart. It does nothing
+// while the left button is held, e.g. during a drag. The second click comes
+// from a timer, so the event loop is not held up meanwhile.
+func (mc *MouseController) DoubleClick(delay time.Duration) {
+	if mc.State.LeftBtnPressed {
+		return
+	}
+	mc.Mouse.LeftPress()
+	mc.Mouse.LeftRelease()
+	time.AfterFunc(delay, func() {
+		mc.mu.Lock()
+		defer mc.mu.Unlock()
+
+		// A drag or leaving mouse mode meanwhile cancels the second click
+		if mc.State.LeftBtnPressed || !mc.State.MouseMode {
+			return
+		}
 		mc.Mouse.LeftPress()
-		mc.State.LeftBtnPressed = true
-		fmt.Println("Drag mode activated")
-	} else {
 		mc.Mouse.LeftRelease()
-		mc.State.LeftBtnPressed = false
-		fmt.Println("Drag mode deactivated")
+	})
+}
+
+// HoldClick presses the left button and releases it after d, which makes
+// Android long presses possible without the drag toggle
+func (mc *MouseController) HoldClick(d time.Duration) {
+	if mc.State.LeftBtnPressed {
+		return
 	}
+	mc.setButton(vdev.BtnLeft, true)
+
+	var timer *time.Timer
+	timer = time.AfterFunc(d, func() {
+		mc.mu.Lock()
+		defer mc.mu.Unlock()
+
+		// ResetButtons or a newer HoldClick took over meanwhile
+		if mc.State.HoldTimer != timer {
+			return
+		}
+		mc.State.HoldTimer = nil
+		// A drag started meanwhile keeps the button
+		if mc.State.LeftBtnPressed && mc.State.DragButton != vdev.BtnLeft {
+			mc.setButton(vdev.BtnLeft, false)
+		}
+	})
+	mc.State.HoldTimer = timer
 }
 
 // ToggleLeftButton toggles left button press/release
@@ -302,11 +711,11 @@ func (mc *MouseController) ToggleLeftButton() {
 	if !mc.State.LeftBtnPressed {
 		mc.Mouse.LeftPress()
 		mc.State.LeftBtnPressed = true
-		fmt.Println("Left button pressed")
+		mc.Logger.Debug("Left button pressed\n")
 	} else {
 		mc.Mouse.LeftRelease()
 		mc.State.LeftBtnPressed = false
-		fmt.Println("Left button released")
+		mc.Logger.Debug("Left button released\n")
 	}
 }
 
@@ -316,6 +725,21 @@ type InputDevice struct {
 	Name         string
 	Path         string
 	KeyboardType int // Refers to keymaps.KBD_TYPE_*
+
+	// Controller is the pointer this device drives; nil means the primary one
+	Controller *MouseController
+
+	// Passive devices are watched for switch events only: never grabbed or forwarded
+	Passive bool
+
+	// Key state changes closer together than this are bounce, see debounce
+	Debounce   time.Duration
+	lastChange map[uint16]keyChange
+
+	// Passed through events of the current frame, sent on SYN_REPORT
+	frame []vdev.Event
+	// Set after SYN_DROPPED until the next SYN_REPORT
+	dropping bool
 }
 
 // EventProcessor processes input events
@@ -324,7 +748,16 @@ type EventProcessor struct {
 	Config             Config
 	KeyMappingProvider *keymaps.KeyMappingProvider
 	Logger             *Logger
-	VirtualKeyboard    uinput.Keyboard
+	VirtualKeyboard    KeyboardOutput
+	Emitter            *Emitter
+
+	// middleware runs before the default processing, see Use
+	middleware []Middleware
+	remapper   *Remapper
+
+	// Modifiers pressed on the virtual keyboard, see ReleaseModifiers
+	modMu         sync.Mutex
+	heldModifiers map[uint16]bool
 }
 
 // NewEventProcessor creates a new event processor
@@ -333,15 +766,44 @@ func NewEventProcessor(
 	config Config,
 	keyMappingProvider *keymaps.KeyMappingProvider,
 	logger *Logger,
-	virtualKeyboard uinput.Keyboard,
+	virtualKeyboard KeyboardOutput,
+	emitter *Emitter,
 ) *EventProcessor {
-	return &EventProcessor{
+	ep := &EventProcessor{
 		MouseController:    mouseController,
 		Config:             config,
 		KeyMappingProvider: keyMappingProvider,
 		Logger:             logger,
 		VirtualKeyboard:    virtualKeyboard,
+		Emitter:            emitter,
+		heldModifiers:      map[uint16]bool{},
+	}
+	ep.Use(ep.debounce)
+	ep.Use(NewMultiTap(ep).Middleware)
+	// Installed without rules too, so pushed configs can add some
+	ep.remapper = NewRemapper(ep, config.remapRules())
+	ep.Use(ep.remapper.Middleware)
+	// After remapping, so remapped keys can be sticky too
+	if len(config.StickyModifiers) > 0 {
+		ep.Use(NewStickyModifiers(ep, config.StickyModifiers).Middleware)
+	}
+	return ep
+}
+
+// controllerFor returns the pointer a device is routed to
+func (ep *EventProcessor) controllerFor(device *InputDevice) *MouseController {
+	if device.Controller != nil {
+		return device.Controller
 	}
+	return ep.MouseController
+}
+
+// inMouseMode reports whether the pointer a device drives is in mouse mode
+func (ep *EventProcessor) inMouseMode(device *InputDevice) bool {
+	mc := ep.controllerFor(device)
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	return mc.State.MouseMode
 }
 
 // ProcessEvent processes a single input event
@@ -350,47 +812,73 @@ func (ep *EventProcessor) ProcessEvent(event *evdev.InputEvent, device *InputDev
 		ep.Logger.Debug("Event: %+v\n", event)
 	}
 
-	// Get the key mapping for this device
+	// Extensions get the first look at every event
+	switch ep.runMiddleware(event, device) {
+	case Mute:
+		return MuteEvent
+	case PassThru:
+		return PassThruEvent
+	}
+
+	// Get the key mapping and pointer for this device
 	km := ep.KeyMappingProvider.GetMapping(device.KeyboardType)
-	mouseState := ep.MouseController.State
+	mc := ep.controllerFor(device)
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	mouseState := mc.State
 
 	// Handle key events
 	if event.Type == EvKey {
 		// Power key handling - exit mouse mode
 		if event.Code == km.ExitKey {
 			ep.Logger.Debug("Power key pressed\n")
-			mouseState.MouseMode = false
-			ep.MouseController.ResetButtons()
-			return PassThruEvent
+			decision, exit := PassThruEvent, true
+			if mouseState.MouseMode {
+				decision, exit = ep.confirmExit(event, mouseState)
+			}
+			if exit {
+				// Through ExitMouseMode, so listeners hear about it
+				mc.ExitMouseMode()
+				mc.ResetButtons()
+				ep.ReleaseModifiers()
+			}
+			return decision
 		}
 
 		// Toggle key for mouse mode
 		if event.Code == km.ToggleMouseKey {
 			ep.Logger.Debug("Toggle key pressed\n")
-			if event.Value == 2 {
-				return MuteEvent
-			}
 
-			// Record start time on key press
-			if event.Value == 1 {
+			// Record start time on key press. A repeat re-arms the key if its
+			// press was missed, e.g. because it happened before startup.
+			if event.Value == KeyPressed || (event.Value == KeyRepeated && !mouseState.ToggleKeyDown) {
 				mouseState.ToggleKeyDownTime = time.Now()
 				mouseState.ToggleKeyDown = true
 				return MuteEvent
 			}
+			if event.Value == KeyRepeated {
+				return MuteEvent
+			}
+
+			// A release without a recorded press is not ours to interpret
+			if !mouseState.ToggleKeyDown {
+				return PassThruEvent
+			}
 
 			// Check for long press
 			diff := time.Since(mouseState.ToggleKeyDownTime)
 			mouseState.ToggleKeyDownTime = time.Time{}
 			mouseState.ToggleKeyDown = false
 
-			if diff > ep.Config.LongPressDuration {
+			if diff > ep.Config.LongPressDuration.Duration {
 				// Long press - toggle mouse mode
 				ep.Logger.Debug("Long press detected\n")
-				ep.MouseController.ToggleMouseMode()
+				mc.ToggleMouseMode()
+				ep.ReleaseModifiers()
 				return MuteEvent
 			} else {
-				// Short press - pass through normal key event
-				return PassThruEvent
+				// Short press - the press was muted, so replay the whole key
+				return ReplayEvent
 			}
 		}
 	}
@@ -400,255 +888,714 @@ func (ep *EventProcessor) ProcessEvent(event *evdev.InputEvent, device *InputDev
 		return PassThruEvent
 	}
 
+	// Only key events can match a binding, and unbound actions (code 0) never match
+	if event.Type != EvKey || event.Code == keymaps.Unbound {
+		return PassThruEvent
+	}
+
 	// Handle mouse mode key events
 	ep.Logger.Debug("Handling event in mouse mode\n")
+	mouseState.LastKeyTime = time.Now()
+
+	// Any other key stops a coasting wheel
+	if event.Value == KeyPressed && mouseState.Fling && !km.IsScrollKey(event.Code) {
+		mc.StopScroll()
+	}
+
+	// Repeat semantics: held actions (directions, scrolling, buttons,
+	// precision, turbo) stay on through repeats and end on release. One-shot
+	// actions fire on the initial press only, except the speed and tuning
+	// keys, which step again on every repeat.
+
+	// Screen reader mode steps or sends arrow keys instead of gliding
+	if ep.Config.ScreenReader.Enabled {
+		if decision, ok := ep.screenReaderKey(event, km, mc); ok {
+			return decision
+		}
+	}
+
+	// The scroll layer turns its keys into wheel directions
+	if mouseState.ScrollLayerActive {
+		switch event.Code {
+		case km.ScrollLayer.Up:
+			mouseState.ScrollUpActive = (event.Value != KeyReleased)
+			return MuteEvent
+		case km.ScrollLayer.Down:
+			mouseState.ScrollDownActive = (event.Value != KeyReleased)
+			return MuteEvent
+		case km.ScrollLayer.Left:
+			mouseState.ScrollLeftActive = (event.Value != KeyReleased)
+			return MuteEvent
+		case km.ScrollLayer.Right:
+			mouseState.ScrollRightActive = (event.Value != KeyReleased)
+			return MuteEvent
+		case km.ScrollLayer.Stop:
+			if event.Value == KeyPressed {
+				mc.StopScroll()
+			}
+			return MuteEvent
+		case km.ScrollLayer.Back:
+			mc.HoldButton(vdev.BtnSide, event.Value)
+			return MuteEvent
+		case km.ScrollLayer.Forward:
+			mc.HoldButton(vdev.BtnExtra, event.Value)
+			return MuteEvent
+		}
+	}
+
+	// In grid mode the digit keys pick screen cells
+	if mouseState.GridMode {
+		if cell := km.GridCell(event.Code); cell > 0 {
+			if event.Value == KeyPressed {
+				mc.SelectGridCell(cell)
+			}
+			return MuteEvent
+		}
+	}
+
+	if target := km.WarpTarget(event.Code); target > 0 {
+		if event.Value == KeyPressed {
+			mc.WarpTo(target)
+		}
+		return MuteEvent
+	}
 
 	switch event.Code {
 	case km.EnterKey:
 		// Convert Enter key to left mouse button
-		if event.Value == 1 {
-			ep.MouseController.Mouse.LeftPress()
+		if event.Value == KeyPressed {
+			mc.Mouse.LeftPress()
 			mouseState.LeftBtnPressed = true
-		} else {
-			ep.MouseController.Mouse.LeftRelease()
+		} else if event.Value == KeyReleased {
+			mc.Mouse.LeftRelease()
 			mouseState.LeftBtnPressed = false
+			// A click ends the current grid selection
+			if mouseState.GridMode {
+				mc.ResetGrid()
+			}
+		}
+		return MuteEvent
+
+	case km.DoubleClickKey:
+		if event.Value == KeyPressed {
+			mc.DoubleClick(ep.Config.DoubleClickDelay.Duration)
+		}
+		return MuteEvent
+
+	case km.PasteKey:
+		if event.Value == KeyPressed {
+			ep.Paste()
+		}
+		return MuteEvent
+
+	case km.HoldClickKey:
+		if event.Value == KeyPressed {
+			mc.HoldClick(ep.Config.HoldClickDuration.Duration)
+		}
+		return MuteEvent
+
+	case km.GridModeKey:
+		if event.Value == KeyPressed {
+			mc.ToggleGridMode()
+		}
+		return MuteEvent
+
+	case km.FindCursorKey:
+		if event.Value == KeyPressed {
+			mc.Animate()
+		}
+		return MuteEvent
+
+	case km.ReadoutKey:
+		if event.Value == KeyPressed {
+			mc.Readout()
+		}
+		return MuteEvent
+
+	case km.BindingsKey:
+		if event.Value == KeyPressed {
+			mc.ShowBindings(km)
+		}
+		return MuteEvent
+
+	case km.ScrollLayerKey:
+		if event.Value == KeyPressed {
+			mc.ToggleScrollLayer()
 		}
 		return MuteEvent
 
+	case km.StopScrollKey:
+		if event.Value == KeyPressed {
+			mc.StopScroll()
+		}
+		return MuteEvent
+
+	case km.MiddleClickKey:
+		// Middle button follows the key
+		if event.Value == KeyPressed {
+			mc.Mouse.MiddlePress()
+			mouseState.MiddleBtnPressed = true
+		} else if event.Value == KeyReleased {
+			mc.Mouse.MiddleRelease()
+			mouseState.MiddleBtnPressed = false
+		}
+		return MuteEvent
+
+	case km.MiddleDragKey:
+		if event.Value == KeyPressed {
+			mc.ToggleDrag(vdev.BtnMiddle)
+		}
+		return MuteEvent
+
+	case km.BackKey:
+		mc.HoldButton(vdev.BtnSide, event.Value)
+		return MuteEvent
+
+	case km.ForwardKey:
+		mc.HoldButton(vdev.BtnExtra, event.Value)
+		return MuteEvent
+
 	case km.FasterKey:
-		if event.Value == 1 {
-			ep.MouseController.IncreaseSpeed()
+		if event.Value != KeyReleased {
+			mc.IncreaseSpeed()
 		}
 		return MuteEvent
 
 	case km.SlowerKey:
-		if event.Value == 1 {
-			ep.MouseController.DecreaseSpeed()
+		if event.Value != KeyReleased {
+			mc.DecreaseSpeed()
+		}
+		return MuteEvent
+
+	case km.MoreAccelerationKey, km.LessAccelerationKey:
+		if event.Value != KeyReleased {
+			if event.Code == km.MoreAccelerationKey {
+				mc.AdjustAcceleration(accelerationStep)
+			} else {
+				mc.AdjustAcceleration(-accelerationStep)
+			}
+		}
+		return MuteEvent
+
+	case km.MoreFrictionKey, km.LessFrictionKey:
+		if event.Value != KeyReleased {
+			if event.Code == km.MoreFrictionKey {
+				mc.AdjustFriction(frictionStep)
+			} else {
+				mc.AdjustFriction(-frictionStep)
+			}
 		}
 		return MuteEvent
 
 	case km.DragKey:
-		if event.Value == 1 {
-			ep.MouseController.ToggleDragMode()
+		if event.Value == KeyPressed {
+			mc.ToggleDragMode()
+		}
+		return MuteEvent
+
+	case km.RightDragKey:
+		if event.Value == KeyPressed {
+			mc.ToggleDrag(vdev.BtnRight)
 		}
 		return MuteEvent
 
+	case km.PrecisionKey:
+		// Hold to slow down
+		mouseState.PrecisionActive = (event.Value != KeyReleased)
+		return MuteEvent
+
+	case km.TurboKey:
+		// Hold to speed up
+		mouseState.TurboActive = (event.Value != KeyReleased)
+		return MuteEvent
+
 	case km.UpKey:
-		mouseState.UpKeyActive = (event.Value != 0)
+		mouseState.UpKeyActive = (event.Value != KeyReleased)
 		return MuteEvent
 
 	case km.DownKey:
-		mouseState.DownKeyActive = (event.Value != 0)
+		mouseState.DownKeyActive = (event.Value != KeyReleased)
 		return MuteEvent
 
 	case km.LeftKey:
-		mouseState.LeftKeyActive = (event.Value != 0)
+		mouseState.LeftKeyActive = (event.Value != KeyReleased)
 		return MuteEvent
 
 	case km.RightKey:
-		mouseState.RightKeyActive = (event.Value != 0)
+		mouseState.RightKeyActive = (event.Value != KeyReleased)
 		return MuteEvent
 
 	case km.ScrollUpKey:
 		// Wheel scrolling functionality
-		mouseState.ScrollUpActive = (event.Value != 0)
+		mouseState.ScrollUpActive = (event.Value != KeyReleased)
 		return MuteEvent
 
 	case km.ScrollDownKey:
 		// Wheel scrolling functionality
-		mouseState.ScrollDownActive = (event.Value != 0)
+		mouseState.ScrollDownActive = (event.Value != KeyReleased)
 		return MuteEvent
 
 	case km.ScrollRightKey:
 		// Horizontal wheel scrolling
-		mouseState.ScrollRightActive = (event.Value != 0)
+		mouseState.ScrollRightActive = (event.Value != KeyReleased)
 		return MuteEvent
 
 	case km.ScrollLeftKey:
 		// Horizontal wheel scrolling
-		mouseState.ScrollLeftActive = (event.Value != 0)
+		mouseState.ScrollLeftActive = (event.Value != KeyReleased)
 		return MuteEvent
 	}
-return PassThruEvent
+	return PassThruEvent
 }
 
 // DeviceManager manages input devices
 type DeviceManager struct {
-	Devices         []*InputDevice
-	EventProcessor  *EventProcessor
-	MouseController *MouseController
-	Logger          *Logger
+	Devices        []*InputDevice
+	EventProcessor *EventProcessor
+	Config         Config
+	Logger         *Logger
+
+	// Controllers holds every virtual pointer; the first one is the primary
+	Controllers []*MouseController
+	// Routes maps an input device name or path to a non-primary pointer
+	Routes map[string]*MouseController
+
+	// mu guards Devices, which changes when devices are rescanned or go away
+	mu sync.Mutex
+
+	// lidClosed is written by the event loop under mu, see setLidClosed
+	lidClosed atomic.Bool
+
+	// Movement and scroll tick rates in Hz, adjustable per profile, and a
+	// cap on both while saving power (0 for none); guarded by mu
+	moveRate   int
+	scrollRate int
+	rateCap    int
+
+	// loop reads every device and runs the ticks, see eventloop.go
+	loop eventLoop
+
+	// Trace records every event and its decision, see -record
+	Trace *TraceRecorder
+
+	// Notices receives the input events read from grabbed devices and
+	// device errors
+	Notices *NoticeHub
 }
 
 // NewDeviceManager creates a new device manager
 func NewDeviceManager(
 	eventProcessor *EventProcessor,
-	mouseController *MouseController,
+	controllers []*MouseController,
+	config Config,
 	logger *Logger,
 ) *DeviceManager {
 	return &DeviceManager{
-		Devices:         []*InputDevice{},
-		EventProcessor:  eventProcessor,
-		MouseController: mouseController,
-		Logger:          logger,
+		Devices:        []*InputDevice{},
+		EventProcessor: eventProcessor,
+		Config:         config,
+		Logger:         logger,
+		Controllers:    controllers,
+		Routes:         map[string]*MouseController{},
+		moveRate:       config.MoveRate,
+		scrollRate:     config.ScrollRate,
+		loop:           eventLoop{calls: make(chan func(), 1)},
 	}
 }
 
-// FindInputDevices locates and initializes input devices
-func (dm *DeviceManager) FindInputDevices() error {
-	// Define devices we're looking for
-	wantedDevs := []string{"mtk-kpd", "matrix-keypad", "AT Translated Set 2 keyboard"}
+// notify publishes a device change if anyone listens
+func (dm *DeviceManager) notify(n Notice) {
+	if dm.Notices != nil {
+		dm.Notices.Publish(n)
+	}
+}
+
+// reportError logs an error that stops keys from working and passes it on
+// to the feedback sinks, since on a phone the log is out of sight
+func (dm *DeviceManager) reportError(format string, v ...interface{}) {
+	msg := fmt.Sprintf(format, v...)
+	dm.Logger.Printf("%s", msg)
+	dm.notify(Notice{Kind: "error", Message: msg})
+}
+
+// tickInterval converts a tick rate in Hz into a timer period
+func tickInterval(hz int) time.Duration {
+	if hz <= 0 {
+		hz = 1
+	}
+	return time.Second / time.Duration(hz)
+}
+
+// SetTickRates changes the movement and scroll tick rates; zero keeps the current rate
+func (dm *DeviceManager) SetTickRates(moveHz, scrollHz int) {
+	dm.mu.Lock()
+	if moveHz > 0 {
+		dm.moveRate = moveHz
+	}
+	if scrollHz > 0 {
+		dm.scrollRate = scrollHz
+	}
+	dm.mu.Unlock()
+
+	// Running timers pick up the new rates
+	dm.Wake()
+}
+
+// SetRateCap limits both tick rates to hz, or lifts the limit for 0
+func (dm *DeviceManager) SetRateCap(hz int) {
+	dm.mu.Lock()
+	dm.rateCap = hz
+	dm.mu.Unlock()
+	dm.Wake()
+}
+
+// RouteDevice sends events from the named (or pathed) input device to a specific pointer
+func (dm *DeviceManager) RouteDevice(nameOrPath string, controller *MouseController) {
+	dm.Routes[nameOrPath] = controller
+}
+
+// routeFor returns the pointer an input device should drive
+func (dm *DeviceManager) routeFor(name, path string) *MouseController {
+	if mc, ok := dm.Routes[path]; ok {
+		return mc
+	}
+	if mc, ok := dm.Routes[name]; ok {
+		return mc
+	}
+	return dm.Controllers[0]
+}
+
+// FindInputDevices locates and initializes input devices
+func (dm *DeviceManager) FindInputDevices() error {
+	found, err := dm.discoverDevices()
+	if err != nil {
+		return err
+	}
+
+	dm.mu.Lock()
+	dm.Devices = append(dm.Devices, found...)
+	count := len(dm.Devices)
+	dm.mu.Unlock()
+
+	if count == 0 {
+		return fmt.Errorf("no suitable input devices found")
+	}
+	return nil
+}
+
+// builtinDevices are the keypads used without being listed in Config.Devices
+var builtinDevices = []string{"mtk-kpd", "matrix-keypad", "AT Translated Set 2 keyboard"}
+
+// discoverDevices opens every wanted input device that is not attached yet
+func (dm *DeviceManager) discoverDevices() ([]*InputDevice, error) {
+	// Define devices we're looking for
+	wantedDevs := append(slices.Clone(builtinDevices), dm.Config.Devices...)
+	// Devices routed to an extra pointer are wanted as well
+	for nameOrPath := range dm.Routes {
+		wantedDevs = append(wantedDevs, nameOrPath)
+	}
 
 	// Find all input devices
 	devFiles, err := filepath.Glob("/dev/input/event*")
 	if err != nil {
-		return fmt.Errorf("failed to list input devices: %v", err)
+		return nil, fmt.Errorf("failed to list input devices: %v", err)
 	}
 
+	found := []*InputDevice{}
 	for _, path := range devFiles {
+		if dm.isAttached(path) {
+			continue
+		}
+
 		dev, err := evdev.Open(path)
 		if err != nil {
 			continue
 		}
 
 		// Check if it's a device we want
-		for _, wanted := range wantedDevs {
-			if dev.Name == wanted {
-				keyboardType := keymaps.GetKeyboardType(dev.Name)
-
-				dm.Devices = append(dm.Devices, &InputDevice{
-					Device:       dev,
-					Name:         dev.Name,
-					Path:         path,
-					KeyboardType: keyboardType,
-				})
-				break
+		wanted := matchesDevice(dev.Name, path, wantedDevs)
+		passive := !wanted && matchesDevice(dev.Name, path, dm.Config.LidDevices)
+		if !wanted && !passive {
+			dev.File.Close()
+			continue
+		}
+
+		found = append(found, &InputDevice{
+			Device:       dev,
+			Name:         dev.Name,
+			Path:         path,
+			KeyboardType: keymaps.GetKeyboardType(dev.Name),
+			Controller:   dm.routeFor(dev.Name, path),
+			Passive:      passive,
+			Debounce:     dm.Config.debounceFor(dev.Name, path),
+		})
+	}
+
+	return found, nil
+}
+
+// forwardedCapabilities collects the misc, switch and LED codes reported by
+// the devices whose events reach the virtual keyboard, which advertises no
+// others. Lid devices are only watched, so they do not count. A device
+// that turns up later can only pass on the codes found here.
+func forwardedCapabilities(config Config) vdev.Capabilities {
+	wanted := append(slices.Clone(builtinDevices), config.Devices...)
+	for _, extra := range config.ExtraPointers {
+		wanted = append(wanted, extra.Devices...)
+	}
+
+	caps := vdev.Capabilities{}
+	paths, _ := filepath.Glob("/dev/input/event*")
+	for _, path := range paths {
+		dev, err := evdev.Open(path)
+		if err != nil {
+			continue
+		}
+		if matchesDevice(dev.Name, path, wanted) {
+			for capType, codes := range dev.Capabilities {
+				evType := uint16(capType.Type)
+				if evType != vdev.EvMsc && evType != vdev.EvSw && evType != vdev.EvLed {
+					continue
+				}
+				for _, code := range codes {
+					if !slices.Contains(caps[evType], uint16(code.Code)) {
+						caps[evType] = append(caps[evType], uint16(code.Code))
+					}
+				}
 			}
 		}
+		dev.File.Close()
 	}
+	return caps
+}
 
-	if len(dm.Devices) == 0 {
-		return fmt.Errorf("no suitable input devices found")
+// matchesDevice reports whether a device name or path appears in a list
+func matchesDevice(name, path string, list []string) bool {
+	for _, entry := range list {
+		if name == entry || path == entry {
+			return true
+		}
 	}
-	return nil
+	return false
+}
+
+// isAttached reports whether a device node is already being monitored
+func (dm *DeviceManager) isAttached(path string) bool {
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
+
+	for _, dev := range dm.Devices {
+		if dev.Path == path {
+			return true
+		}
+	}
+	return false
 }
 
 // StartDeviceMonitoring starts monitoring all devices
 func (dm *DeviceManager) StartDeviceMonitoring() error {
-	for i, dev := range dm.Devices {
-		fmt.Printf("Monitoring device %d: %s\n - %s\n", i, dev.Name, dev.Path)
+	if err := dm.openLoop(); err != nil {
+		return err
+	}
 
-		err := dev.Device.Grab()
-		if err != nil {
-			return fmt.Errorf("failed to grab device %s: %v", dev.Name, err)
+	dm.mu.Lock()
+	devices := append([]*InputDevice{}, dm.Devices...)
+	dm.mu.Unlock()
+
+	for i, dev := range devices {
+		dm.Logger.Info("Monitoring device %d: %s\n - %s\n", i, dev.Name, dev.Path)
+
+		if !dev.Passive {
+			err := dev.Device.Grab()
+			if err != nil {
+				return fmt.Errorf("failed to grab device %s: %v", dev.Name, err)
+			}
 		}
 
-		// Start a goroutine for each device to handle input events
-		go dm.processDeviceEvents(dev)
+		if err := dm.watch(dev); err != nil {
+			return err
+		}
 	}
 
-	// Start the movement goroutine
-	go dm.processMovement()
-	go dm.processScroll()
+	go dm.watchStuckKeys()
 
 	return nil
 }
 
-// processDeviceEvents continuously processes events from a device
-func (dm *DeviceManager) processDeviceEvents(device *InputDevice) {
-	for {
-		// Read the next event
-		event, err := device.Device.ReadOne()
-		if err != nil {
-			dm.Logger.Printf("Error reading from %s: %v", device.Name, err)
-			continue
-		}
+// Rescan re-runs device discovery and starts monitoring any device that
+// appeared since startup. It returns the number of newly attached devices.
+func (dm *DeviceManager) Rescan() (int, error) {
+	found, err := dm.discoverDevices()
+	if err != nil {
+		return 0, err
+	}
 
-		// Process the event
-		result := dm.EventProcessor.ProcessEvent(event, device)
+	attached := 0
+	for _, dev := range found {
+		dm.mu.Lock()
+		// While the flip is closed the OS keeps new keypads too, see setLidClosed
+		if !dev.Passive && !dm.grabsPaused() {
+			if err := dev.Device.Grab(); err != nil {
+				dm.mu.Unlock()
+				dm.reportError("Failed to grab device %s: %v", dev.Name, err)
+				dev.Device.File.Close()
+				continue
+			}
+		}
+		dm.Devices = append(dm.Devices, dev)
+		dm.mu.Unlock()
 
-		// Handle event result
-		if result == PassThruEvent {
-			dm.EventProcessor.VirtualKeyboard.SendEvent(event.Time, event.Type, event.Code, event.Value)
-		} else {
-			dm.Logger.Debug("Intercepted event. Result: %d\n", result)
+		dm.Logger.Info("Monitoring new device: %s\n - %s\n", dev.Name, dev.Path)
+		if err := dm.watch(dev); err != nil {
+			dm.reportError("Failed to watch device %s: %v", dev.Name, err)
+			dev.Device.File.Close()
+			continue
 		}
+		attached++
 	}
+
+	return attached, nil
 }
 
-// processMovement handles continuous mouse movement based on key states
-func (dm *DeviceManager) processMovement() {
-	ticker := time.NewTicker((1000 / 60) * time.Millisecond) // ~60fps
-	defer ticker.Stop()
+// grabsPaused reports whether the keypads are handed back to the OS because
+// the flip is closed
+func (dm *DeviceManager) grabsPaused() bool {
+	return dm.Config.PauseGrabsWhenClosed && dm.lidClosed.Load()
+}
 
-	for range ticker.C {
-		mouseState := dm.MouseController.State
+// detach forgets a device that can no longer be read, so a later rescan can pick it up again
+func (dm *DeviceManager) detach(device *InputDevice) {
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
 
-		if !mouseState.MouseMode {
-			// Reset velocities when not in mouse mode
-			mouseState.VelocityX = 0
-			mouseState.VelocityY = 0
-			continue
+	for i, dev := range dm.Devices {
+		if dev == device {
+			dm.Devices = append(dm.Devices[:i], dm.Devices[i+1:]...)
+			break
 		}
+	}
+	device.Device.File.Close()
+}
 
-		// Calculate input direction
-		moveInputX := float64(0)
-		moveInputY := float64(0)
+// setLidClosed reacts to the flip being closed or opened. Closing leaves
+// mouse mode on every pointer and, if configured, hands the keypad back to
+// the OS until the flip is opened again.
+func (dm *DeviceManager) setLidClosed(closed bool) {
+	if closed == dm.lidClosed.Load() {
+		return
+	}
 
-		if mouseState.LeftKeyActive {
-			moveInputX -= mouseState.MaxSpeed
+	if closed {
+		dm.Logger.Debug("Lid closed\n")
+		for _, mc := range dm.Controllers {
+			mc.mu.Lock()
+			mc.ExitMouseMode()
+			mc.mu.Unlock()
 		}
-		if mouseState.RightKeyActive {
-			moveInputX += mouseState.MaxSpeed
+		dm.EventProcessor.ReleaseModifiers()
+	} else {
+		dm.Logger.Debug("Lid opened\n")
+	}
+
+	if !dm.Config.PauseGrabsWhenClosed {
+		dm.lidClosed.Store(closed)
+		return
+	}
+
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
+	// Changed with the grabs, so Rescan grabs a new device only if the others are
+	dm.lidClosed.Store(closed)
+	for _, dev := range dm.Devices {
+		if dev.Passive {
+			continue
 		}
-		if mouseState.UpKeyActive {
-			moveInputY -= mouseState.MaxSpeed
+		var err error
+		if closed {
+			err = dev.Device.Release()
+		} else {
+			err = dev.Device.Grab()
 		}
-		if mouseState.DownKeyActive {
-			moveInputY += mouseState.MaxSpeed
+		if err != nil {
+			dm.reportError("Failed to change grab on %s: %v", dev.Name, err)
 		}
-
-		dm.MouseController.AccelerateAndMove(moveInputX, moveInputY)
 	}
 }
 
-func (dm *DeviceManager) processScroll() {
-	ticker := time.NewTicker((1000 / 10) * time.Millisecond) // ~10fps
-	defer ticker.Stop()
+// moveController advances one pointer by a single movement tick
+func (dm *DeviceManager) moveController(mc *MouseController) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	mouseState := mc.State
 
-	for range ticker.C {
-		// check if ticker even or odd
-		mouseState := dm.MouseController.State
+	if !mouseState.MouseMode {
+		// Reset velocities when not in mouse mode
+		mouseState.VelocityX = 0
+		mouseState.VelocityY = 0
+		mouseState.MoveRemainderX = 0
+		mouseState.MoveRemainderY = 0
+		return
+	}
 
-		if !mouseState.MouseMode {
-			// Reset velocities when not in mouse mode
-			mouseState.ScrollVelocityX = 0
-			mouseState.ScrollVelocityY = 0
-			continue
-		}
+	// Calculate input direction
+	moveInputX := float64(0)
+	moveInputY := float64(0)
+	speed := mc.EffectiveMaxSpeed()
 
-		// Calculate input direction
-		scrollInputX := float64(0)
-		scrollInputY := float64(0)
-		if mouseState.ScrollLeftActive {
-			scrollInputX += mouseState.ScrollMaxSpeed
-		}
-		if mouseState.ScrollRightActive {
-			scrollInputX -= mouseState.ScrollMaxSpeed
-		}
-		if mouseState.ScrollUpActive {
-			scrollInputY += mouseState.ScrollMaxSpeed
-		}
-		if mouseState.ScrollDownActive {
-			scrollInputY -= mouseState.ScrollMaxSpeed
-		}
+	if mouseState.LeftKeyActive {
+		moveInputX -= speed
+	}
+	if mouseState.RightKeyActive {
+		moveInputX += speed
+	}
+	if mouseState.UpKeyActive {
+		moveInputY -= speed
+	}
+	if mouseState.DownKeyActive {
+		moveInputY += speed
+	}
 
-		// Currently too fast, not fine enough input
-		// dm.MouseController.AccelerateAndScroll(scrollInputX, scrollInputY)
+	// Normalize so diagonals are no faster than a single direction
+	if length := math.Hypot(moveInputX, moveInputY); length > speed {
+		moveInputX *= speed / length
+		moveInputY *= speed / length
+	}
+
+	mc.AccelerateAndMove(moveInputX, moveInputY)
+}
 
-		dm.MouseController.Mouse.Wheel(false, int32(scrollInputY*mouseState.ScrollMulti))
-		dm.MouseController.Mouse.Wheel(true, int32(scrollInputX*mouseState.ScrollMulti))
+// scrollController advances one pointer by a single scroll tick
+func (dm *DeviceManager) scrollController(mc *MouseController) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	mouseState := mc.State
+
+	if !mouseState.MouseMode {
+		// Reset velocities when not in mouse mode
+		mouseState.ScrollVelocityX = 0
+		mouseState.ScrollVelocityY = 0
+		mouseState.ScrollRemainderX = 0
+		mouseState.ScrollRemainderY = 0
+		return
+	}
+
+	// Calculate input direction
+	scrollInputX := float64(0)
+	scrollInputY := float64(0)
+	if mouseState.ScrollLeftActive {
+		scrollInputX += mouseState.ScrollMaxSpeed
+	}
+	if mouseState.ScrollRightActive {
+		scrollInputX -= mouseState.ScrollMaxSpeed
 	}
+	if mouseState.ScrollUpActive {
+		scrollInputY += mouseState.ScrollMaxSpeed
+	}
+	if mouseState.ScrollDownActive {
+		scrollInputY -= mouseState.ScrollMaxSpeed
+	}
+
+	mc.AccelerateAndScroll(scrollInputX, scrollInputY)
 }
 
 // Application is the main application structure
@@ -658,39 +1605,126 @@ type Application struct {
 	MouseController *MouseController
 	EventProcessor  *EventProcessor
 	DeviceManager   *DeviceManager
-	VirtualMouse    uinput.Mouse
-	VirtualKeyboard uinput.Keyboard
+	VirtualMouse    PointerOutput
+	VirtualKeyboard KeyboardOutput
+	Emitter         *Emitter
 	LogFile         *os.File
+	InstanceLock    *InstanceLock
+	Control         *ControlServer
+	FIFO            *CommandFIFO
+	DBus            *dbus.Conn
+	HTTP            *HTTPServer
+	Overlay         *OverlayServer
+	GRPC            *GRPCServer
+	MQTT            *MQTTClient
+	NetServer       *NetServer
+	Lua             *LuaEngine
+	Beeper          *Beeper
+	Vibrator        *Vibrator
+	Indicator       *ModeIndicator
+	KeyBacklight    *ModeIndicator
+	OSD             *OSD
+	Notifier        *Notifier
+	Speaker         *Speaker
+	TUI             *TUI
+	Plugins         []*Plugin
+	Messages        *Messages
+
+	// Notices publishes state changes and input to API subscribers
+	Notices *NoticeHub
+	Started time.Time
+
+	// mu guards ActiveProfile, which the app watcher and commands change,
+	// and the Config settings a pushed config replaces
+	mu            sync.Mutex
+	ActiveProfile string
+
+	leader    *Leader
+	watchApps sync.Once
+
+	// ExtraControllers drive the additional pointers from Config.ExtraPointers
+	ExtraControllers []*MouseController
 }
 
 // NewApplication creates and initializes the application
-func NewApplication() (*Application, error) {
-	// Use default config
-	config := defaultConfig
-
+func NewApplication(config Config) (*Application, error) {
 	// Initialize logger
 	logger, logFile, err := NewLogger(config)
 	if err != nil {
 		return nil, fmt.Errorf("failed to setup logging: %v", err)
 	}
 
+	keyMappingProvider := keymaps.CreateDefaultKeyMappingProvider()
+	if config.Keymap != "" {
+		if err := LoadKeymap(keyMappingProvider, config.Keymap); err != nil {
+			logFile.Close()
+			return nil, err
+		}
+	}
+
+	notices := NewNoticeHub()
+
+	// All output to the virtual devices goes through one ordered emitter
+	emitter := NewEmitter(logger)
+	emitter.Notices = notices
+	go emitter.Run()
+
 	// Create virtual devices
-	virtualMouse, err := uinput.CreateMouse("/dev/uinput", []byte("goFlipMouse"))
+	rawMouse, err := NewPointerOutput(config.PointerBackend, config.VirtualMouse, config.Screen, config.NetPeer)
 	if err != nil {
 		logFile.Close()
 		return nil, fmt.Errorf("failed to create virtual mouse: %v", err)
 	}
+	virtualMouse := emitter.Mouse(TapPointer(rawMouse, config.VirtualMouse.Name, notices))
 
-	virtualKeyboard, err := uinput.CreateKeyboard("/dev/uinput", []byte("goFlipKeyboard"))
+	layout := config.Layout()
+	rawKeyboard, err := NewKeyboardOutput(config.KeyboardBackend, config.VirtualKeyboard, layout, forwardedCapabilities(config))
 	if err != nil {
 		virtualMouse.Close()
 		logFile.Close()
 		return nil, fmt.Errorf("failed to create virtual keyboard: %v", err)
 	}
+	virtualKeyboard := TapKeyboard(rawKeyboard, config.VirtualKeyboard.Name, layout, notices)
 
-	// Create components
-	mouseController := NewMouseController(virtualMouse, logger)
-	keyMappingProvider := keymaps.CreateDefaultKeyMappingProvider()
+	mice := []PointerOutput{virtualMouse}
+	for _, extra := range config.ExtraPointers {
+		extraMouse, err := NewPointerOutput(config.PointerBackend, extra.Mouse, config.Screen, config.NetPeer)
+		if err != nil {
+			for _, mouse := range mice {
+				mouse.Close()
+			}
+			virtualKeyboard.Close()
+			logFile.Close()
+			return nil, fmt.Errorf("failed to create virtual mouse %s: %v", extra.Mouse.Name, err)
+		}
+		mice = append(mice, emitter.Mouse(TapPointer(extraMouse, extra.Mouse.Name, notices)))
+	}
+
+	app := assemble(config, logger, keyMappingProvider, emitter, notices, mice, virtualKeyboard)
+	app.LogFile = logFile
+	return app, nil
+}
+
+// assemble builds the application around virtual devices that already
+// exist: mice[0] is the primary pointer, the rest belong to
+// config.ExtraPointers in order
+func assemble(
+	config Config,
+	logger *Logger,
+	keyMappingProvider *keymaps.KeyMappingProvider,
+	emitter *Emitter,
+	notices *NoticeHub,
+	mice []PointerOutput,
+	virtualKeyboard KeyboardOutput,
+) *Application {
+	var controllers []*MouseController
+	for _, mouse := range mice {
+		mc := NewMouseController(mouse, config.Screen, logger)
+		mc.Animation = config.CursorAnimation
+		controllers = append(controllers, mc)
+	}
+	mouseController := controllers[0]
+	mouseController.Notices = notices
 
 	eventProcessor := NewEventProcessor(
 		mouseController,
@@ -698,24 +1732,49 @@ func NewApplication() (*Application, error) {
 		keyMappingProvider,
 		logger,
 		virtualKeyboard,
+		emitter,
 	)
 
 	deviceManager := NewDeviceManager(
 		eventProcessor,
-		mouseController,
+		controllers,
+		config,
 		logger,
 	)
+	for i, extra := range config.ExtraPointers {
+		for _, dev := range extra.Devices {
+			deviceManager.RouteDevice(dev, controllers[i+1])
+		}
+	}
+
+	deviceManager.Notices = notices
+
+	locale := config.Locale
+	if locale == "" {
+		locale = systemLocale()
+	}
 
-	return &Application{
+	app := &Application{
 		Config:          config,
 		Logger:          logger,
 		MouseController: mouseController,
 		EventProcessor:  eventProcessor,
 		DeviceManager:   deviceManager,
-		VirtualMouse:    virtualMouse,
+		VirtualMouse:    mice[0],
 		VirtualKeyboard: virtualKeyboard,
-		LogFile:         logFile,
-	}, nil
+		Emitter:         emitter,
+		Notices:         notices,
+		Messages:        NewMessages(locale, config.Messages),
+		Started:         time.Now(),
+
+		ExtraControllers: controllers[1:],
+	}
+
+	// Leader sequences run commands, which need the application. Without a
+	// leader key it never triggers, but a pushed config may set one.
+	app.leader = NewLeader(app)
+	eventProcessor.Use(app.leader.Middleware)
+	return app
 }
 
 // Setup initializes the application
@@ -725,7 +1784,224 @@ func (app *Application) Setup() error {
 		return err
 	}
 
-	fmt.Printf("Found %d input devices\n", len(app.DeviceManager.Devices))
+	app.Logger.Info("Found %d input devices\n", len(app.DeviceManager.Devices))
+
+	// Before the first profile is applied, so it is printed
+	notices, _ := app.Notices.SubscribeChanges()
+	go app.logChanges(notices)
+
+	if app.Config.Profile != "" {
+		if err := app.SetProfile(app.Config.Profile); err != nil {
+			return err
+		}
+	}
+
+	if len(app.Config.AppProfiles) > 0 {
+		app.watchApps.Do(func() { go app.watchForegroundApp() })
+	}
+
+	if app.Config.DragReminder.Duration > 0 {
+		go app.remindDrag(app.Config.DragReminder.Duration)
+	}
+
+	if app.Config.ScreenOff.Enabled {
+		probe, err := newDisplayProbe(app.Config.ScreenOff.Source, app.Config.Android)
+		if err != nil {
+			return err
+		}
+		go app.watchScreen(probe)
+	}
+
+	if app.Config.PowerSave.Enabled {
+		battery, err := OpenBattery(app.Config.PowerSave.Battery)
+		if err != nil {
+			return err
+		}
+		go app.watchBattery(battery)
+	}
+
+	if app.Config.LuaScript != "" {
+		engine, err := LoadLua(app, app.Config.LuaScript)
+		if err != nil {
+			return err
+		}
+		app.Lua = engine
+		app.EventProcessor.Use(engine.Middleware)
+	}
+
+	for _, config := range app.Config.Plugins {
+		plugin, err := StartPlugin(app, config)
+		if err != nil {
+			return err
+		}
+		app.Plugins = append(app.Plugins, plugin)
+		app.EventProcessor.Use(plugin.Middleware)
+	}
+
+	if app.Config.StatusPath != "" {
+		notices, _ := app.Notices.SubscribeChanges()
+		go app.writeStatusFiles(notices)
+	}
+
+	if app.Config.Beep.Enabled {
+		beeper, err := NewBeeper(app.Config.Beep, app.Config.Android, filepath.Dir(app.Config.LogPath))
+		if err != nil {
+			return err
+		}
+		app.Beeper = beeper
+		notices, _ := app.Notices.SubscribeChanges()
+		go app.beepChanges(notices)
+	}
+
+	if app.Config.Vibrate.Enabled {
+		vibrator, err := OpenVibrator(app.Config.Vibrate)
+		if err != nil {
+			return err
+		}
+		app.Vibrator = vibrator
+		notices, _ := app.Notices.SubscribeChanges()
+		go app.vibrateChanges(notices)
+	}
+
+	if len(app.Config.LEDs.Names) > 0 {
+		indicator, err := NewModeIndicator(app.Config.LEDs)
+		if err != nil {
+			return err
+		}
+		app.Indicator = indicator
+		notices, _ := app.Notices.SubscribeChanges()
+		go app.indicateMode(notices)
+	}
+
+	if app.Config.KeyBacklight.Enabled {
+		backlight, err := NewKeyBacklight(app.Config.KeyBacklight)
+		if err != nil {
+			return err
+		}
+		app.KeyBacklight = backlight
+		notices, _ := app.Notices.SubscribeChanges()
+		go app.lightKeypad(notices)
+	}
+
+	if app.Config.OSD.Enabled {
+		osd, err := NewOSD(app.Config.OSD, app.Config.Android)
+		if err != nil {
+			return err
+		}
+		app.OSD = osd
+		notices, _ := app.Notices.SubscribeChanges()
+		go app.showMessages(notices)
+	}
+
+	if app.Config.Notify.Enabled {
+		notifier, err := NewNotifier(app.Config.Notify)
+		if err != nil {
+			return err
+		}
+		app.Notifier = notifier
+		notices, _ := app.Notices.SubscribeChanges()
+		go app.notifyChanges(notices)
+	}
+
+	if app.Config.Speech.Enabled {
+		speaker, err := NewSpeaker(app.Config.Speech)
+		if err != nil {
+			return err
+		}
+		app.Speaker = speaker
+		notices, _ := app.Notices.SubscribeChanges()
+		go app.speakChanges(notices)
+	}
+
+	if len(app.Config.Hooks) > 0 {
+		notices, _ := app.Notices.SubscribeChanges()
+		go app.runHooks(notices)
+	}
+
+	if app.Config.Broadcasts.Enabled {
+		notices, _ := app.Notices.SubscribeChanges()
+		go app.broadcastChanges(notices)
+	}
+
+	if app.Config.ControlSocket != "" {
+		control, err := ListenControl(app, app.Config.ControlSocket)
+		if err != nil {
+			return err
+		}
+		app.Control = control
+		go control.Serve()
+		if app.Config.Android {
+			if err := shareWithShell(app.Config.ControlSocket); err != nil {
+				app.Logger.Printf("%v", err)
+			}
+		}
+	}
+
+	if app.Config.CommandFIFO != "" {
+		fifo, err := OpenCommandFIFO(app, app.Config.CommandFIFO)
+		if err != nil {
+			return err
+		}
+		app.FIFO = fifo
+		go fifo.Serve()
+		if app.Config.Android {
+			if err := shareWithShell(app.Config.CommandFIFO); err != nil {
+				app.Logger.Printf("%v", err)
+			}
+		}
+	}
+
+	if app.Config.DBus != "" {
+		conn, err := app.ExportDBus(app.Config.DBus)
+		if err != nil {
+			return err
+		}
+		app.DBus = conn
+	}
+
+	if app.Config.HTTPAddr != "" {
+		server, err := ListenHTTP(app, app.Config.HTTPAddr)
+		if err != nil {
+			return err
+		}
+		app.HTTP = server
+		go server.Serve()
+	}
+
+	if app.Config.OverlayAddr != "" {
+		server, err := ListenOverlay(app, app.Config.OverlayAddr)
+		if err != nil {
+			return err
+		}
+		app.Overlay = server
+		go server.Serve()
+	}
+
+	if app.Config.GRPCAddr != "" {
+		server, err := ListenGRPC(app, app.Config.GRPCAddr)
+		if err != nil {
+			return err
+		}
+		app.GRPC = server
+		go server.Serve()
+	}
+
+	if app.Config.MQTT.Broker != "" {
+		client, err := ConnectMQTT(app, app.Config.MQTT)
+		if err != nil {
+			return err
+		}
+		app.MQTT = client
+	}
+
+	if app.Config.NetListen != "" {
+		server, err := ListenNet(app, app.Config.NetListen, app.Config.NetAllow)
+		if err != nil {
+			return err
+		}
+		app.NetServer = server
+		go server.Serve()
+	}
 
 	// Set up signal handling for graceful shutdown
 	app.setupSignalHandling()
@@ -740,56 +2016,281 @@ func (app *Application) Run() error {
 		return err
 	}
 
-	fmt.Println("Virtual mouse active. Press Ctrl+C to exit.")
+	app.Logger.Info("Virtual mouse active. Press Ctrl+C to exit.\n")
+	if err := sdNotify("READY=1"); err != nil {
+		app.Logger.Printf("Failed to notify systemd: %v", err)
+	}
+	go app.runHook("start")
 
-	// Block forever
-	select {}
+	// Blocks until a shutdown signal stops the loop
+	return app.DeviceManager.runLoop()
 }
 
 // setupSignalHandling sets up handlers for OS signals
 func (app *Application) setupSignalHandling() {
 	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGUSR1, syscall.SIGUSR2)
 
 	go func() {
-		<-c
-		fmt.Println("\nShutting down...")
-		app.Cleanup()
-		os.Exit(0)
+		for sig := range c {
+			// SIGHUP asks for a device rescan, SIGUSR1 toggles mouse mode and
+			// SIGUSR2 cycles profiles, instead of a shutdown
+			switch sig {
+			case syscall.SIGHUP:
+				app.Rescan()
+				continue
+			case syscall.SIGUSR1:
+				app.Execute("toggle")
+				continue
+			case syscall.SIGUSR2:
+				if err := app.CycleProfile(); err != nil {
+					app.Logger.Printf("Failed to cycle profile: %v", err)
+				}
+				continue
+			}
+
+			app.Logger.Info("Shutting down...\n")
+			app.DeviceManager.Stop()
+			return
+		}
 	}()
 }
 
+// Rescan attaches any input devices that appeared since startup
+func (app *Application) Rescan() {
+	added, err := app.DeviceManager.Rescan()
+	if err != nil {
+		app.Logger.Printf("Rescan failed: %v", err)
+		return
+	}
+	app.Logger.Printf("Rescan attached %d new devices", added)
+}
+
 // Cleanup releases resources when the application exits
 func (app *Application) Cleanup() {
+	sdNotify("STOPPING=1")
+	app.runHook("stop")
+	if app.TUI != nil {
+		app.TUI.Close()
+	}
+
+	// Stop taking commands before the devices they drive go away
+	if app.Control != nil {
+		app.Control.Close()
+	}
+	if app.FIFO != nil {
+		app.FIFO.Close()
+	}
+	if app.DBus != nil {
+		app.DBus.Close()
+	}
+	if app.HTTP != nil {
+		app.HTTP.Close()
+	}
+	if app.Beeper != nil {
+		app.Beeper.Close()
+	}
+	if app.Vibrator != nil {
+		app.Vibrator.Close()
+	}
+	if app.Indicator != nil {
+		app.Indicator.Set(false)
+	}
+	if app.KeyBacklight != nil {
+		app.KeyBacklight.Set(false)
+	}
+	if app.OSD != nil {
+		app.OSD.Close()
+	}
+	if app.Notifier != nil {
+		app.Notifier.Close()
+	}
+	if app.Overlay != nil {
+		app.Overlay.Close()
+	}
+	if app.GRPC != nil {
+		app.GRPC.Close()
+	}
+	if app.MQTT != nil {
+		app.MQTT.Close()
+	}
+	if app.NetServer != nil {
+		app.NetServer.Close()
+	}
+	if app.Lua != nil {
+		app.Lua.Close()
+	}
+	for _, plugin := range app.Plugins {
+		plugin.Stop()
+	}
+
 	// Release buttons in case they're stuck
 	app.VirtualMouse.LeftRelease()
 	app.VirtualMouse.RightRelease()
 
 	// Close all devices
+	app.DeviceManager.mu.Lock()
 	for _, dev := range app.DeviceManager.Devices {
 		dev.Device.File.Close()
 	}
+	app.DeviceManager.mu.Unlock()
+
+	app.EventProcessor.ReleaseModifiers()
+	app.Emitter.Flush()
+	if app.DeviceManager.Trace != nil {
+		app.DeviceManager.Trace.Close()
+	}
 
 	app.VirtualMouse.Close()
+	for _, mc := range app.ExtraControllers {
+		mc.mu.Lock()
+		mc.ResetButtons()
+		mc.Mouse.Close()
+		mc.mu.Unlock()
+	}
 	app.VirtualKeyboard.Close()
 	app.LogFile.Close()
+	app.InstanceLock.Release()
 }
 
 func main() {
-	fmt.Println("Starting virtual mouse service...")
+	// "ctl" talks to a running instance instead of starting one
+	if len(os.Args) > 1 && os.Args[1] == "ctl" {
+		if err := runCtl(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "ctl: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	configPath := flag.String("config", DefaultConfigPath, "path to the JSON config file")
+	replace := flag.Bool("replace", false, "stop an already running instance and take over")
+	android := flag.Bool("android", false, "run as an Android init or Magisk service")
+	installRules := flag.Bool("install-udev-rules", false, "install udev rules for running without root, then exit")
+	tui := flag.Bool("tui", false, "show a live dashboard of devices, state and events")
+	quiet := flag.Bool("quiet", false, "print nothing but errors; status messages only go to the log")
+	debug := flag.Bool("debug", false, "log every key and decision, as \"debug_mode\" does")
+	record := flag.String("record", "", "write every input event and its decision to a trace file")
+	replay := flag.String("replay", "", "run a trace file through the keymap and print what it does, then exit")
+	dumpEvents := flag.Bool("dump-events", false, "print the events of the keypads, or of the devices named after the flags, with the actions bound to them")
+	grab := flag.Bool("grab", false, "with -dump-events, keep the keys from reaching anything else")
+	setup := flag.Bool("setup", false, "run the setup assistant, as on a first run without a config")
+	flag.Parse()
+
+	if *installRules {
+		if err := installUdevRules(); err != nil {
+			log.Fatalf("Failed to install udev rules: %v", err)
+		}
+		return
+	}
+
+	// A first run from a terminal offers to set up the keypad
+	if _, err := os.Stat(*configPath); *setup || os.IsNotExist(err) && isTerminal(os.Stdin) && askSetup(*configPath, os.Stdin, os.Stdout) {
+		if err := runSetup(*configPath, os.Stdout); err != nil {
+			log.Fatalf("Setup failed: %v", err)
+		}
+	}
+
+	config, err := LoadConfig(*configPath)
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	// The dashboard owns the terminal
+	if *quiet || *tui {
+		config.Quiet = true
+	}
+	if *debug {
+		config.DebugMode = true
+	}
+	if *replay != "" {
+		if err := Replay(config, *replay, os.Stdout); err != nil {
+			log.Fatalf("Replay failed: %v", err)
+		}
+		return
+	}
+	if *dumpEvents {
+		// A running instance grabs the keypads, which would leave nothing to read
+		lock, err := AcquireInstanceLock(config.PidPath, *replace)
+		if err != nil {
+			log.Fatalf("Failed to dump events: %v", err)
+		}
+		err = DumpEvents(config, flag.Args(), *grab, os.Stdout)
+		lock.Release()
+		if err != nil {
+			log.Fatalf("Failed to dump events: %v", err)
+		}
+		return
+	}
+	if !config.Quiet {
+		fmt.Println("Starting virtual mouse service...")
+	}
+
+	if *android {
+		config.Android = true
+	}
+	if config.Android {
+		config.applyAndroid()
+		if err := waitForUinput(); err != nil {
+			log.Fatalf("Failed to start: %v", err)
+		}
+	}
+
+	if problems, fatal := checkAccess(); len(problems) > 0 {
+		printAccessHelp(problems)
+		if fatal {
+			os.Exit(1)
+		}
+	}
+
+	if config.Screen.AutoDetect {
+		screen, err := DetectScreen(config.Screen)
+		if err != nil && !config.Quiet {
+			fmt.Printf("Screen detection failed, using configured size: %v\n", err)
+		}
+		config.Screen = screen
+	}
+
+	// Make sure no other instance is holding the devices
+	lock, err := AcquireInstanceLock(config.PidPath, *replace)
+	if err != nil {
+		log.Fatalf("Failed to start: %v", err)
+	}
 
 	// Create and initialize the application
-	app, err := NewApplication()
+	app, err := NewApplication(config)
 	if err != nil {
+		lock.Release()
 		log.Fatalf("Failed to initialize application: %v", err)
 	}
+	app.InstanceLock = lock
 	defer app.Cleanup()
 
+	if *record != "" {
+		trace, err := CreateTrace(*record)
+		if err != nil {
+			log.Fatalf("Failed to start: %v", err)
+		}
+		app.DeviceManager.Trace = trace
+	}
+
+	// Anything still printed joins the log rather than the dashboard
+	term := os.Stdout
+	if *tui {
+		os.Stdout = app.LogFile
+	}
+
 	// Setup the application
 	if err := app.Setup(); err != nil {
 		log.Fatalf("Failed to setup application: %v", err)
 	}
 
+	if *tui {
+		app.TUI = StartTUI(app, term)
+	} else if isTerminal(os.Stdin) {
+		app.Logger.Info("Commands typed here are run; try help.\n")
+		go app.runConsole(os.Stdin, os.Stdout)
+	}
+
 	// Run the application
 	if err := app.Run(); err != nil {
 		log.Fatalf("Application error: %v", err)
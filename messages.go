@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/goFlipMouse/keymaps"
+)
+
+// Messages renders the text of notices, shown on the OSD, in notifications
+// and the log, or spoken, in one language. Diagnostics stay in English.
+type Messages struct {
+	catalog Catalog
+}
+
+// NewMessages picks the catalog for locale, such as "pt_BR.UTF-8", with
+// overrides laid over it. Messages it lacks are taken from English.
+func NewMessages(locale string, overrides map[string]string) *Messages {
+	catalog := maps.Clone(catalogs["en"])
+	maps.Copy(catalog, catalogs[language(locale)])
+	maps.Copy(catalog, overrides)
+	return &Messages{catalog: catalog}
+}
+
+// language reduces a locale such as "pt_BR.UTF-8" or "pt-BR" to "pt"
+func language(locale string) string {
+	locale = strings.ToLower(locale)
+	if i := strings.IndexAny(locale, "_-.@"); i >= 0 {
+		locale = locale[:i]
+	}
+	return locale
+}
+
+// systemLocale finds the locale in LC_ALL, LC_MESSAGES or LANG, or on
+// Android in persist.sys.locale
+func systemLocale() string {
+	for _, name := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		if locale := os.Getenv(name); locale != "" {
+			return locale
+		}
+	}
+	if out, err := exec.Command("getprop", "persist.sys.locale").Output(); err == nil {
+		return strings.TrimSpace(string(out))
+	}
+	return ""
+}
+
+// Text formats the message id, or returns the id if there is no such message
+func (m *Messages) Text(id string, args ...any) string {
+	format, ok := m.catalog[id]
+	if !ok {
+		return id
+	}
+	if len(args) == 0 {
+		return format
+	}
+	return fmt.Sprintf(format, args...)
+}
+
+// Notice is the short text shown for a notice, "" for notices that are
+// not worth telling the user about
+func (m *Messages) Notice(n Notice) string {
+	id, args := noticeID(n)
+	if id == "" {
+		return ""
+	}
+	return m.Text(id, args...)
+}
+
+// Spoken is Notice as it reads best aloud; errors are only announced,
+// their details would take too long
+func (m *Messages) Spoken(n Notice) string {
+	switch n.Kind {
+	case "error":
+		return m.Text("spoken_error")
+	case "bindings":
+		// "up: KEY_UP" reads as "up: up"
+		return strings.ToLower(strings.NewReplacer("KEY_", "", "\n", ", ").Replace(n.Message))
+	}
+	id, args := noticeID(n)
+	if id == "" {
+		return ""
+	}
+	if _, ok := m.catalog["spoken_"+id]; ok {
+		return m.Text("spoken_"+id, args...)
+	}
+	return strings.ToLower(m.Text(id, args...))
+}
+
+// noticeID names the message for a notice and its arguments, "" for none
+func noticeID(n Notice) (string, []any) {
+	switch n.Kind {
+	case "mode":
+		if n.On {
+			return "mode_on", nil
+		}
+		return "mode_off", nil
+	case "speed":
+		return "speed", []any{n.Speed}
+	case "drag":
+		if n.On {
+			return "drag_on", nil
+		}
+		return "drag_off", nil
+	case "profile":
+		return "profile", []any{n.Profile}
+	case "feel":
+		return "feel", []any{n.Message}
+	case "readout":
+		if n.Profile != "" {
+			return "readout_profile", []any{n.Speed, n.Scroll, n.Profile}
+		}
+		return "readout", []any{n.Speed, n.Scroll}
+	case "power":
+		if n.On {
+			return "power_on", nil
+		}
+		return "power_off", nil
+	case "error":
+		return "error", []any{n.Message}
+	case "edge":
+		return "edge_" + n.Message, nil
+	case "bindings":
+		return "bindings", []any{n.Message}
+	}
+	return "", nil
+}
+
+// bindingsText lists km's keys one per line, as "toggle mouse: KEY_HELP"
+func bindingsText(km keymaps.KeyMapping) string {
+	var lines []string
+	for _, b := range km.Bindings() {
+		lines = append(lines, b.Action+": "+keyName(b.Code))
+	}
+	return strings.Join(lines, "\n")
+}
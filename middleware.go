@@ -0,0 +1,34 @@
+package main
+
+import evdev "github.com/grafov/evdev"
+
+// Decision is what a Middleware wants done with an event
+type Decision int
+
+const (
+	// Continue hands the event on to the next middleware and finally ProcessEvent
+	Continue Decision = iota
+	// Mute swallows the event
+	Mute
+	// PassThru forwards the event to the virtual keyboard as is
+	PassThru
+)
+
+// Middleware looks at an event before the default processing. It may change
+// the event in place, e.g. to remap a key.
+type Middleware func(event *evdev.InputEvent, device *InputDevice) Decision
+
+// Use appends a middleware to the chain run before the default processing
+func (ep *EventProcessor) Use(m Middleware) {
+	ep.middleware = append(ep.middleware, m)
+}
+
+// runMiddleware runs the chain until a middleware decides the event's fate
+func (ep *EventProcessor) runMiddleware(event *evdev.InputEvent, device *InputDevice) Decision {
+	for _, m := range ep.middleware {
+		if d := m(event, device); d != Continue {
+			return d
+		}
+	}
+	return Continue
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"sync"
+	"testing"
+
+	"github.com/goFlipMouse/keymaps"
+	"github.com/goFlipMouse/vdev"
+	evdev "github.com/grafov/evdev"
+)
+
+// mockPointer is a PointerOutput that records its calls
+type mockPointer struct {
+	mu    sync.Mutex
+	calls []string
+}
+
+func (m *mockPointer) record(format string, v ...any) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls = append(m.calls, fmt.Sprintf(format, v...))
+	return nil
+}
+
+// take returns the calls made since the last take
+func (m *mockPointer) take() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	calls := m.calls
+	m.calls = nil
+	return calls
+}
+
+func (m *mockPointer) Move(x, y int32) error { return m.record("move %d %d", x, y) }
+func (m *mockPointer) Wheel(horizontal bool, delta int32) error {
+	return m.record("wheel %t %d", horizontal, delta)
+}
+func (m *mockPointer) ButtonPress(code uint16) error   { return m.record("press %d", code) }
+func (m *mockPointer) ButtonRelease(code uint16) error { return m.record("release %d", code) }
+func (m *mockPointer) LeftPress() error                { return m.ButtonPress(vdev.BtnLeft) }
+func (m *mockPointer) LeftRelease() error              { return m.ButtonRelease(vdev.BtnLeft) }
+func (m *mockPointer) RightPress() error               { return m.ButtonPress(vdev.BtnRight) }
+func (m *mockPointer) RightRelease() error             { return m.ButtonRelease(vdev.BtnRight) }
+func (m *mockPointer) MiddlePress() error              { return m.ButtonPress(vdev.BtnMiddle) }
+func (m *mockPointer) MiddleRelease() error            { return m.ButtonRelease(vdev.BtnMiddle) }
+func (m *mockPointer) Close() error                    { return nil }
+
+// mockKeyboard is a KeyboardOutput that ignores everything
+type mockKeyboard struct{}
+
+func (mockKeyboard) KeyDown(key int) error               { return nil }
+func (mockKeyboard) KeyUp(key int) error                 { return nil }
+func (mockKeyboard) KeyPress(key int) error              { return nil }
+func (mockKeyboard) TypeString(text string) error        { return nil }
+func (mockKeyboard) SendFrame(events []vdev.Event) error { return nil }
+func (mockKeyboard) Close() error                        { return nil }
+
+// newTestApp assembles an application on mock devices, with a laptop keypad
+// that uses the laptop keymap
+func newTestApp(t *testing.T, config Config) (*Application, *InputDevice, *mockPointer) {
+	t.Helper()
+	logger := &Logger{Logger: log.New(io.Discard, "", 0), quiet: true}
+	emitter := NewEmitter(logger)
+	go emitter.Run()
+
+	mouse := &mockPointer{}
+	app := assemble(config, logger, keymaps.CreateDefaultKeyMappingProvider(), emitter, NewNoticeHub(), []PointerOutput{mouse}, mockKeyboard{})
+	device := &InputDevice{Name: "test keypad", KeyboardType: keymaps.KBD_TYPE_LAPTOP}
+	return app, device, mouse
+}
+
+// key builds a key event
+func key(code uint16, value int32) *evdev.InputEvent {
+	return &evdev.InputEvent{Type: EvKey, Code: code, Value: value}
+}
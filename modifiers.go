@@ -0,0 +1,53 @@
+package main
+
+import "github.com/goFlipMouse/vdev"
+
+// Modifier key codes from input-event-codes.h
+var modifierKeys = map[uint16]bool{
+	29:  true, // KEY_LEFTCTRL
+	42:  true, // KEY_LEFTSHIFT
+	54:  true, // KEY_RIGHTSHIFT
+	56:  true, // KEY_LEFTALT
+	97:  true, // KEY_RIGHTCTRL
+	100: true, // KEY_RIGHTALT
+	125: true, // KEY_LEFTMETA
+	126: true, // KEY_RIGHTMETA
+}
+
+// trackModifiers records modifier presses and releases forwarded to the
+// virtual keyboard
+func (ep *EventProcessor) trackModifiers(frame []vdev.Event) {
+	ep.modMu.Lock()
+	defer ep.modMu.Unlock()
+
+	for _, e := range frame {
+		if e.Type != EvKey || !modifierKeys[e.Code] {
+			continue
+		}
+		switch e.Value {
+		case KeyPressed:
+			ep.heldModifiers[e.Code] = true
+		case KeyReleased:
+			delete(ep.heldModifiers, e.Code)
+		}
+	}
+}
+
+// ReleaseModifiers queues releases for every modifier the virtual keyboard
+// still holds. It runs on mode changes and shutdown, where the physical
+// release would otherwise be muted and leave the modifier stuck. Running on
+// the emitter, it also sees modifier frames queued before it.
+func (ep *EventProcessor) ReleaseModifiers() {
+	ep.Emitter.Do(func() error {
+		ep.modMu.Lock()
+		defer ep.modMu.Unlock()
+
+		for code := range ep.heldModifiers {
+			if err := ep.VirtualKeyboard.KeyUp(int(code)); err != nil {
+				ep.Logger.Printf("Failed to release modifier %d: %v", code, err)
+			}
+			delete(ep.heldModifiers, code)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// MQTTConfig connects the daemon to an MQTT broker. Commands published to
+// TOPIC/cmd are run and answered on TOPIC/reply; the StatusReport is kept
+// retained on TOPIC/state and TOPIC/availability says online or offline.
+type MQTTConfig struct {
+	// Broker URL, e.g. "tcp://192.168.1.2:1883"; "" disables MQTT
+	Broker   string `json:"broker"`
+	ClientID string `json:"client_id"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+	// Topic prefix, "goflipmouse" by default
+	Topic string `json:"topic"`
+}
+
+// MQTTClient bridges control commands and state changes to a broker
+type MQTTClient struct {
+	app    *Application
+	client mqtt.Client
+	topic  string
+	cancel func()
+}
+
+// ConnectMQTT connects to the broker, subscribes to the command topic and
+// starts publishing state. The client reconnects by itself afterwards.
+func ConnectMQTT(app *Application, config MQTTConfig) (*MQTTClient, error) {
+	m := &MQTTClient{app: app, topic: config.Topic}
+	if m.topic == "" {
+		m.topic = "goflipmouse"
+	}
+	clientID := config.ClientID
+	if clientID == "" {
+		hostname, _ := os.Hostname()
+		clientID = "goflipmouse-" + hostname
+	}
+
+	opts := mqtt.NewClientOptions().
+		AddBroker(config.Broker).
+		SetClientID(clientID).
+		SetUsername(config.Username).
+		SetPassword(config.Password).
+		SetAutoReconnect(true).
+		SetWill(m.topic+"/availability", "offline", 1, true).
+		SetOnConnectHandler(m.onConnect)
+
+	m.client = mqtt.NewClient(opts)
+	if token := m.client.Connect(); token.Wait() && token.Error() != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %v", config.Broker, token.Error())
+	}
+
+	notices, cancel := app.Notices.Subscribe()
+	m.cancel = cancel
+	go func() {
+		for n := range notices {
+			if !n.IsEvent() {
+				m.publishState()
+			}
+		}
+	}()
+	return m, nil
+}
+
+// onConnect (re)subscribes after every connection, since the session is not kept
+func (m *MQTTClient) onConnect(client mqtt.Client) {
+	client.Publish(m.topic+"/availability", 1, true, "online")
+	m.publishState()
+
+	token := client.Subscribe(m.topic+"/cmd", 1, func(client mqtt.Client, msg mqtt.Message) {
+		reply, err := m.app.Execute(string(msg.Payload()))
+		if err != nil {
+			reply = "error " + err.Error()
+		} else {
+			reply = "ok " + reply
+		}
+		client.Publish(m.topic+"/reply", 1, false, reply)
+	})
+	if token.Wait() && token.Error() != nil {
+		m.app.Logger.Printf("Failed to subscribe to %s/cmd: %v", m.topic, token.Error())
+	}
+}
+
+// publishState publishes the current StatusReport as retained JSON
+func (m *MQTTClient) publishState() {
+	data, err := json.Marshal(m.app.Report())
+	if err != nil {
+		return
+	}
+	m.client.Publish(m.topic+"/state", 1, true, data)
+}
+
+// Close marks the daemon offline and disconnects
+func (m *MQTTClient) Close() {
+	m.cancel()
+	m.client.Publish(m.topic+"/availability", 1, true, "offline").Wait()
+	m.client.Disconnect(250)
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/goFlipMouse/vdev"
+)
+
+// netDialTimeout bounds how long a write may wait for the peer to answer
+const netDialTimeout = 2 * time.Second
+
+// NetMouse is a PointerOutput that sends its output to a peer's network
+// server (see netserver.go) instead of a local uinput device, so the keypad
+// can drive a TV box or PC. It reconnects on the next write after a failure.
+type NetMouse struct {
+	peer string
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+// DialNetMouse creates a NetMouse for peer ("host:port"). The first
+// connection is made right away so a wrong address shows up at startup.
+func DialNetMouse(peer string) (*NetMouse, error) {
+	m := &NetMouse{peer: peer}
+	conn, err := net.DialTimeout("tcp", peer, netDialTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %v", peer, err)
+	}
+	m.conn = conn
+	return m, nil
+}
+
+func (m *NetMouse) send(p netPacket) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.conn == nil {
+		conn, err := net.DialTimeout("tcp", m.peer, netDialTimeout)
+		if err != nil {
+			return fmt.Errorf("failed to reconnect to %s: %v", m.peer, err)
+		}
+		m.conn = conn
+	}
+	if _, err := m.conn.Write(p.encode()); err != nil {
+		m.conn.Close()
+		m.conn = nil
+		return fmt.Errorf("failed to send to %s: %v", m.peer, err)
+	}
+	return nil
+}
+
+// clamp16 limits a value to the int16 range of a packet field
+func clamp16(v int32) int16 {
+	return int16(max(math.MinInt16, min(v, math.MaxInt16)))
+}
+
+func (m *NetMouse) Move(x, y int32) error {
+	return m.send(netPacket{Type: netMove, A: clamp16(x), B: clamp16(y)})
+}
+
+func (m *NetMouse) Wheel(horizontal bool, delta int32) error {
+	if horizontal {
+		return m.send(netPacket{Type: netWheel, B: clamp16(delta)})
+	}
+	return m.send(netPacket{Type: netWheel, A: clamp16(delta)})
+}
+
+func (m *NetMouse) ButtonPress(code uint16) error {
+	return m.send(netPacket{Type: netButton, A: int16(code), B: 1})
+}
+
+func (m *NetMouse) ButtonRelease(code uint16) error {
+	return m.send(netPacket{Type: netButton, A: int16(code)})
+}
+
+func (m *NetMouse) LeftPress() error     { return m.ButtonPress(vdev.BtnLeft) }
+func (m *NetMouse) LeftRelease() error   { return m.ButtonRelease(vdev.BtnLeft) }
+func (m *NetMouse) RightPress() error    { return m.ButtonPress(vdev.BtnRight) }
+func (m *NetMouse) RightRelease() error  { return m.ButtonRelease(vdev.BtnRight) }
+func (m *NetMouse) MiddlePress() error   { return m.ButtonPress(vdev.BtnMiddle) }
+func (m *NetMouse) MiddleRelease() error { return m.ButtonRelease(vdev.BtnMiddle) }
+
+// Close drops the connection
+func (m *NetMouse) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.conn == nil {
+		return nil
+	}
+	err := m.conn.Close()
+	m.conn = nil
+	return err
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+
+	"github.com/goFlipMouse/vdev"
+)
+
+// Network remote protocol. Every packet is 6 bytes:
+//
+//	offset 0  uint8  type: 'M' move, 'W' wheel, 'B' button, 'K' key
+//	offset 1  uint8  reserved, 0
+//	offset 2  int16  move dx, vertical wheel notches, or button/key code
+//	offset 4  int16  move dy, horizontal wheel notches, or 1 press / 0 release
+//
+// Integers are big-endian. A UDP datagram holds one or more packets; a TCP
+// connection is a stream of them.
+const netPacketSize = 6
+
+// Packet types
+const (
+	netMove   = 'M'
+	netWheel  = 'W'
+	netButton = 'B'
+	netKey    = 'K'
+)
+
+// netPacket is one decoded packet
+type netPacket struct {
+	Type byte
+	A, B int16
+}
+
+func (p netPacket) encode() []byte {
+	buf := make([]byte, netPacketSize)
+	buf[0] = p.Type
+	binary.BigEndian.PutUint16(buf[2:], uint16(p.A))
+	binary.BigEndian.PutUint16(buf[4:], uint16(p.B))
+	return buf
+}
+
+func decodePacket(buf []byte) netPacket {
+	return netPacket{
+		Type: buf[0],
+		A:    int16(binary.BigEndian.Uint16(buf[2:])),
+		B:    int16(binary.BigEndian.Uint16(buf[4:])),
+	}
+}
+
+// NetServer receives remote pointer packets on TCP and UDP and injects them
+// through the primary pointer and the virtual keyboard
+type NetServer struct {
+	app      *Application
+	allow    []*net.IPNet
+	listener net.Listener
+	conn     net.PacketConn
+}
+
+// ListenNet listens for packets on addr over both TCP and UDP. Only senders
+// matching allow (IPs or CIDRs) are served; an empty list allows everyone.
+func ListenNet(app *Application, addr string, allow []string) (*NetServer, error) {
+	s := &NetServer{app: app}
+	for _, a := range allow {
+		if !strings.Contains(a, "/") {
+			if strings.Contains(a, ":") {
+				a += "/128"
+			} else {
+				a += "/32"
+			}
+		}
+		_, ipnet, err := net.ParseCIDR(a)
+		if err != nil {
+			return nil, fmt.Errorf("invalid net_allow entry %q: %v", a, err)
+		}
+		s.allow = append(s.allow, ipnet)
+	}
+
+	var err error
+	if s.listener, err = net.Listen("tcp", addr); err != nil {
+		return nil, fmt.Errorf("failed to listen on tcp %s: %v", addr, err)
+	}
+	if s.conn, err = net.ListenPacket("udp", addr); err != nil {
+		s.listener.Close()
+		return nil, fmt.Errorf("failed to listen on udp %s: %v", addr, err)
+	}
+	return s, nil
+}
+
+// Serve handles TCP clients and UDP datagrams until Close
+func (s *NetServer) Serve() {
+	go s.serveUDP()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				s.app.Logger.Printf("Network server accept failed: %v", err)
+			}
+			return
+		}
+		if !s.allowed(conn.RemoteAddr()) {
+			s.app.Logger.Printf("Refused network client %s", conn.RemoteAddr())
+			conn.Close()
+			continue
+		}
+		go s.serveTCP(conn)
+	}
+}
+
+func (s *NetServer) serveTCP(conn net.Conn) {
+	defer conn.Close()
+	buf := make([]byte, netPacketSize)
+	for {
+		if _, err := io.ReadFull(conn, buf); err != nil {
+			return
+		}
+		s.inject(decodePacket(buf))
+	}
+}
+
+func (s *NetServer) serveUDP() {
+	buf := make([]byte, 1500)
+	for {
+		n, addr, err := s.conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		if !s.allowed(addr) {
+			continue
+		}
+		for i := 0; i+netPacketSize <= n; i += netPacketSize {
+			s.inject(decodePacket(buf[i:]))
+		}
+	}
+}
+
+// allowed checks a sender against the allow list
+func (s *NetServer) allowed(addr net.Addr) bool {
+	if len(s.allow) == 0 {
+		return true
+	}
+	var ip net.IP
+	switch a := addr.(type) {
+	case *net.TCPAddr:
+		ip = a.IP
+	case *net.UDPAddr:
+		ip = a.IP
+	}
+	for _, ipnet := range s.allow {
+		if ipnet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// inject performs one packet
+func (s *NetServer) inject(p netPacket) {
+	mc := s.app.MouseController
+	switch p.Type {
+	case netMove:
+		mc.mu.Lock()
+		mc.MoveBy(int32(p.A), int32(p.B))
+		mc.mu.Unlock()
+	case netWheel:
+		mc.mu.Lock()
+		if p.A != 0 {
+			mc.Mouse.Wheel(false, int32(p.A))
+		}
+		if p.B != 0 {
+			mc.Mouse.Wheel(true, int32(p.B))
+		}
+		mc.mu.Unlock()
+	case netButton:
+		if p.A < vdev.BtnLeft || p.A > vdev.BtnExtra {
+			return
+		}
+		mc.mu.Lock()
+		mc.setButton(uint16(p.A), p.B != 0)
+		mc.mu.Unlock()
+	case netKey:
+		code, down := int(p.A), p.B != 0
+		if code <= 0 || code > vdev.KeyMax {
+			return
+		}
+		kbd := s.app.VirtualKeyboard
+		s.app.Emitter.Do(func() error {
+			if down {
+				return kbd.KeyDown(code)
+			}
+			return kbd.KeyUp(code)
+		})
+	default:
+		s.app.Logger.Debug("Unknown network packet type %q\n", p.Type)
+	}
+}
+
+// Close stops both listeners
+func (s *NetServer) Close() {
+	s.listener.Close()
+	s.conn.Close()
+}
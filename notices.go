@@ -0,0 +1,110 @@
+package main
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// noticeBuffer is how many notices a subscriber may fall behind before
+// further ones are dropped for it
+const noticeBuffer = 64
+
+// Notice is a state change or an input or output event pushed to subscribers
+type Notice struct {
+	// Kind is "mode", "speed", "profile", "feel" (the preset in Message),
+	// "drag" (with the button in Code), "dragging" while a drag holds a
+	// button, "device" (attached when On), "error", "readout" when the
+	// settings are asked for, "power" (saving when On), "edge" (the side in
+	// Message) when a screen reader step hits it, "bindings" (one key per
+	// line in Message) when they are asked for, "input" for events read from
+	// a device or "output" for events written to a virtual device
+	Kind    string  `json:"kind"`
+	On      bool    `json:"on,omitempty"`
+	Speed   float64 `json:"speed,omitempty"`
+	Scroll  float64 `json:"scroll,omitempty"`
+	Profile string  `json:"profile,omitempty"`
+	// Message describes an error
+	Message string `json:"message,omitempty"`
+
+	// Input and output events
+	Device string `json:"device,omitempty"`
+	Type   uint16 `json:"type,omitempty"`
+	Code   uint16 `json:"code,omitempty"`
+	Value  int32  `json:"value,omitempty"`
+}
+
+// IsEvent reports whether a notice is an input or output event rather
+// than a state change
+func (n Notice) IsEvent() bool {
+	return n.Kind == "input" || n.Kind == "output"
+}
+
+// NoticeHub fans notices out to subscribers. Publishing never blocks; a
+// subscriber that does not keep up misses notices.
+type NoticeHub struct {
+	mu sync.Mutex
+	// subs maps each subscriber to whether it takes input and output events
+	subs map[chan Notice]bool
+	// count is the number of subscribers taking events
+	count atomic.Int32
+}
+
+// NewNoticeHub creates a hub without subscribers
+func NewNoticeHub() *NoticeHub {
+	return &NoticeHub{subs: map[chan Notice]bool{}}
+}
+
+// Subscribe returns a channel receiving every later notice and a function
+// that ends the subscription
+func (h *NoticeHub) Subscribe() (<-chan Notice, func()) {
+	return h.subscribe(true)
+}
+
+// SubscribeChanges is Subscribe without input and output events, for
+// subscribers that only follow state changes
+func (h *NoticeHub) SubscribeChanges() (<-chan Notice, func()) {
+	return h.subscribe(false)
+}
+
+func (h *NoticeHub) subscribe(events bool) (<-chan Notice, func()) {
+	ch := make(chan Notice, noticeBuffer)
+	h.mu.Lock()
+	h.subs[ch] = events
+	if events {
+		h.count.Add(1)
+	}
+	h.mu.Unlock()
+
+	return ch, func() {
+		h.mu.Lock()
+		if events, ok := h.subs[ch]; ok {
+			delete(h.subs, ch)
+			if events {
+				h.count.Add(-1)
+			}
+			close(ch)
+		}
+		h.mu.Unlock()
+	}
+}
+
+// Watched reports whether anyone is subscribed to input and output events,
+// so callers can skip building notices nobody reads
+func (h *NoticeHub) Watched() bool {
+	return h.count.Load() > 0
+}
+
+// Publish sends a notice to every subscriber with room for it
+func (h *NoticeHub) Publish(n Notice) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch, events := range h.subs {
+		if n.IsEvent() && !events {
+			continue
+		}
+		select {
+		case ch <- n:
+		default:
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// The freedesktop notification service, as used by libnotify
+const (
+	notifyName   = "org.freedesktop.Notifications"
+	notifyPath   = dbus.ObjectPath("/org/freedesktop/Notifications")
+	notifyMethod = notifyName + ".Notify"
+)
+
+// Notification urgencies from the specification
+const (
+	urgencyLow      byte = 0
+	urgencyCritical byte = 2
+)
+
+// NotifyConfig sends desktop notifications for mode changes and errors
+type NotifyConfig struct {
+	Enabled bool `json:"enabled"`
+	// Timeout before a notification closes; 0 leaves it to the desktop
+	Timeout Duration `json:"timeout"`
+	// Icon is a themed icon name or an image path
+	Icon string `json:"icon"`
+}
+
+// Notifier posts notifications on the session bus. Each notification
+// replaces the previous one so they do not pile up.
+type Notifier struct {
+	conn   *dbus.Conn
+	config NotifyConfig
+	id     uint32
+}
+
+// NewNotifier connects to the session bus. The control interface may be on
+// the system bus, so this is a connection of its own.
+func NewNotifier(config NotifyConfig) (*Notifier, error) {
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to the session bus: %v", err)
+	}
+	return &Notifier{conn: conn, config: config}, nil
+}
+
+// Notify shows summary, replacing the previous notification
+func (n *Notifier) Notify(summary, body string, urgency byte) error {
+	hints := map[string]dbus.Variant{"urgency": dbus.MakeVariant(urgency)}
+	timeout := int32(-1)
+	if n.config.Timeout.Duration > 0 {
+		timeout = int32(n.config.Timeout.Duration / time.Millisecond)
+	}
+	call := n.conn.Object(notifyName, notifyPath).Call(notifyMethod, 0,
+		"goFlipMouse", n.id, n.config.Icon, summary, body, []string{}, hints, timeout)
+	if call.Err != nil {
+		return fmt.Errorf("failed to send notification: %v", call.Err)
+	}
+	return call.Store(&n.id)
+}
+
+// Close disconnects from the session bus
+func (n *Notifier) Close() error {
+	return n.conn.Close()
+}
+
+// notifyChanges posts the notices routed to it, by default mode changes
+// and errors, until the subscription ends
+func (app *Application) notifyChanges(notices <-chan Notice) {
+	for n := range notices {
+		if !app.routed(n, SinkNotify) {
+			continue
+		}
+		var err error
+		switch n.Kind {
+		case "error":
+			err = app.Notifier.Notify(app.Messages.Text("error_title"), n.Message, urgencyCritical)
+		default:
+			msg := app.Messages.Notice(n)
+			if msg == "" {
+				continue
+			}
+			err = app.Notifier.Notify(msg, "", urgencyLow)
+		}
+		if err != nil {
+			app.Logger.Printf("%v", err)
+		}
+	}
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// OSD backends
+const (
+	OSDNotification = "notification"
+	OSDFramebuffer  = "framebuffer"
+)
+
+// Framebuffer ioctls and the offsets of the fields read from
+// fb_var_screeninfo and fb_fix_screeninfo
+const (
+	fbioGetVScreenInfo = 0x4600
+	fbioGetFScreenInfo = 0x4602
+	fbVarSize          = 160
+	fbFixSize          = 96
+	fbLineLength       = 40 + unsafe.Sizeof(uintptr(0))
+)
+
+// Layout of an OSD message on the framebuffer, in font pixels
+const (
+	osdPadding = 3
+	osdAdvance = 6
+)
+
+// OSDConfig shows short messages such as "Mouse ON" or "Speed 6"
+type OSDConfig struct {
+	Enabled bool `json:"enabled"`
+	// Backend is "notification" for an Android notification, the default on
+	// Android, or "framebuffer" to draw on Device, the default elsewhere
+	Backend string `json:"backend"`
+	Device  string `json:"device"`
+	// Duration a framebuffer message stays up
+	Duration Duration `json:"duration"`
+}
+
+// OSD shows messages on the screen
+type OSD struct {
+	config OSDConfig
+	fb     *framebuffer
+}
+
+// NewOSD prepares the configured backend
+func NewOSD(config OSDConfig, android bool) (*OSD, error) {
+	if config.Backend == "" {
+		config.Backend = OSDFramebuffer
+		if android {
+			config.Backend = OSDNotification
+		}
+	}
+	if config.Duration.Duration <= 0 {
+		config.Duration.Duration = 1500 * time.Millisecond
+	}
+
+	switch config.Backend {
+	case OSDNotification:
+		return &OSD{config: config}, nil
+	case OSDFramebuffer:
+		if config.Device == "" {
+			config.Device = "/dev/fb0"
+		}
+		fb, err := openFramebuffer(config.Device)
+		if err != nil {
+			return nil, err
+		}
+		return &OSD{config: config, fb: fb}, nil
+	}
+	return nil, fmt.Errorf("unknown OSD backend %q", config.Backend)
+}
+
+// Show displays a message. Framebuffer messages are taken down again before
+// it returns; notifications replace the previous one.
+func (o *OSD) Show(text string) error {
+	if o.fb != nil {
+		return o.fb.flash(text, o.config.Duration.Duration)
+	}
+	cmd := exec.Command("cmd", "notification", "post", "-S", "bigtext", "-t", "goFlipMouse", "goflipmouse", text)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("cmd notification failed: %v: %s", err, bytes.TrimSpace(out))
+	}
+	return nil
+}
+
+// Close releases the framebuffer
+func (o *OSD) Close() error {
+	if o.fb != nil {
+		return o.fb.file.Close()
+	}
+	return nil
+}
+
+// framebuffer is a Linux fbdev device drawn to with pread and pwrite
+type framebuffer struct {
+	file          *os.File
+	width, height int
+	// offset of the visible area's first row
+	top        int
+	lineLength int
+	bytesPP    int
+}
+
+func openFramebuffer(path string) (*framebuffer, error) {
+	file, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open framebuffer: %v", err)
+	}
+
+	var vinfo [fbVarSize]byte
+	var finfo [fbFixSize]byte
+	for _, q := range []struct {
+		req uintptr
+		buf []byte
+	}{{fbioGetVScreenInfo, vinfo[:]}, {fbioGetFScreenInfo, finfo[:]}} {
+		_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, file.Fd(), q.req, uintptr(unsafe.Pointer(&q.buf[0])))
+		if errno != 0 {
+			file.Close()
+			return nil, fmt.Errorf("failed to query framebuffer %s: %v", path, errno)
+		}
+	}
+
+	u32 := func(b []byte, off uintptr) int { return int(binary.NativeEndian.Uint32(b[off:])) }
+	fb := &framebuffer{
+		file:       file,
+		width:      u32(vinfo[:], 0),
+		height:     u32(vinfo[:], 4),
+		lineLength: u32(finfo[:], fbLineLength),
+		bytesPP:    u32(vinfo[:], 24) / 8,
+	}
+	fb.top = u32(vinfo[:], 20) * fb.lineLength
+	if fb.bytesPP != 2 && fb.bytesPP != 4 {
+		file.Close()
+		return nil, fmt.Errorf("unsupported framebuffer depth %d", fb.bytesPP*8)
+	}
+	return fb, nil
+}
+
+// flash draws white text on black at the top of the screen, waits and puts
+// back what was there
+func (fb *framebuffer) flash(text string, d time.Duration) error {
+	chars := []rune(strings.ToUpper(text))
+	scale := max(2, fb.width/120)
+	maxChars := max(1, (fb.width/scale-2*osdPadding)/osdAdvance)
+	if len(chars) > maxChars {
+		chars = chars[:maxChars]
+	}
+
+	boxW := (len(chars)*osdAdvance - 1 + 2*osdPadding) * scale
+	boxH := (7 + 2*osdPadding) * scale
+	x0 := (fb.width - boxW) / 2
+
+	// Render the box into rows of pixels first
+	rows := make([][]byte, boxH)
+	for y := range rows {
+		rows[y] = make([]byte, boxW*fb.bytesPP)
+		fy := y/scale - osdPadding
+		if fy < 0 || fy >= 7 {
+			continue
+		}
+		for x := 0; x < boxW; x++ {
+			fx := x/scale - osdPadding
+			if fx < 0 || fx/osdAdvance >= len(chars) {
+				continue
+			}
+			col := fx % osdAdvance
+			if col < 5 && font5x7[chars[fx/osdAdvance]][fy]&(1<<(4-col)) != 0 {
+				for i := 0; i < fb.bytesPP; i++ {
+					rows[y][x*fb.bytesPP+i] = 0xff
+				}
+			}
+		}
+	}
+
+	saved := make([][]byte, boxH)
+	for y := range rows {
+		off := int64(fb.top + y*fb.lineLength + x0*fb.bytesPP)
+		saved[y] = make([]byte, len(rows[y]))
+		if _, err := fb.file.ReadAt(saved[y], off); err != nil {
+			return fmt.Errorf("failed to read framebuffer: %v", err)
+		}
+	}
+	if err := fb.blit(x0, rows); err != nil {
+		return err
+	}
+	time.Sleep(d)
+	return fb.blit(x0, saved)
+}
+
+func (fb *framebuffer) blit(x0 int, rows [][]byte) error {
+	for y, row := range rows {
+		off := int64(fb.top + y*fb.lineLength + x0*fb.bytesPP)
+		if _, err := fb.file.WriteAt(row, off); err != nil {
+			return fmt.Errorf("failed to write framebuffer: %v", err)
+		}
+	}
+	return nil
+}
+
+// showMessages puts notices on the OSD until the subscription ends. Notices
+// arriving while a message is up are merged into the latest one.
+func (app *Application) showMessages(notices <-chan Notice) {
+	message := func(n Notice) string {
+		if !app.routed(n, SinkOSD) {
+			return ""
+		}
+		return app.Messages.Notice(n)
+	}
+	for n := range notices {
+		text := latestMessage(notices, message(n), message)
+		if text == "" {
+			continue
+		}
+		if err := app.OSD.Show(text); err != nil {
+			app.Logger.Printf("Failed to show %q: %v", text, err)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// overlayInterval is how often overlay clients are sent the pointer state
+// when it changed, about once per display frame
+const overlayInterval = 16 * time.Millisecond
+
+// OverlayServer feeds a companion overlay app that draws the cursor, since
+// some Android builds draw none for uinput pointers. Clients connect and read
+// one JSON object per line, sent on connect and whenever the state changes:
+//
+//	{"mouse_mode":true,"x":120,"y":160,"width":240,"height":320,
+//	 "drag":false,"scroll":false,"grid":{"x":0,"y":0,"width":240,"height":320}}
+//
+// x and y are the estimated cursor position in screen pixels of a width x
+// height screen in its current rotation. grid is only present in grid mode
+// and is the area its digits divide. Clients send nothing; the position is an
+// estimate, so the overlay should hide the cursor while mouse_mode is false.
+type OverlayServer struct {
+	app      *Application
+	listener net.Listener
+
+	mu    sync.Mutex
+	conns map[net.Conn]struct{}
+}
+
+// overlayFrame is one line of the overlay protocol
+type overlayFrame struct {
+	MouseMode bool         `json:"mouse_mode"`
+	X         int          `json:"x"`
+	Y         int          `json:"y"`
+	Width     int          `json:"width"`
+	Height    int          `json:"height"`
+	Drag      bool         `json:"drag"`
+	Scroll    bool         `json:"scroll"`
+	Grid      *overlayRect `json:"grid,omitempty"`
+}
+
+type overlayRect struct {
+	X      int `json:"x"`
+	Y      int `json:"y"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+// ListenOverlay listens on addr: "@name" for an abstract UNIX socket, which
+// apps can reach without file permissions, a path for a UNIX socket, or a
+// TCP address
+func ListenOverlay(app *Application, addr string) (*OverlayServer, error) {
+	network := "tcp"
+	if strings.HasPrefix(addr, "@") {
+		network = "unix"
+	}
+	if strings.HasPrefix(addr, "/") {
+		network = "unix"
+		if err := os.Remove(addr); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("failed to remove stale socket %s: %v", addr, err)
+		}
+	}
+	listener, err := net.Listen(network, addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %v", addr, err)
+	}
+	return &OverlayServer{app: app, listener: listener, conns: map[net.Conn]struct{}{}}, nil
+}
+
+// Serve accepts overlay clients until Close
+func (s *OverlayServer) Serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				s.app.Logger.Printf("Overlay socket stopped: %v", err)
+			}
+			return
+		}
+		s.mu.Lock()
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+		go s.stream(conn)
+	}
+}
+
+// stream sends frames to one client until it goes away
+func (s *OverlayServer) stream(conn net.Conn) {
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		conn.Close()
+	}()
+
+	// Clients send nothing, so a finished read means they left
+	closed := make(chan struct{})
+	go func() {
+		io.Copy(io.Discard, conn)
+		close(closed)
+	}()
+
+	ticker := time.NewTicker(overlayInterval)
+	defer ticker.Stop()
+
+	enc := json.NewEncoder(conn)
+	var last overlayFrame
+	first := true
+	for {
+		frame := s.frame()
+		if first || !frame.equal(last) {
+			conn.SetWriteDeadline(time.Now().Add(time.Second))
+			if err := enc.Encode(frame); err != nil {
+				return
+			}
+			last, first = frame, false
+		}
+
+		select {
+		case <-closed:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// frame takes a snapshot of the primary pointer
+func (s *OverlayServer) frame() overlayFrame {
+	mc := s.app.MouseController
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	x, y := mc.Position()
+	w, h := mc.Screen.Logical()
+	frame := overlayFrame{
+		MouseMode: mc.State.MouseMode,
+		X:         int(x),
+		Y:         int(y),
+		Width:     w,
+		Height:    h,
+		Drag:      mc.State.DragButton != 0,
+		Scroll:    mc.State.ScrollLayerActive,
+	}
+	if mc.State.GridMode {
+		r := mc.State.GridRect
+		frame.Grid = &overlayRect{X: int(r.X), Y: int(r.Y), Width: int(r.Width), Height: int(r.Height)}
+	}
+	return frame
+}
+
+func (f overlayFrame) equal(o overlayFrame) bool {
+	grid, otherGrid := f.Grid, o.Grid
+	f.Grid, o.Grid = nil, nil
+	if f != o || (grid == nil) != (otherGrid == nil) {
+		return false
+	}
+	return grid == nil || *grid == *otherGrid
+}
+
+// Close stops accepting clients and disconnects the connected ones
+func (s *OverlayServer) Close() error {
+	err := s.listener.Close()
+	s.mu.Lock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+	return err
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/goFlipMouse/vdev"
+	evdev "github.com/grafov/evdev"
+)
+
+// handleEvent runs one event from the event loop through the EventProcessor
+// and returns the decision taken on it
+func (dm *DeviceManager) handleEvent(device *InputDevice, event *evdev.InputEvent) int {
+	if event.Type == EvSw && event.Code == SwLid {
+		dm.setLidClosed(event.Value != 0)
+	}
+	if device.Passive {
+		return MuteEvent
+	}
+	// The OS reads the keypad itself while the flip is closed, so handling
+	// its keys here too would deliver them twice
+	if dm.grabsPaused() {
+		return MuteEvent
+	}
+	if event.Type != EvSyn && dm.Notices != nil && dm.Notices.Watched() {
+		dm.notify(Notice{Kind: "input", Device: device.Name, Type: event.Type, Code: event.Code, Value: event.Value})
+	}
+
+	// Process the event
+	result := dm.EventProcessor.ProcessEvent(event, device)
+	if dm.Trace != nil {
+		if err := dm.Trace.Record(device, event, result); err != nil {
+			dm.reportError("Stopped recording: %v", err)
+			dm.Trace.Close()
+			dm.Trace = nil
+		}
+	}
+
+	// Handle event result
+	switch result {
+	case PassThruEvent:
+		dm.forward(device, event)
+	case ReplayEvent:
+		dm.replayPress(device, event)
+		dm.forward(device, event)
+	default:
+		dm.Logger.Debug("Intercepted event. Result: %d\n", result)
+	}
+	return result
+}
+
+// forward collects a passed through event and queues the whole frame for the
+// virtual keyboard once its SYN_REPORT arrives
+func (dm *DeviceManager) forward(device *InputDevice, event *evdev.InputEvent) {
+	if event.Type != EvSyn {
+		if !device.dropping {
+			device.frame = append(device.frame, vdev.Event{Time: event.Time, Type: event.Type, Code: event.Code, Value: event.Value})
+		}
+		return
+	}
+
+	frame := device.frame
+	device.frame = nil
+
+	// The kernel dropped events; discard everything up to the next SYN_REPORT
+	if event.Code == vdev.SynDropped {
+		device.dropping = true
+		return
+	}
+	if device.dropping {
+		device.dropping = false
+		return
+	}
+
+	// Skip frames whose key events were all intercepted
+	if !hasPayload(frame) {
+		return
+	}
+
+	frame = append(frame, vdev.Event{Time: event.Time, Type: event.Type, Code: event.Code, Value: event.Value})
+	dm.emitFrame(device, frame)
+}
+
+// replayPress queues the press that belongs to a release, as a frame of its own
+func (dm *DeviceManager) replayPress(device *InputDevice, release *evdev.InputEvent) {
+	dm.emitFrame(device, []vdev.Event{
+		{Time: release.Time, Type: EvKey, Code: release.Code, Value: KeyPressed},
+		{Time: release.Time, Type: EvSyn, Code: SynReport, Value: 0},
+	})
+}
+
+// emitFrame queues a complete frame for the virtual keyboard
+func (dm *DeviceManager) emitFrame(device *InputDevice, frame []vdev.Event) {
+	ep := dm.EventProcessor
+	ep.Emitter.Do(func() error {
+		if err := ep.VirtualKeyboard.SendFrame(frame); err != nil {
+			return fmt.Errorf("failed to forward event frame from %s: %v", device.Name, err)
+		}
+		ep.trackModifiers(frame)
+		return nil
+	})
+}
+
+// hasPayload reports whether a frame carries more than scan codes. A scan
+// code on its own belongs to a key that was intercepted.
+func hasPayload(frame []vdev.Event) bool {
+	for _, e := range frame {
+		if e.Type != EvMsc {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os/exec"
+	"sync/atomic"
+	"time"
+
+	evdev "github.com/grafov/evdev"
+)
+
+// pluginTimeout is how long a key event waits for a plugin's decision
+// before being handled as if the plugin said "continue"
+const pluginTimeout = 50 * time.Millisecond
+
+// pluginQueue is how many events may wait for a plugin to read them. When it
+// is full the plugin is skipped, so one that stops reading cannot block the loop.
+const pluginQueue = 16
+
+// pluginStopTimeout is how long a plugin gets to exit after its stdin closes
+const pluginStopTimeout = time.Second
+
+// PluginConfig starts a plugin: a program speaking JSON lines on stdio.
+//
+// For every key event (only Keys, if given) the plugin receives
+//
+//	{"id": 7, "device": "mtk-kpd", "code": 2, "value": 1, "mouse_mode": true}
+//
+// and answers with the same id, a decision and optional commands:
+//
+//	{"id": 7, "decision": "mute", "commands": ["click left"]}
+//
+// Decisions are "continue" (default processing), "mute" (swallow) and
+// "pass" (send to the OS unchanged). A line without an id, such as
+// {"commands": ["toggle"]}, runs commands at any time.
+type PluginConfig struct {
+	Command []string `json:"command"`
+	Keys    []uint16 `json:"keys"`
+}
+
+// pluginEvent is sent to a plugin
+type pluginEvent struct {
+	ID        uint64 `json:"id"`
+	Device    string `json:"device"`
+	Code      uint16 `json:"code"`
+	Value     int32  `json:"value"`
+	MouseMode bool   `json:"mouse_mode"`
+}
+
+// pluginReply is read from a plugin
+type pluginReply struct {
+	ID       uint64   `json:"id"`
+	Decision string   `json:"decision"`
+	Commands []string `json:"commands"`
+}
+
+// Plugin is a running plugin process
+type Plugin struct {
+	app     *Application
+	name    string
+	keys    map[uint16]bool
+	cmd     *exec.Cmd
+	stdin   io.WriteCloser
+	replies chan pluginReply
+	nextID  uint64
+	dead    atomic.Bool
+	exited  chan struct{}
+
+	// outbox holds the events write has yet to send
+	outbox chan []byte
+}
+
+// StartPlugin launches a plugin process
+func StartPlugin(app *Application, config PluginConfig) (*Plugin, error) {
+	if len(config.Command) == 0 {
+		return nil, fmt.Errorf("plugin without a command")
+	}
+
+	p := &Plugin{
+		app:     app,
+		name:    config.Command[0],
+		cmd:     exec.Command(config.Command[0], config.Command[1:]...),
+		outbox:  make(chan []byte, pluginQueue),
+		replies: make(chan pluginReply, 1),
+		exited:  make(chan struct{}),
+	}
+	if len(config.Keys) > 0 {
+		p.keys = map[uint16]bool{}
+		for _, code := range config.Keys {
+			p.keys[code] = true
+		}
+	}
+
+	var err error
+	if p.stdin, err = p.cmd.StdinPipe(); err != nil {
+		return nil, fmt.Errorf("failed to start plugin %s: %v", p.name, err)
+	}
+	stdout, err := p.cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start plugin %s: %v", p.name, err)
+	}
+	p.cmd.Stderr = app.LogFile
+	if err := p.cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start plugin %s: %v", p.name, err)
+	}
+
+	go p.read(stdout)
+	go p.write()
+	return p, nil
+}
+
+// write sends queued events to the plugin until Stop, then closes its stdin
+func (p *Plugin) write() {
+	for msg := range p.outbox {
+		if _, err := p.stdin.Write(msg); err != nil {
+			p.app.Logger.Printf("Plugin %s stopped reading: %v", p.name, err)
+			p.dead.Store(true)
+			break
+		}
+	}
+	p.stdin.Close()
+	// Drain, so Middleware never blocks however the plugin went away
+	for range p.outbox {
+	}
+}
+
+// read handles the plugin's output until it exits
+func (p *Plugin) read(stdout io.Reader) {
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		var reply pluginReply
+		if err := json.Unmarshal(scanner.Bytes(), &reply); err != nil {
+			p.app.Logger.Printf("Plugin %s sent invalid JSON: %v", p.name, err)
+			continue
+		}
+
+		if reply.ID == 0 {
+			p.runCommands(reply.Commands)
+			continue
+		}
+		// Keep only the newest reply; a late one for an old event is worthless
+		select {
+		case <-p.replies:
+		default:
+		}
+		p.replies <- reply
+	}
+
+	p.dead.Store(true)
+	p.app.Logger.Printf("Plugin %s exited: %v", p.name, p.cmd.Wait())
+	close(p.exited)
+}
+
+// Middleware is the plugin dispatch stage: it asks the plugin what to do
+// with each key event it is interested in
+func (p *Plugin) Middleware(event *evdev.InputEvent, device *InputDevice) Decision {
+	if event.Type != EvKey || p.dead.Load() || (p.keys != nil && !p.keys[event.Code]) {
+		return Continue
+	}
+
+	p.nextID++
+	msg, _ := json.Marshal(pluginEvent{
+		ID:        p.nextID,
+		Device:    device.Name,
+		Code:      event.Code,
+		Value:     event.Value,
+		MouseMode: p.app.EventProcessor.inMouseMode(device),
+	})
+	select {
+	case p.outbox <- append(msg, '\n'):
+	default:
+		p.app.Logger.Debug("Plugin %s is not reading, skipped\n", p.name)
+		return Continue
+	}
+
+	timeout := time.NewTimer(pluginTimeout)
+	defer timeout.Stop()
+	for {
+		select {
+		case reply := <-p.replies:
+			if reply.ID != p.nextID {
+				continue
+			}
+			p.runCommands(reply.Commands)
+			switch reply.Decision {
+			case "mute":
+				return Mute
+			case "pass":
+				return PassThru
+			}
+			return Continue
+		case <-timeout.C:
+			p.app.Logger.Debug("Plugin %s did not answer in time\n", p.name)
+			return Continue
+		}
+	}
+}
+
+func (p *Plugin) runCommands(commands []string) {
+	for _, command := range commands {
+		if _, err := p.app.executeOnLoop(command); err != nil {
+			p.app.Logger.Printf("Plugin %s command %q failed: %v", p.name, command, err)
+		}
+	}
+}
+
+// Stop closes the plugin's stdin once the queued events are written, which
+// asks it to exit, and kills it if it is still running after
+// pluginStopTimeout. The event loop must have stopped.
+func (p *Plugin) Stop() {
+	close(p.outbox)
+	select {
+	case <-p.exited:
+	case <-time.After(pluginStopTimeout):
+		p.cmd.Process.Kill()
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import "math"
+
+// MoveBy moves the pointer relatively and updates the position estimate.
+// Absolute backends are driven from the estimate so both stay in step.
+func (mc *MouseController) MoveBy(dx, dy int32) error {
+	x, y := mc.State.PosX+float64(dx), mc.State.PosY+float64(dy)
+
+	var err error
+	if abs, ok := mc.Mouse.(AbsolutePointerOutput); ok {
+		err = abs.MoveTo(mc.Screen.ToPanel(int32(x), int32(y)))
+	} else {
+		err = mc.Mouse.Move(dx, dy)
+	}
+	mc.setPosition(x, y)
+	return err
+}
+
+// Position returns the estimated cursor position in screen pixels
+func (mc *MouseController) Position() (float64, float64) {
+	return mc.State.PosX, mc.State.PosY
+}
+
+// CenterPosition resets the estimate to the screen centre, where Android
+// places the cursor when a pointer device appears
+func (mc *MouseController) CenterPosition() {
+	w, h := mc.Screen.Logical()
+	mc.setPosition(float64(w)/2, float64(h)/2)
+}
+
+// setPosition stores a new estimate, clamped to the screen bounds
+func (mc *MouseController) setPosition(x, y float64) {
+	w, h := mc.Screen.Logical()
+	if w > 0 {
+		x = math.Max(0, math.Min(x, float64(w-1)))
+	}
+	if h > 0 {
+		y = math.Max(0, math.Min(y, float64(h-1)))
+	}
+	mc.State.PosX = x
+	mc.State.PosY = y
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+)
+
+// Profile bundles pointer tuning that can be switched at runtime. Zero
+// numeric fields keep the default value, or the feel's.
+type Profile struct {
+	Name string `json:"name"`
+	// Feel starts from a preset ("crisp", "floaty" or "stepped", see
+	// feel.go) that the fields below refine
+	Feel           string  `json:"feel"`
+	MaxSpeed       float64 `json:"max_speed"`
+	Acceleration   float64 `json:"acceleration"`
+	Friction       float64 `json:"friction"`
+	ScrollMaxSpeed float64 `json:"scroll_max_speed"`
+	MoveRate       int     `json:"move_rate"`
+	ScrollRate     int     `json:"scroll_rate"`
+	// NaturalScroll inverts both wheels so content follows the key, like a touch screen
+	NaturalScroll bool `json:"natural_scroll"`
+	// Fling lets the wheel coast after release, slowing by FlingFriction each tick
+	Fling         bool    `json:"fling"`
+	FlingFriction float64 `json:"fling_friction"`
+	// ScrollLayer has the scroll layer on while the profile is applied, and
+	// off without it
+	ScrollLayer bool `json:"scroll_layer"`
+}
+
+// FindProfile looks up a configured profile by name
+func (c Config) FindProfile(name string) (Profile, error) {
+	for _, p := range c.Profiles {
+		if p.Name == name {
+			return p, nil
+		}
+	}
+	return Profile{}, fmt.Errorf("unknown profile %q", name)
+}
+
+// ApplyProfile loads a profile's tuning into the mouse state. It starts from
+// the defaults, so nothing is left over from the profile before. The speed
+// is set quietly, as a profile switch is announced on its own.
+func (mc *MouseController) ApplyProfile(p Profile) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	base := NewMouseState()
+	mc.State.Acceleration = base.Acceleration
+	mc.State.Friction = base.Friction
+	mc.State.SpeedMulti = base.SpeedMulti
+	mc.State.ScrollMaxSpeed = base.ScrollMaxSpeed
+	mc.State.FlingFriction = base.FlingFriction
+
+	if feel, err := findFeel(p.Feel); err == nil {
+		mc.applyFeel(feel)
+	}
+	mc.State.MaxSpeed = max(cmp.Or(p.MaxSpeed, base.MaxSpeed), 1)
+	if p.Acceleration > 0 {
+		mc.State.Acceleration = p.Acceleration
+	}
+	if p.Friction > 0 {
+		mc.State.Friction = p.Friction
+	}
+	if p.ScrollMaxSpeed > 0 {
+		mc.State.ScrollMaxSpeed = p.ScrollMaxSpeed
+	}
+	if p.FlingFriction > 0 && p.FlingFriction < 1 {
+		mc.State.FlingFriction = p.FlingFriction
+	}
+	mc.State.NaturalScroll = p.NaturalScroll
+	mc.State.Fling = p.Fling
+	mc.Profile = p.Name
+	if p.ScrollLayer != mc.State.ScrollLayerActive {
+		mc.ToggleScrollLayer()
+	}
+}
+
+// Profile returns the name of the active profile
+func (app *Application) Profile() string {
+	app.mu.Lock()
+	defer app.mu.Unlock()
+	return app.ActiveProfile
+}
+
+// CycleProfile switches to the profile after the active one in the config
+func (app *Application) CycleProfile() error {
+	app.mu.Lock()
+	profiles := app.Config.Profiles
+	app.mu.Unlock()
+	if len(profiles) == 0 {
+		return fmt.Errorf("no profiles configured")
+	}
+
+	active := app.Profile()
+	next := 0
+	for i, p := range profiles {
+		if p.Name == active {
+			next = (i + 1) % len(profiles)
+			break
+		}
+	}
+	return app.SetProfile(profiles[next].Name)
+}
+
+// SetProfile applies the named profile to every pointer
+func (app *Application) SetProfile(name string) error {
+	app.mu.Lock()
+	config := app.Config
+	app.mu.Unlock()
+	profile, err := config.FindProfile(name)
+	if err != nil {
+		return err
+	}
+
+	app.MouseController.ApplyProfile(profile)
+	for _, mc := range app.ExtraControllers {
+		mc.ApplyProfile(profile)
+	}
+	app.mu.Lock()
+	app.ActiveProfile = profile.Name
+	app.mu.Unlock()
+	app.Notices.Publish(Notice{Kind: "profile", Profile: profile.Name})
+
+	// Profiles without their own rates fall back to their feel's, then
+	// the global ones
+	moveRate, scrollRate := profile.MoveRate, profile.ScrollRate
+	if feel, err := findFeel(profile.Feel); err == nil {
+		moveRate = cmp.Or(moveRate, feel.MoveRate)
+		scrollRate = cmp.Or(scrollRate, feel.ScrollRate)
+	}
+	if moveRate <= 0 {
+		moveRate = config.MoveRate
+	}
+	if scrollRate <= 0 {
+		scrollRate = config.ScrollRate
+	}
+	app.DeviceManager.SetTickRates(moveRate, scrollRate)
+	return nil
+}
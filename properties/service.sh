@@ -1,2 +1,2 @@
 echo "Hello from goFlipMouse!" > /cache/goFlipMouse.log &
-"${0%/*}/mouse" &
+"${0%/*}/mouse" -android &
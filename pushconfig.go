@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/goFlipMouse/vdev"
+)
+
+// Settings taken from a config pushed with the load-config command. The
+// rest, such as devices, backends and the control interfaces, keeps its
+// running value until a restart.
+//
+//	long_press_duration, double_click_delay, hold_click_duration,
+//	exit_confirm, exit_confirm_window,
+//	multi_tap_timeout, remaps, snippets, leader_key, leader_timeout,
+//	leader_sequences, profiles, profile, app_profiles, move_rate, scroll_rate,
+//	feedback
+
+// ParseConfig reads a JSON config on top of the defaults and validates it.
+// Unlike LoadConfig it rejects unknown keys, which are likely typos.
+func ParseConfig(data []byte) (Config, error) {
+	config := defaults()
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&config); err != nil {
+		return config, fmt.Errorf("failed to parse config: %v", err)
+	}
+	keepGivenScreen(data, &config)
+	return config, config.Validate()
+}
+
+// Validate finds mistakes that would otherwise only show when a key is pressed
+func (c Config) Validate() error {
+	var problems []string
+	problem := func(format string, args ...any) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	names := map[string]bool{}
+	for _, p := range c.Profiles {
+		if p.Name == "" {
+			problem("a profile has no name")
+		} else if names[p.Name] {
+			problem("profile %q is defined twice", p.Name)
+		}
+		names[p.Name] = true
+		if p.Feel != "" {
+			if _, err := findFeel(p.Feel); err != nil {
+				problem("profile %q: %v", p.Name, err)
+			}
+		}
+	}
+	if c.Profile != "" && !names[c.Profile] {
+		problem("profile %q is not defined", c.Profile)
+	}
+	for pkg, name := range c.AppProfiles {
+		if !names[name] {
+			problem("profile %q for %s is not defined", name, pkg)
+		}
+	}
+
+	validKey := func(code uint16) bool { return code > 0 && code <= vdev.KeyMax }
+	for _, r := range c.Remaps {
+		if !validKey(r.From) || (r.To != 0 && !validKey(r.To)) || (r.LongPress != 0 && !validKey(r.LongPress)) {
+			problem("remap of key %d uses an invalid key code", r.From)
+		}
+	}
+	for _, s := range c.Snippets {
+		if !validKey(s.Key) {
+			problem("snippet key %d is invalid", s.Key)
+		}
+	}
+	for _, seq := range c.LeaderSequences {
+		if len(seq.Keys) == 0 {
+			problem("leader sequence for %q has no keys", seq.Command)
+		}
+	}
+
+	problems = append(problems, c.Feedback.validate()...)
+
+	for _, layer := range c.ScreenReader.DPad {
+		if layer != LayerMove && layer != LayerScroll {
+			problem("screen_reader dpad layer %q is neither %q nor %q", layer, LayerMove, LayerScroll)
+		}
+	}
+
+	switch c.ExitConfirm {
+	case "", ExitConfirmDouble, ExitConfirmLong:
+	default:
+		problem("exit_confirm must be %q, %q or empty", ExitConfirmDouble, ExitConfirmLong)
+	}
+
+	if c.MoveRate <= 0 || c.ScrollRate <= 0 {
+		problem("move_rate and scroll_rate must be positive")
+	}
+	for name, d := range map[string]Duration{
+		"long_press_duration": c.LongPressDuration,
+		"double_click_delay":  c.DoubleClickDelay,
+		"exit_confirm_window": c.ExitConfirmWindow,
+		"hold_click_duration": c.HoldClickDuration,
+		"multi_tap_timeout":   c.MultiTapTimeout,
+		"leader_timeout":      c.LeaderTimeout,
+	} {
+		if d.Duration < 0 {
+			problem("%s is negative", name)
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid config: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// applyLive copies the settings a pushed config replaces from src
+func (c *Config) applyLive(src Config) {
+	c.LongPressDuration = src.LongPressDuration
+	c.DoubleClickDelay = src.DoubleClickDelay
+	c.ExitConfirm = src.ExitConfirm
+	c.ExitConfirmWindow = src.ExitConfirmWindow
+	c.HoldClickDuration = src.HoldClickDuration
+	c.MultiTapTimeout = src.MultiTapTimeout
+	c.Remaps = src.Remaps
+	c.Snippets = src.Snippets
+	c.LeaderKey = src.LeaderKey
+	c.LeaderTimeout = src.LeaderTimeout
+	c.LeaderSequences = src.LeaderSequences
+	c.Profiles = src.Profiles
+	c.Profile = src.Profile
+	c.AppProfiles = src.AppProfiles
+	c.MoveRate = src.MoveRate
+	c.ScrollRate = src.ScrollRate
+	c.Feedback = src.Feedback
+}
+
+// PushConfig validates a config and swaps in its live settings all at once,
+// between two input events, feedback routing included. Nothing changes if it
+// is invalid. The active profile is applied again, or the config's profile if
+// it is gone.
+func (app *Application) PushConfig(data []byte) error {
+	config, err := ParseConfig(data)
+	if err != nil {
+		return err
+	}
+
+	app.DeviceManager.OnLoop(func() {
+		ep := app.EventProcessor
+		ep.Config.applyLive(config)
+		ep.remapper.SetRules(config.remapRules())
+		app.leader.Configure(config)
+
+		app.mu.Lock()
+		app.Config.applyLive(config)
+		app.mu.Unlock()
+	})
+
+	if len(config.AppProfiles) > 0 {
+		app.watchApps.Do(func() { go app.watchForegroundApp() })
+	}
+
+	profile := app.Profile()
+	if _, err := config.FindProfile(profile); err != nil {
+		profile = config.Profile
+	}
+	if profile == "" {
+		return nil
+	}
+	return app.SetProfile(profile)
+}
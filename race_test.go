@@ -0,0 +1,71 @@
+package main
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// raceRounds is how often each goroutine below acts on the controller
+const raceRounds = 500
+
+// The event loop, the movement ticks, hold-click timers, profile switches
+// and commands all reach the controller from their own goroutines. Run with
+// -race to check they only touch its state under mc.mu.
+func TestControllerRace(t *testing.T) {
+	app, device, mouse := newTestApp(t, defaults())
+	mc, dm := app.MouseController, app.DeviceManager
+	mc.mu.Lock()
+	mc.State.MouseMode = true
+	mc.mu.Unlock()
+
+	var wg sync.WaitGroup
+	run := func(step func(i int)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < raceRounds; i++ {
+				step(i)
+			}
+		}()
+	}
+
+	// Device state belongs to the event loop, so a single goroutine feeds
+	// events, as the loop would
+	run(func(i int) {
+		code := uint16(testUpKey)
+		if i%4 == 0 {
+			code = testEnterKey
+		}
+		app.EventProcessor.ProcessEvent(key(code, KeyPressed), device)
+		app.EventProcessor.ProcessEvent(key(code, KeyReleased), device)
+	})
+	run(func(int) {
+		dm.moveController(mc)
+		dm.scrollController(mc)
+	})
+	run(func(int) {
+		mc.mu.Lock()
+		mc.HoldClick(time.Microsecond)
+		mc.mu.Unlock()
+	})
+	run(func(i int) {
+		mc.ApplyProfile(Profile{MaxSpeed: float64(1 + i%8), Fling: i%2 == 0})
+	})
+	run(func(i int) {
+		// A few toggles are enough to race with the rest
+		if i%10 != 0 {
+			return
+		}
+		if _, err := app.Execute("toggle"); err != nil {
+			t.Error(err)
+		}
+	})
+	wg.Wait()
+
+	// Let the last hold-click timers run out
+	time.Sleep(10 * time.Millisecond)
+	if len(mouse.take()) == 0 {
+		t.Error("the pointer was never used")
+	}
+}
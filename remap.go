@@ -0,0 +1,141 @@
+package main
+
+import (
+	"time"
+
+	evdev "github.com/grafov/evdev"
+)
+
+// RemapRule rewrites a key while mouse mode is off
+type RemapRule struct {
+	// Device limits the rule to one device name or path; empty matches all
+	Device string `json:"device"`
+	From   uint16 `json:"from"`
+	// To replaces From; 0 keeps From
+	To uint16 `json:"to"`
+	// LongPress, if set, is tapped instead when From is held for
+	// Config.LongPressDuration. Short presses then tap To on release.
+	LongPress uint16 `json:"long_press"`
+	// LongPressText is typed on a long press instead, see Snippet
+	LongPressText string `json:"long_press_text"`
+}
+
+func (r RemapRule) matches(event *evdev.InputEvent, device *InputDevice) bool {
+	return event.Code == r.From && (r.Device == "" || r.Device == device.Name || r.Device == device.Path)
+}
+
+// hasLongPress reports whether the rule acts differently on a long press
+func (r RemapRule) hasLongPress() bool {
+	return r.LongPress != 0 || r.LongPressText != ""
+}
+
+// target is the code a short press turns into
+func (r RemapRule) target() uint16 {
+	if r.To != 0 {
+		return r.To
+	}
+	return r.From
+}
+
+// remapKey identifies a physical key on a device
+type remapKey struct {
+	device *InputDevice
+	code   uint16
+}
+
+// Remapper applies RemapRules to passed through keys
+type Remapper struct {
+	ep    *EventProcessor
+	rules []RemapRule
+
+	// Rules whose key is down, so the release is remapped like the press
+	// even if mouse mode changed in between
+	active  map[remapKey]RemapRule
+	pressed map[remapKey]time.Time
+}
+
+// NewRemapper creates a remapper for the given rules
+func NewRemapper(ep *EventProcessor, rules []RemapRule) *Remapper {
+	return &Remapper{
+		ep:      ep,
+		rules:   rules,
+		active:  map[remapKey]RemapRule{},
+		pressed: map[remapKey]time.Time{},
+	}
+}
+
+// Middleware remaps key events outside mouse mode
+func (r *Remapper) Middleware(event *evdev.InputEvent, device *InputDevice) Decision {
+	if event.Type != EvKey {
+		return Continue
+	}
+
+	key := remapKey{device, event.Code}
+	rule, ok := r.active[key]
+	if !ok {
+		if event.Value != KeyPressed || r.ep.inMouseMode(device) {
+			return Continue
+		}
+		if rule, ok = r.find(event, device); !ok {
+			return Continue
+		}
+		r.active[key] = rule
+	}
+	if event.Value == KeyReleased {
+		delete(r.active, key)
+	}
+
+	if !rule.hasLongPress() {
+		event.Code = rule.target()
+		return Continue
+	}
+
+	// Long press rules decide on release and tap the chosen key
+	switch event.Value {
+	case KeyPressed:
+		r.pressed[key] = time.Now()
+	case KeyReleased:
+		long := time.Since(r.pressed[key]) > r.ep.Config.LongPressDuration.Duration
+		delete(r.pressed, key)
+		switch {
+		case long && rule.LongPressText != "":
+			r.typeText(rule.LongPressText)
+		case long:
+			r.tap(rule.LongPress)
+		default:
+			r.tap(rule.target())
+		}
+	}
+	return Mute
+}
+
+// SetRules replaces the rules. Keys that are down stay remapped by the rule
+// they were pressed with.
+func (r *Remapper) SetRules(rules []RemapRule) {
+	r.rules = rules
+}
+
+func (r *Remapper) find(event *evdev.InputEvent, device *InputDevice) (RemapRule, bool) {
+	for _, rule := range r.rules {
+		if rule.matches(event, device) {
+			return rule, true
+		}
+	}
+	return RemapRule{}, false
+}
+
+// typeText queues text to be typed on the virtual keyboard
+func (r *Remapper) typeText(text string) {
+	kbd := r.ep.VirtualKeyboard
+	r.ep.Emitter.Do(func() error {
+		return kbd.TypeString(text)
+	})
+}
+
+// tap queues a full key press on the virtual keyboard
+func (r *Remapper) tap(code uint16) {
+	kbd := r.ep.VirtualKeyboard
+	r.ep.Emitter.Do(func() error {
+		return kbd.KeyPress(int(code))
+	})
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/goFlipMouse/vdev"
+)
+
+// Keys of the laptop keymap used below
+const (
+	testToggleKey    = 29
+	testEnterKey     = 28
+	testDragKey      = 32
+	testUpKey        = 103
+	testFasterKey    = 13
+	testSlowerKey    = 12
+	testPrecisionKey = 42
+)
+
+// repeatStep is one event of a key and what it should lead to
+type repeatStep struct {
+	value    int32
+	decision int
+	calls    []string
+	check    func(*MouseState) bool
+}
+
+func TestRepeatSemantics(t *testing.T) {
+	speed := func(want float64) func(*MouseState) bool {
+		return func(s *MouseState) bool { return s.MaxSpeed == want }
+	}
+	up := func(want bool) func(*MouseState) bool {
+		return func(s *MouseState) bool { return s.UpKeyActive == want }
+	}
+	precision := func(want bool) func(*MouseState) bool {
+		return func(s *MouseState) bool { return s.PrecisionActive == want }
+	}
+	press := fmt.Sprintf("press %d", vdev.BtnLeft)
+	release := fmt.Sprintf("release %d", vdev.BtnLeft)
+
+	tests := []struct {
+		name      string
+		code      uint16
+		mouseMode bool
+		steps     []repeatStep
+	}{
+		{"direction held through repeats", testUpKey, true, []repeatStep{
+			{value: KeyPressed, decision: MuteEvent, check: up(true)},
+			{value: KeyRepeated, decision: MuteEvent, check: up(true)},
+			{value: KeyRepeated, decision: MuteEvent, check: up(true)},
+			{value: KeyReleased, decision: MuteEvent, check: up(false)},
+		}},
+		{"precision held through repeats", testPrecisionKey, true, []repeatStep{
+			{value: KeyPressed, decision: MuteEvent, check: precision(true)},
+			{value: KeyRepeated, decision: MuteEvent, check: precision(true)},
+			{value: KeyReleased, decision: MuteEvent, check: precision(false)},
+		}},
+		{"faster steps on every repeat", testFasterKey, true, []repeatStep{
+			{value: KeyPressed, decision: MuteEvent, check: speed(5)},
+			{value: KeyRepeated, decision: MuteEvent, check: speed(6)},
+			{value: KeyRepeated, decision: MuteEvent, check: speed(7)},
+			{value: KeyReleased, decision: MuteEvent, check: speed(7)},
+		}},
+		{"slower steps on every repeat", testSlowerKey, true, []repeatStep{
+			{value: KeyPressed, decision: MuteEvent, check: speed(3)},
+			{value: KeyRepeated, decision: MuteEvent, check: speed(2)},
+			{value: KeyRepeated, decision: MuteEvent, check: speed(1)},
+			{value: KeyRepeated, decision: MuteEvent, check: speed(1)},
+			{value: KeyReleased, decision: MuteEvent, check: speed(1)},
+		}},
+		{"click button follows the key", testEnterKey, true, []repeatStep{
+			{value: KeyPressed, decision: MuteEvent, calls: []string{press}},
+			{value: KeyRepeated, decision: MuteEvent},
+			{value: KeyReleased, decision: MuteEvent, calls: []string{release}},
+		}},
+		{"drag toggles on the press only", testDragKey, true, []repeatStep{
+			{value: KeyPressed, decision: MuteEvent, calls: []string{press}},
+			{value: KeyRepeated, decision: MuteEvent},
+			{value: KeyRepeated, decision: MuteEvent},
+			{value: KeyReleased, decision: MuteEvent},
+		}},
+		{"keys pass through outside mouse mode", testUpKey, false, []repeatStep{
+			{value: KeyPressed, decision: PassThruEvent, check: up(false)},
+			{value: KeyRepeated, decision: PassThruEvent, check: up(false)},
+			{value: KeyReleased, decision: PassThruEvent, check: up(false)},
+		}},
+		{"short toggle press is replayed", testToggleKey, false, []repeatStep{
+			{value: KeyPressed, decision: MuteEvent},
+			{value: KeyRepeated, decision: MuteEvent},
+			{value: KeyReleased, decision: ReplayEvent},
+		}},
+		{"toggle release without a press passes", testToggleKey, false, []repeatStep{
+			{value: KeyReleased, decision: PassThruEvent},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, device, mouse := newTestApp(t, defaults())
+			mc := app.MouseController
+			mc.State.MouseMode = tt.mouseMode
+			for i, step := range tt.steps {
+				got := app.EventProcessor.ProcessEvent(key(tt.code, step.value), device)
+				if got != step.decision {
+					t.Errorf("step %d (value %d): decision %s, want %s", i, step.value, decisionName(got), decisionName(step.decision))
+				}
+				if calls := mouse.take(); !slices.Equal(calls, step.calls) {
+					t.Errorf("step %d (value %d): pointer calls %q, want %q", i, step.value, calls, step.calls)
+				}
+				if step.check != nil && !step.check(mc.State) {
+					t.Errorf("step %d (value %d): unexpected state %+v", i, step.value, *mc.State)
+				}
+			}
+		})
+	}
+}
+
+// A toggle key whose press was missed, e.g. held while starting, is armed
+// by its first repeat, so a long hold still toggles mouse mode
+func TestToggleRepeatRearms(t *testing.T) {
+	app, device, _ := newTestApp(t, defaults())
+	ep, mc := app.EventProcessor, app.MouseController
+
+	if got := ep.ProcessEvent(key(testToggleKey, KeyRepeated), device); got != MuteEvent {
+		t.Fatalf("first repeat: decision %s, want mute", decisionName(got))
+	}
+	if !mc.State.ToggleKeyDown {
+		t.Fatal("first repeat did not arm the toggle key")
+	}
+	armed := mc.State.ToggleKeyDownTime
+	if got := ep.ProcessEvent(key(testToggleKey, KeyRepeated), device); got != MuteEvent {
+		t.Fatalf("second repeat: decision %s, want mute", decisionName(got))
+	}
+	if mc.State.ToggleKeyDownTime != armed {
+		t.Fatal("a later repeat restarted the long press")
+	}
+
+	// Held for longer than a long press
+	mc.State.ToggleKeyDownTime = time.Now().Add(-2 * ep.Config.LongPressDuration.Duration)
+	if got := ep.ProcessEvent(key(testToggleKey, KeyReleased), device); got != MuteEvent {
+		t.Fatalf("release: decision %s, want mute", decisionName(got))
+	}
+	if !mc.State.MouseMode {
+		t.Fatal("long hold did not turn mouse mode on")
+	}
+	if mc.State.ToggleKeyDown {
+		t.Fatal("release left the toggle key armed")
+	}
+}
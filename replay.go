@@ -0,0 +1,350 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/goFlipMouse/keymaps"
+	"github.com/goFlipMouse/vdev"
+)
+
+// replayTail is how long a replay keeps ticking after the last event, for
+// a gliding pointer to come to a stop
+const replayTail = 2 * time.Second
+
+// decisionNames name ProcessEvent's results in replay reports
+var decisionNames = map[int]string{
+	ChangedToMouse: "mouse",
+	MuteEvent:      "mute",
+	PassThruEvent:  "pass",
+	ChangedEvent:   "changed",
+	ReplayEvent:    "replay",
+}
+
+// decisionName names a ProcessEvent result, or gives its number
+func decisionName(decision int) string {
+	if name, ok := decisionNames[decision]; ok {
+		return name
+	}
+	return fmt.Sprintf("%d", decision)
+}
+
+// Replay feeds a trace recorded with -record through the event processor
+// and prints every event, the decision taken on it and what the virtual
+// devices were asked to do, without creating any. Events are replayed at
+// the pace they were recorded, so long presses and timeouts come out the
+// same, and movement ticks run on the trace's clock. It fails when a
+// decision differs from the recorded one, e.g. after a keymap change.
+func Replay(config Config, path string, out io.Writer) error {
+	trace, err := OpenTrace(path)
+	if err != nil {
+		return err
+	}
+	defer trace.Close()
+
+	// Debug messages and errors go to stderr, apart from the report
+	logger := &Logger{Logger: log.New(os.Stderr, "", 0), quiet: true}
+	logger.debugMode.Store(config.DebugMode)
+
+	keyMappingProvider := keymaps.CreateDefaultKeyMappingProvider()
+	if config.Keymap != "" {
+		if err := LoadKeymap(keyMappingProvider, config.Keymap); err != nil {
+			return err
+		}
+	}
+
+	notices := NewNoticeHub()
+	changes, unsubscribe := notices.SubscribeChanges()
+	defer unsubscribe()
+
+	emitter := NewEmitter(logger)
+	emitter.Notices = notices
+	go emitter.Run()
+
+	output := &replayOutput{}
+	mice := []PointerOutput{emitter.Mouse(replayPointer{out: output})}
+	for _, extra := range config.ExtraPointers {
+		mice = append(mice, emitter.Mouse(replayPointer{out: output, name: extra.Mouse.Name}))
+	}
+	app := assemble(config, logger, keyMappingProvider, emitter, notices, mice, replayKeyboard{out: output})
+	dm := app.DeviceManager
+
+	// Notices are reported as the user would have seen them
+	messages := NewMessages("en", nil)
+
+	r := &replayer{dm: dm, start: time.Now(), nextMove: -1, nextScroll: -1}
+	var devices []*InputDevice
+	var at time.Duration
+	events, differ := 0, 0
+	for {
+		te, err := trace.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		for len(devices) < len(trace.Devices) {
+			td := trace.Devices[len(devices)]
+			devices = append(devices, &InputDevice{
+				Name:         td.Name,
+				KeyboardType: td.KeyboardType,
+				Controller:   dm.routeFor(td.Name, ""),
+				Debounce:     config.debounceFor(td.Name, ""),
+			})
+		}
+
+		at += te.Delta
+		r.tick(at)
+		r.now = at
+		r.sleep()
+		device := devices[te.Device]
+		event := te.Event
+		decision := dm.handleEvent(device, &event)
+		emitter.Flush()
+
+		events++
+		if event.Type != EvSyn || decision != te.Decision {
+			line := fmt.Sprintf("%s: %s => %s", device.Name, eventName(event.Type, event.Code, event.Value), decisionName(decision))
+			if decision != te.Decision {
+				line += ", recorded " + decisionName(te.Decision)
+				differ++
+			}
+			r.print(out, line)
+		}
+		r.report(out, output, changes, messages)
+	}
+
+	r.tick(at + replayTail)
+	emitter.Flush()
+	r.report(out, output, changes, messages)
+
+	fmt.Fprintf(out, "%d events from %d devices, %d decisions differ\n", events, len(devices), differ)
+	if differ > 0 {
+		return errors.New("decisions differ from the recording")
+	}
+	return nil
+}
+
+// replayer keeps the trace's clock and runs the movement and scroll ticks
+// the event loop's timers would
+type replayer struct {
+	dm    *DeviceManager
+	start time.Time
+	// now is the trace time of the last event or tick
+	now time.Duration
+	// When the next ticks are due, -1 while none are
+	nextMove   time.Duration
+	nextScroll time.Duration
+}
+
+// tick runs every tick due up to at
+func (r *replayer) tick(at time.Duration) {
+	for {
+		moving, scrolling, moveRate, scrollRate := r.dm.ticksNeeded()
+		r.nextMove = nextTick(r.nextMove, r.now, moving, moveRate)
+		r.nextScroll = nextTick(r.nextScroll, r.now, scrolling, scrollRate)
+
+		switch {
+		case r.nextMove >= 0 && r.nextMove <= at && (r.nextScroll < 0 || r.nextMove <= r.nextScroll):
+			r.now = r.nextMove
+			r.sleep()
+			for _, mc := range r.dm.Controllers {
+				r.dm.moveController(mc)
+			}
+			r.nextMove += tickInterval(moveRate)
+		case r.nextScroll >= 0 && r.nextScroll <= at:
+			r.now = r.nextScroll
+			r.sleep()
+			for _, mc := range r.dm.Controllers {
+				r.dm.scrollController(mc)
+			}
+			r.nextScroll += tickInterval(scrollRate)
+		default:
+			return
+		}
+	}
+}
+
+// nextTick arms a tick a period after now when ticks start and disarms it
+// when they stop, as setTimer does
+func nextTick(next, now time.Duration, active bool, hz int) time.Duration {
+	switch {
+	case !active:
+		return -1
+	case next < 0:
+		return now + tickInterval(hz)
+	}
+	return next
+}
+
+// sleep waits until the trace's clock is due in real time
+func (r *replayer) sleep() {
+	time.Sleep(time.Until(r.start.Add(r.now)))
+}
+
+// print writes a report line stamped with the trace time
+func (r *replayer) print(out io.Writer, line string) {
+	fmt.Fprintf(out, "%9.3f %s\n", r.now.Seconds(), line)
+}
+
+// report prints the notices published and the output produced since the
+// last report
+func (r *replayer) report(out io.Writer, output *replayOutput, changes <-chan Notice, messages *Messages) {
+	for drained := false; !drained; {
+		select {
+		case n := <-changes:
+			text := messages.Notice(n)
+			if text == "" {
+				text = n.Kind
+			}
+			r.print(out, "  notice: "+strings.ReplaceAll(text, "\n", ", "))
+		default:
+			drained = true
+		}
+	}
+	for _, line := range output.take() {
+		r.print(out, "  "+line)
+	}
+}
+
+// replayOutput collects what the stand-in virtual devices of a replay were
+// asked to do, as lines of text
+type replayOutput struct {
+	mu    sync.Mutex
+	lines []string
+	// Movement and wheel turns not yet in lines, added up so a glide reads
+	// as one line rather than one per tick
+	dx, dy        int32
+	wheel, hwheel int32
+}
+
+// add appends a line after any movement before it
+func (o *replayOutput) add(format string, v ...interface{}) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.flushMotion()
+	o.lines = append(o.lines, fmt.Sprintf(format, v...))
+	return nil
+}
+
+// flushMotion turns the movement added up so far into lines; mu must be held
+func (o *replayOutput) flushMotion() {
+	if o.dx != 0 || o.dy != 0 {
+		o.lines = append(o.lines, fmt.Sprintf("move %d %d", o.dx, o.dy))
+	}
+	if o.wheel != 0 || o.hwheel != 0 {
+		o.lines = append(o.lines, fmt.Sprintf("wheel %d %d", o.hwheel, o.wheel))
+	}
+	o.dx, o.dy, o.wheel, o.hwheel = 0, 0, 0, 0
+}
+
+// take returns the lines collected since the last call
+func (o *replayOutput) take() []string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.flushMotion()
+	lines := o.lines
+	o.lines = nil
+	return lines
+}
+
+// replayPointer is a PointerOutput that reports to a replayOutput; name
+// tells extra pointers apart and is empty for the primary one
+type replayPointer struct {
+	out  *replayOutput
+	name string
+}
+
+func (p replayPointer) Move(x, y int32) error {
+	p.out.mu.Lock()
+	defer p.out.mu.Unlock()
+	if p.name != "" {
+		p.out.flushMotion()
+		p.out.lines = append(p.out.lines, fmt.Sprintf("%s: move %d %d", p.name, x, y))
+		return nil
+	}
+	p.out.dx += x
+	p.out.dy += y
+	return nil
+}
+
+func (p replayPointer) Wheel(horizontal bool, delta int32) error {
+	p.out.mu.Lock()
+	defer p.out.mu.Unlock()
+	if p.name != "" {
+		p.out.flushMotion()
+		p.out.lines = append(p.out.lines, fmt.Sprintf("%s: wheel %t %d", p.name, horizontal, delta))
+		return nil
+	}
+	if horizontal {
+		p.out.hwheel += delta
+	} else {
+		p.out.wheel += delta
+	}
+	return nil
+}
+
+func (p replayPointer) ButtonPress(code uint16) error {
+	return p.out.add("%s%s %d", p.prefix(), keyName(code), KeyPressed)
+}
+
+func (p replayPointer) ButtonRelease(code uint16) error {
+	return p.out.add("%s%s %d", p.prefix(), keyName(code), KeyReleased)
+}
+
+func (p replayPointer) LeftPress() error     { return p.ButtonPress(vdev.BtnLeft) }
+func (p replayPointer) LeftRelease() error   { return p.ButtonRelease(vdev.BtnLeft) }
+func (p replayPointer) RightPress() error    { return p.ButtonPress(vdev.BtnRight) }
+func (p replayPointer) RightRelease() error  { return p.ButtonRelease(vdev.BtnRight) }
+func (p replayPointer) MiddlePress() error   { return p.ButtonPress(vdev.BtnMiddle) }
+func (p replayPointer) MiddleRelease() error { return p.ButtonRelease(vdev.BtnMiddle) }
+func (p replayPointer) Close() error         { return nil }
+
+// prefix names an extra pointer in front of its lines
+func (p replayPointer) prefix() string {
+	if p.name == "" {
+		return ""
+	}
+	return p.name + ": "
+}
+
+// replayKeyboard is a KeyboardOutput that reports to a replayOutput
+type replayKeyboard struct {
+	out *replayOutput
+}
+
+func (k replayKeyboard) KeyDown(key int) error {
+	return k.out.add("key %s %d", keyName(uint16(key)), KeyPressed)
+}
+
+func (k replayKeyboard) KeyUp(key int) error {
+	return k.out.add("key %s %d", keyName(uint16(key)), KeyReleased)
+}
+
+func (k replayKeyboard) KeyPress(key int) error {
+	k.KeyDown(key)
+	return k.KeyUp(key)
+}
+
+func (k replayKeyboard) TypeString(text string) error {
+	return k.out.add("type %q", text)
+}
+
+func (k replayKeyboard) SendFrame(events []vdev.Event) error {
+	var parts []string
+	for _, e := range events {
+		if e.Type != EvSyn && e.Type != EvMsc {
+			parts = append(parts, eventName(e.Type, e.Code, e.Value))
+		}
+	}
+	return k.out.add("forward %s", strings.Join(parts, ", "))
+}
+
+func (k replayKeyboard) Close() error { return nil }
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ScreenConfig describes the display the pointer moves on. Width and Height
+// are the panel's natural (unrotated) size; Rotation is in degrees.
+type ScreenConfig struct {
+	Width      int  `json:"width"`
+	Height     int  `json:"height"`
+	Rotation   int  `json:"rotation"`
+	AutoDetect bool `json:"auto_detect"`
+}
+
+// Logical returns the screen size as the user sees it, after rotation
+func (s ScreenConfig) Logical() (int, int) {
+	if s.Rotation == 90 || s.Rotation == 270 {
+		return s.Height, s.Width
+	}
+	return s.Width, s.Height
+}
+
+// ToPanel converts logical (rotated) coordinates into the panel's natural
+// coordinate space, which is what absolute input devices report in
+func (s ScreenConfig) ToPanel(x, y int32) (int32, int32) {
+	w, h := int32(s.Width), int32(s.Height)
+	switch s.Rotation {
+	case 90:
+		return w - 1 - y, x
+	case 180:
+		return w - 1 - x, h - 1 - y
+	case 270:
+		return y, h - 1 - x
+	default:
+		return x, y
+	}
+}
+
+// DetectScreen fills in the panel size and rotation from the running system.
+// Fields that cannot be detected keep their configured values.
+func DetectScreen(screen ScreenConfig) (ScreenConfig, error) {
+	w, h, err := detectScreenSize()
+	if err != nil {
+		return screen, err
+	}
+	screen.Width, screen.Height = w, h
+
+	if rotation, err := detectRotation(); err == nil {
+		screen.Rotation = rotation
+	}
+	return screen, nil
+}
+
+// keepGivenScreen turns detection off when a config file sets the screen
+// size without asking for auto_detect, so the size given is not overwritten
+func keepGivenScreen(data []byte, config *Config) {
+	var given struct {
+		Screen struct {
+			Width      *int  `json:"width"`
+			Height     *int  `json:"height"`
+			AutoDetect *bool `json:"auto_detect"`
+		} `json:"screen"`
+	}
+	if err := json.Unmarshal(data, &given); err != nil {
+		return
+	}
+	if (given.Screen.Width != nil || given.Screen.Height != nil) && given.Screen.AutoDetect == nil {
+		config.Screen.AutoDetect = false
+	}
+}
+
+var sizePattern = regexp.MustCompile(`(\d+)\s*[x,]\s*(\d+)`)
+
+func parseSize(s string) (int, int, bool) {
+	m := sizePattern.FindStringSubmatch(s)
+	if m == nil {
+		return 0, 0, false
+	}
+	w, _ := strconv.Atoi(m[1])
+	h, _ := strconv.Atoi(m[2])
+	return w, h, w > 0 && h > 0
+}
+
+// detectScreenSize tries Android's wm, then the framebuffer, then DRM connectors
+func detectScreenSize() (int, int, error) {
+	if out, err := exec.Command("wm", "size").Output(); err == nil {
+		physical, override := "", ""
+		for _, line := range strings.Split(string(out), "\n") {
+			if strings.HasPrefix(line, "Physical size:") {
+				physical = line
+			} else if strings.HasPrefix(line, "Override size:") {
+				override = line
+			}
+		}
+		if w, h, ok := parseSize(override); ok {
+			return w, h, nil
+		}
+		if w, h, ok := parseSize(physical); ok {
+			return w, h, nil
+		}
+	}
+
+	if data, err := os.ReadFile("/sys/class/graphics/fb0/virtual_size"); err == nil {
+		if w, h, ok := parseSize(string(data)); ok {
+			return w, h, nil
+		}
+	}
+
+	connectors, _ := filepath.Glob("/sys/class/drm/card*-*")
+	for _, connector := range connectors {
+		status, err := os.ReadFile(filepath.Join(connector, "status"))
+		if err != nil || strings.TrimSpace(string(status)) != "connected" {
+			continue
+		}
+		modes, err := os.ReadFile(filepath.Join(connector, "modes"))
+		if err != nil {
+			continue
+		}
+		// The first listed mode is the preferred one
+		if w, h, ok := parseSize(strings.SplitN(string(modes), "\n", 2)[0]); ok {
+			return w, h, nil
+		}
+	}
+
+	return 0, 0, fmt.Errorf("could not detect screen size")
+}
+
+// detectRotation reads Android's user rotation setting, falling back to the fbcon rotation
+func detectRotation() (int, error) {
+	if out, err := exec.Command("settings", "get", "system", "user_rotation").Output(); err == nil {
+		if quarter, err := strconv.Atoi(strings.TrimSpace(string(out))); err == nil {
+			return (quarter % 4) * 90, nil
+		}
+	}
+
+	data, err := os.ReadFile("/sys/class/graphics/fbcon/rotate")
+	if err != nil {
+		return 0, err
+	}
+	quarter, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0, err
+	}
+	return (quarter % 4) * 90, nil
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"slices"
+
+	"github.com/goFlipMouse/keymaps"
+	evdev "github.com/grafov/evdev"
+)
+
+// Layers whose direction keys ScreenReaderConfig.DPad can turn into arrow keys
+const (
+	LayerMove   = "move"
+	LayerScroll = "scroll"
+)
+
+// dpadKeys are KEY_UP, KEY_DOWN, KEY_LEFT and KEY_RIGHT, which Android
+// reports as DPAD_UP and so on
+var dpadKeys = [4]uint16{103, 108, 105, 106}
+
+// ScreenReaderConfig suits mouse mode to TalkBack and Orca users
+type ScreenReaderConfig struct {
+	Enabled bool `json:"enabled"`
+	// Step is how far in pixels a direction key jumps the pointer, once per
+	// press and repeat, instead of gliding with acceleration (20)
+	Step int `json:"step"`
+	// DPad lists the layers whose direction keys send arrow keys for the
+	// screen reader to move its focus with, instead of moving or scrolling:
+	// "move" and "scroll"
+	DPad []string `json:"dpad"`
+}
+
+// screenReaderKey handles the direction keys in screen reader mode and
+// reports whether event was one of them
+func (ep *EventProcessor) screenReaderKey(event *evdev.InputEvent, km keymaps.KeyMapping, mc *MouseController) (int, bool) {
+	layer := LayerMove
+	dir := slices.Index([]uint16{km.UpKey, km.DownKey, km.LeftKey, km.RightKey}, event.Code)
+	if mc.State.ScrollLayerActive {
+		if i := slices.Index([]uint16{km.ScrollLayer.Up, km.ScrollLayer.Down, km.ScrollLayer.Left, km.ScrollLayer.Right}, event.Code); i >= 0 {
+			layer, dir = LayerScroll, i
+		}
+	}
+	dpad := slices.Contains(ep.Config.ScreenReader.DPad, layer)
+	if dir < 0 || (layer == LayerScroll && !dpad) {
+		return 0, false
+	}
+
+	if event.Value == KeyReleased {
+		return MuteEvent, true
+	}
+	if dpad {
+		kbd := ep.VirtualKeyboard
+		ep.Emitter.Do(func() error {
+			return kbd.KeyPress(int(dpadKeys[dir]))
+		})
+		return MuteEvent, true
+	}
+
+	step := int32(ep.Config.ScreenReader.Step)
+	dx := [4]int32{0, 0, -step, step}[dir]
+	dy := [4]int32{-step, step, 0, 0}[dir]
+	mc.Step(dx, dy)
+	return MuteEvent, true
+}
+
+// Step jumps the pointer and publishes an "edge" notice when it is stopped
+// by the side of the screen, which a user who cannot see it would miss
+func (mc *MouseController) Step(dx, dy int32) {
+	x, y := mc.Position()
+	mc.MoveBy(dx, dy)
+	nx, ny := mc.Position()
+
+	edge := ""
+	switch {
+	case dx < 0 && nx == x:
+		edge = "left"
+	case dx > 0 && nx == x:
+		edge = "right"
+	case dy < 0 && ny == y:
+		edge = "top"
+	case dy > 0 && ny == y:
+		edge = "bottom"
+	}
+	if edge != "" {
+		mc.notify(Notice{Kind: "edge", Message: edge})
+	}
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/goFlipMouse/keymaps"
+	evdev "github.com/grafov/evdev"
+)
+
+// setupKeymapName is the keymap file the setup assistant writes next to the config
+const setupKeymapName = "goFlipMouse.keymap.json"
+
+// Timing of the setup assistant
+const (
+	setupPickTimeout = time.Minute      // to press a key on the keypad to use
+	setupKeyTimeout  = 10 * time.Second // before a binding keeps the built-in key
+	setupTestTimeout = 30 * time.Second // of no keys before the test ends
+	setupStep        = 10               // pixels the test pointer moves per press
+)
+
+// setupAction is a binding the setup assistant asks for
+type setupAction struct {
+	field  string // KeyMapping field, as written to the keymap file
+	prompt string
+	key    func(*keymaps.KeyMapping) *uint16
+}
+
+var setupActions = []setupAction{
+	{"ToggleMouseKey", "turns mouse mode on and off when held", func(k *keymaps.KeyMapping) *uint16 { return &k.ToggleMouseKey }},
+	{"UpKey", "moves the pointer up", func(k *keymaps.KeyMapping) *uint16 { return &k.UpKey }},
+	{"DownKey", "moves the pointer down", func(k *keymaps.KeyMapping) *uint16 { return &k.DownKey }},
+	{"LeftKey", "moves the pointer left", func(k *keymaps.KeyMapping) *uint16 { return &k.LeftKey }},
+	{"RightKey", "moves the pointer right", func(k *keymaps.KeyMapping) *uint16 { return &k.RightKey }},
+	{"EnterKey", "clicks", func(k *keymaps.KeyMapping) *uint16 { return &k.EnterKey }},
+	{"DragKey", "holds the button down to drag", func(k *keymaps.KeyMapping) *uint16 { return &k.DragKey }},
+	{"ScrollUpKey", "scrolls up", func(k *keymaps.KeyMapping) *uint16 { return &k.ScrollUpKey }},
+	{"ScrollDownKey", "scrolls down", func(k *keymaps.KeyMapping) *uint16 { return &k.ScrollDownKey }},
+	{"FasterKey", "speeds the pointer up", func(k *keymaps.KeyMapping) *uint16 { return &k.FasterKey }},
+	{"SlowerKey", "slows the pointer down", func(k *keymaps.KeyMapping) *uint16 { return &k.SlowerKey }},
+	{"ExitKey", "leaves mouse mode", func(k *keymaps.KeyMapping) *uint16 { return &k.ExitKey }},
+}
+
+// keyPress is a key pressed on a device the assistant reads
+type keyPress struct {
+	dev  *evdev.InputDevice
+	code uint16
+}
+
+// askSetup offers the setup assistant when there is no config yet
+func askSetup(configPath string, in io.Reader, out io.Writer) bool {
+	fmt.Fprintf(out, "There is no config at %s. Set up goFlipMouse now? [Y/n] ", configPath)
+	answer, _ := bufio.NewReader(in).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "n", "no":
+		return false
+	}
+	return true
+}
+
+// runSetup guides a first run: it finds the keypad, learns its keys, lets
+// them be tried on a test pointer and writes the config and keymap files
+func runSetup(configPath string, out io.Writer) error {
+	candidates := keyDevices()
+	if len(candidates) == 0 {
+		return fmt.Errorf("no input devices with keys; is this running as root? see -install-udev-rules")
+	}
+	fmt.Fprintln(out, "Input devices with keys:")
+	for _, dev := range candidates {
+		fmt.Fprintf(out, "  %s  %s\n", dev.Fn, dev.Name)
+	}
+
+	presses := readKeys(candidates)
+	fmt.Fprintln(out, "Press a key on the keypad to use as a mouse...")
+	var keypad *evdev.InputDevice
+	select {
+	case p := <-presses:
+		keypad = p.dev
+	case <-time.After(setupPickTimeout):
+	}
+	for _, dev := range candidates {
+		if dev != keypad {
+			dev.File.Close()
+		}
+	}
+	if keypad == nil {
+		return fmt.Errorf("no key pressed")
+	}
+	defer keypad.File.Close()
+	fmt.Fprintf(out, "Using %s (%s)\n\n", keypad.Name, keypad.Fn)
+
+	// Keys pressed from here on only reach the assistant
+	if err := keypad.Grab(); err != nil {
+		return fmt.Errorf("failed to grab %s: %v", keypad.Name, err)
+	}
+	defer keypad.Release()
+
+	km, learned := learnKeys(keypad, presses, out)
+	testKeys(km, presses, out)
+
+	keymapPath := filepath.Join(filepath.Dir(configPath), setupKeymapName)
+	if err := writeSetupFile(keymapPath, learned); err != nil {
+		return err
+	}
+	config := map[string]any{
+		"devices": []string{keypad.Name},
+		"keymap":  keymapPath,
+	}
+	if err := writeSetupFile(configPath, config); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "\nWrote %s and %s. Edit them to fine-tune; see the README.\n\n", configPath, keymapPath)
+	return nil
+}
+
+// keyDevices opens every input device that reports keys
+func keyDevices() []*evdev.InputDevice {
+	paths, _ := filepath.Glob("/dev/input/event*")
+	var devices []*evdev.InputDevice
+	for _, path := range paths {
+		dev, err := evdev.Open(path)
+		if err != nil {
+			continue
+		}
+		hasKeys := false
+		for capType := range dev.Capabilities {
+			hasKeys = hasKeys || capType.Type == EvKey
+		}
+		if !hasKeys {
+			dev.File.Close()
+			continue
+		}
+		devices = append(devices, dev)
+	}
+	return devices
+}
+
+// readKeys reports key presses on devices until their files are closed.
+// Presses nobody waits for are dropped.
+func readKeys(devices []*evdev.InputDevice) <-chan keyPress {
+	presses := make(chan keyPress)
+	for _, dev := range devices {
+		go func() {
+			for {
+				events, err := dev.Read()
+				if err != nil {
+					return
+				}
+				for _, event := range events {
+					if event.Type != EvKey || event.Value != KeyPressed {
+						continue
+					}
+					select {
+					case presses <- keyPress{dev: dev, code: event.Code}:
+					default:
+					}
+				}
+			}
+		}()
+	}
+	return presses
+}
+
+// learnKeys asks for a key per setupActions and returns the keypad's
+// mapping with them bound, and the keys that were pressed by field name
+func learnKeys(keypad *evdev.InputDevice, presses <-chan keyPress, out io.Writer) (keymaps.KeyMapping, map[string]uint16) {
+	km := keymaps.CreateDefaultKeyMappingProvider().GetMapping(keymaps.GetKeyboardType(keypad.Name))
+	learned := map[string]uint16{}
+	fmt.Fprintf(out, "Press the key for each action, or wait %v to keep the one shown.\n", setupKeyTimeout)
+	for _, action := range setupActions {
+		key := action.key(&km)
+		current := "none"
+		if *key != keymaps.Unbound {
+			current = keyName(*key)
+		}
+		fmt.Fprintf(out, "  The key that %s [%s]: ", action.prompt, current)
+		select {
+		case p := <-presses:
+			*key = p.code
+			learned[action.field] = p.code
+			fmt.Fprintln(out, keyName(p.code))
+		case <-time.After(setupKeyTimeout):
+			fmt.Fprintln(out, current)
+		}
+	}
+	return km, learned
+}
+
+// testKeys drives a test pointer with the learned keys until the toggle key
+// is pressed or no key is pressed for a while
+func testKeys(km keymaps.KeyMapping, presses <-chan keyPress, out io.Writer) {
+	mouse, err := NewPointerOutput(BackendRelative, defaultConfig.VirtualMouse, ScreenConfig{}, "")
+	if err != nil {
+		fmt.Fprintf(out, "\nSkipping the test, no test pointer: %v\n", err)
+		return
+	}
+	defer mouse.Close()
+
+	fmt.Fprintf(out, "\nTry the keys: they move, click and scroll now. Press %s when done.\n", keyName(km.ToggleMouseKey))
+	for {
+		var p keyPress
+		select {
+		case p = <-presses:
+		case <-time.After(setupTestTimeout):
+			return
+		}
+		var err error
+		switch p.code {
+		case km.ToggleMouseKey:
+			return
+		case km.UpKey:
+			err = mouse.Move(0, -setupStep)
+		case km.DownKey:
+			err = mouse.Move(0, setupStep)
+		case km.LeftKey:
+			err = mouse.Move(-setupStep, 0)
+		case km.RightKey:
+			err = mouse.Move(setupStep, 0)
+		case km.EnterKey:
+			if err = mouse.LeftPress(); err == nil {
+				err = mouse.LeftRelease()
+			}
+			fmt.Fprintln(out, "  click")
+		case km.ScrollUpKey:
+			err = mouse.Wheel(false, 1)
+			fmt.Fprintln(out, "  scroll up")
+		case km.ScrollDownKey:
+			err = mouse.Wheel(false, -1)
+			fmt.Fprintln(out, "  scroll down")
+		}
+		if err != nil {
+			fmt.Fprintf(out, "  failed: %v\n", err)
+		}
+	}
+}
+
+// writeSetupFile writes v as indented JSON to path, creating its directory
+func writeSetupFile(path string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %v", filepath.Dir(path), err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	return nil
+}
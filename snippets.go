@@ -0,0 +1,23 @@
+package main
+
+// Snippet types a piece of text when its key is long pressed outside mouse mode
+type Snippet struct {
+	// Device limits the snippet to one device name or path; empty matches all
+	Device string `json:"device"`
+	Key    uint16 `json:"key"`
+	Text   string `json:"text"`
+}
+
+// remapRules are the remaps followed by the snippets' rules
+func (c Config) remapRules() []RemapRule {
+	return append(append([]RemapRule{}, c.Remaps...), snippetRules(c.Snippets)...)
+}
+
+// snippetRules turns snippets into long press remap rules
+func snippetRules(snippets []Snippet) []RemapRule {
+	rules := make([]RemapRule, 0, len(snippets))
+	for _, s := range snippets {
+		rules = append(rules, RemapRule{Device: s.Device, From: s.Key, LongPressText: s.Text})
+	}
+	return rules
+}
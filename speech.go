@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+)
+
+// SpeechConfig speaks changes aloud for users who cannot see the screen
+type SpeechConfig struct {
+	Enabled bool `json:"enabled"`
+	// Command speaks the text appended to it, e.g. ["espeak-ng", "-s", "200"].
+	// Android has no shell TTS command; Termux's termux-tts-speak is one way
+	// to reach the system voice. By default espeak is used.
+	Command []string `json:"command"`
+}
+
+// Speaker says messages with a TTS command
+type Speaker struct {
+	command []string
+}
+
+// NewSpeaker checks that the TTS command exists
+func NewSpeaker(config SpeechConfig) (*Speaker, error) {
+	command := config.Command
+	if len(command) == 0 {
+		command = []string{"espeak"}
+	}
+	if _, err := exec.LookPath(command[0]); err != nil {
+		return nil, fmt.Errorf("no TTS command: %v", err)
+	}
+	return &Speaker{command: command}, nil
+}
+
+// Say speaks text and returns once it has been said
+func (s *Speaker) Say(text string) error {
+	args := append(append([]string{}, s.command[1:]...), text)
+	if out, err := exec.Command(s.command[0], args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("%s failed: %v: %s", s.command[0], err, bytes.TrimSpace(out))
+	}
+	return nil
+}
+
+// speakChanges says the notices routed to speech until the subscription
+// ends. Changes made while something is being said are merged into the
+// latest one, so holding the speed key does not queue up a sentence per step.
+func (app *Application) speakChanges(notices <-chan Notice) {
+	message := func(n Notice) string {
+		if !app.routed(n, SinkSpeak) {
+			return ""
+		}
+		return app.Messages.Spoken(n)
+	}
+	for n := range notices {
+		text := latestMessage(notices, message(n), message)
+		if text == "" {
+			continue
+		}
+		if err := app.Speaker.Say(text); err != nil {
+			app.Logger.Printf("Failed to say %q: %v", text, err)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// StatusBar is the state as a waybar custom module with "return-type":
+// "json" reads it. Class is "on" or "off" for styling, or "stopped" when
+// no instance is running.
+type StatusBar struct {
+	Text    string `json:"text"`
+	Alt     string `json:"alt"`
+	Tooltip string `json:"tooltip"`
+	Class   string `json:"class"`
+}
+
+// stoppedStatusBar is printed by ctl -statusbar when nothing answers, so the
+// bar shows that instead of an error
+var stoppedStatusBar = StatusBar{Text: "mouse -", Alt: "stopped", Tooltip: "goFlipMouse is not running", Class: "stopped"}
+
+// StatusBar summarizes the primary pointer's state for a status bar
+func (app *Application) StatusBar() StatusBar {
+	r := app.Report()
+	mode := onOff(r.MouseMode)
+	text := "keys"
+	if r.MouseMode {
+		text = fmt.Sprintf("mouse %g", r.Speed)
+	}
+	tooltip := fmt.Sprintf("Mouse mode %s\nSpeed %g", mode, r.Speed)
+	if r.Profile != "" {
+		tooltip += "\nProfile " + r.Profile
+	}
+	return StatusBar{Text: text, Alt: mode, Tooltip: tooltip, Class: mode}
+}
+
+// JSON encodes the status as a single line
+func (s StatusBar) JSON() string {
+	data, _ := json.Marshal(s)
+	return string(data)
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+)
+
+// statusFile is what writeStatusFile stores
+type statusFile struct {
+	StatusReport
+	ScrollSpeed float64  `json:"scroll_speed"`
+	Devices     []string `json:"devices"`
+	// Seconds since startup
+	Uptime float64 `json:"uptime"`
+}
+
+// writeStatusFiles keeps Config.StatusPath up to date: it is rewritten on
+// every state change and at least every StatusInterval
+func (app *Application) writeStatusFiles(notices <-chan Notice) {
+	ticker := time.NewTicker(app.Config.StatusInterval.Duration)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case n, ok := <-notices:
+			if !ok {
+				return
+			}
+			if n.IsEvent() {
+				continue
+			}
+		case <-ticker.C:
+		}
+		if err := app.writeStatusFile(app.Config.StatusPath); err != nil {
+			app.Logger.Printf("Failed to write status file: %v", err)
+		}
+	}
+}
+
+// writeStatusFile replaces the status file atomically, so readers never see
+// a partial one
+func (app *Application) writeStatusFile(path string) error {
+	status := statusFile{
+		StatusReport: app.Report(),
+		Uptime:       time.Since(app.Started).Seconds(),
+	}
+
+	mc := app.MouseController
+	mc.mu.Lock()
+	status.ScrollSpeed = mc.State.ScrollMaxSpeed
+	mc.mu.Unlock()
+
+	dm := app.DeviceManager
+	dm.mu.Lock()
+	for _, dev := range dm.Devices {
+		status.Devices = append(status.Devices, dev.Name)
+	}
+	dm.mu.Unlock()
+
+	data, err := json.Marshal(status)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
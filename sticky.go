@@ -0,0 +1,88 @@
+package main
+
+import evdev "github.com/grafov/evdev"
+
+// StickyModifiers makes modifier keys one-shot outside mouse mode: tapping
+// a sticky modifier on its own keeps it down for the next key only. Holding
+// it while pressing other keys works as usual, and tapping it again while
+// latched lets it go.
+type StickyModifiers struct {
+	ep    *EventProcessor
+	codes map[uint16]bool
+
+	held      map[uint16]bool
+	latched   map[uint16]bool
+	unlatched map[uint16]bool
+	// used records that another key was pressed while a modifier was held
+	used bool
+}
+
+// NewStickyModifiers creates the middleware for the given modifier codes
+func NewStickyModifiers(ep *EventProcessor, codes []uint16) *StickyModifiers {
+	s := &StickyModifiers{
+		ep:        ep,
+		codes:     map[uint16]bool{},
+		held:      map[uint16]bool{},
+		latched:   map[uint16]bool{},
+		unlatched: map[uint16]bool{},
+	}
+	for _, code := range codes {
+		s.codes[code] = true
+	}
+	return s
+}
+
+// Middleware latches tapped modifiers and releases them after the next key
+func (s *StickyModifiers) Middleware(event *evdev.InputEvent, device *InputDevice) Decision {
+	if event.Type != EvKey || s.ep.inMouseMode(device) {
+		return Continue
+	}
+
+	if s.codes[event.Code] {
+		switch event.Value {
+		case KeyPressed:
+			if s.latched[event.Code] {
+				// Second tap: let this press and release through to drop it
+				delete(s.latched, event.Code)
+				s.unlatched[event.Code] = true
+			}
+			s.held[event.Code] = true
+			s.used = false
+		case KeyReleased:
+			delete(s.held, event.Code)
+			if s.unlatched[event.Code] {
+				delete(s.unlatched, event.Code)
+				return Continue
+			}
+			if !s.used {
+				// A tap: keep the modifier down for the next key
+				s.latched[event.Code] = true
+				return Mute
+			}
+		}
+		return Continue
+	}
+
+	switch event.Value {
+	case KeyPressed:
+		if len(s.held) > 0 {
+			s.used = true
+		}
+	case KeyReleased:
+		if len(s.held) == 0 {
+			s.releaseLatched()
+		}
+	}
+	return Continue
+}
+
+// releaseLatched lets go of every latched modifier
+func (s *StickyModifiers) releaseLatched() {
+	kbd := s.ep.VirtualKeyboard
+	for code := range s.latched {
+		s.ep.Emitter.Do(func() error {
+			return kbd.KeyUp(int(code))
+		})
+		delete(s.latched, code)
+	}
+}
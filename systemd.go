@@ -0,0 +1,74 @@
+package main
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// First file descriptor passed by socket activation, SD_LISTEN_FDS_START
+const listenFdsStart = 3
+
+// sdNotify sends a state such as "READY=1" to systemd. It does nothing when
+// the service is not of Type=notify.
+func sdNotify(state string) error {
+	name := os.Getenv("NOTIFY_SOCKET")
+	if name == "" {
+		return nil
+	}
+	// Abstract sockets are given with a leading @
+	if name[0] == '@' {
+		name = "\x00" + name[1:]
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: name, Net: "unixgram"})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.Write([]byte(state))
+	return err
+}
+
+// sdWatchdogPeriod returns how often to send WATCHDOG=1, half the interval
+// systemd expects, or 0 when the watchdog is off
+func sdWatchdogPeriod() time.Duration {
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+	return time.Duration(usec) * time.Microsecond / 2
+}
+
+// activatedListener returns the first UNIX stream socket passed by systemd
+// socket activation, or nil when there is none
+func activatedListener() net.Listener {
+	if os.Getenv("LISTEN_PID") != strconv.Itoa(os.Getpid()) {
+		return nil
+	}
+	count, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil {
+		return nil
+	}
+
+	for fd := listenFdsStart; fd < listenFdsStart+count; fd++ {
+		syscall.CloseOnExec(fd)
+		file := os.NewFile(uintptr(fd), "listen-fd")
+		listener, err := net.FileListener(file)
+		// The listener has a duplicate of the descriptor
+		file.Close()
+		if err != nil {
+			continue
+		}
+		if _, ok := listener.(*net.UnixListener); ok {
+			return listener
+		}
+		listener.Close()
+	}
+	return nil
+}
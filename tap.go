@@ -0,0 +1,123 @@
+package main
+
+import "github.com/goFlipMouse/vdev"
+
+// Outputs are tapped so debuggers see what was emitted next to what was
+// read. The taps sit behind the emitter, so notices come in the order the
+// events left, and cost nothing while nobody is subscribed.
+
+// TapPointer publishes a pointer's output as "output" notices under name
+func TapPointer(output PointerOutput, name string, notices *NoticeHub) PointerOutput {
+	p := tappedPointer{output: output, name: name, notices: notices}
+	if abs, ok := output.(AbsolutePointerOutput); ok {
+		return tappedAbsPointer{tappedPointer: p, abs: abs}
+	}
+	return p
+}
+
+// tappedPointer describes each call as the evdev events it stands for
+type tappedPointer struct {
+	output  PointerOutput
+	name    string
+	notices *NoticeHub
+}
+
+// publish reports events once they made it out
+func (p tappedPointer) publish(err error, events ...vdev.Event) error {
+	if err != nil || !p.notices.Watched() {
+		return err
+	}
+	for _, e := range events {
+		p.notices.Publish(Notice{Kind: "output", Device: p.name, Type: e.Type, Code: e.Code, Value: e.Value})
+	}
+	return nil
+}
+
+func (p tappedPointer) Move(x, y int32) error {
+	return p.publish(p.output.Move(x, y),
+		vdev.Event{Type: vdev.EvRel, Code: vdev.RelX, Value: x},
+		vdev.Event{Type: vdev.EvRel, Code: vdev.RelY, Value: y})
+}
+
+func (p tappedPointer) Wheel(horizontal bool, delta int32) error {
+	code := uint16(vdev.RelWheel)
+	if horizontal {
+		code = vdev.RelHWheel
+	}
+	return p.publish(p.output.Wheel(horizontal, delta), vdev.Event{Type: vdev.EvRel, Code: code, Value: delta})
+}
+
+func (p tappedPointer) ButtonPress(code uint16) error {
+	return p.publish(p.output.ButtonPress(code), vdev.Event{Type: EvKey, Code: code, Value: KeyPressed})
+}
+
+func (p tappedPointer) ButtonRelease(code uint16) error {
+	return p.publish(p.output.ButtonRelease(code), vdev.Event{Type: EvKey, Code: code, Value: KeyReleased})
+}
+
+func (p tappedPointer) LeftPress() error     { return p.ButtonPress(vdev.BtnLeft) }
+func (p tappedPointer) LeftRelease() error   { return p.ButtonRelease(vdev.BtnLeft) }
+func (p tappedPointer) RightPress() error    { return p.ButtonPress(vdev.BtnRight) }
+func (p tappedPointer) RightRelease() error  { return p.ButtonRelease(vdev.BtnRight) }
+func (p tappedPointer) MiddlePress() error   { return p.ButtonPress(vdev.BtnMiddle) }
+func (p tappedPointer) MiddleRelease() error { return p.ButtonRelease(vdev.BtnMiddle) }
+
+func (p tappedPointer) Close() error {
+	return p.output.Close()
+}
+
+type tappedAbsPointer struct {
+	tappedPointer
+	abs AbsolutePointerOutput
+}
+
+func (p tappedAbsPointer) MoveTo(x, y int32) error {
+	return p.publish(p.abs.MoveTo(x, y),
+		vdev.Event{Type: vdev.EvAbs, Code: vdev.AbsX, Value: x},
+		vdev.Event{Type: vdev.EvAbs, Code: vdev.AbsY, Value: y})
+}
+
+// TapKeyboard publishes a keyboard's output as "output" notices under
+// name. layout must be the one the keyboard types text with.
+func TapKeyboard(output KeyboardOutput, name string, layout vdev.Layout, notices *NoticeHub) KeyboardOutput {
+	return &tappedKeyboard{KeyboardOutput: output, layout: layout, tap: tappedPointer{name: name, notices: notices}}
+}
+
+// tappedKeyboard reports keys; typed text shows up as the keys it took
+type tappedKeyboard struct {
+	KeyboardOutput
+	layout vdev.Layout
+	tap    tappedPointer
+}
+
+func (k *tappedKeyboard) KeyDown(key int) error {
+	return k.tap.publish(k.KeyboardOutput.KeyDown(key), vdev.Event{Type: EvKey, Code: uint16(key), Value: KeyPressed})
+}
+
+func (k *tappedKeyboard) KeyUp(key int) error {
+	return k.tap.publish(k.KeyboardOutput.KeyUp(key), vdev.Event{Type: EvKey, Code: uint16(key), Value: KeyReleased})
+}
+
+func (k *tappedKeyboard) KeyPress(key int) error {
+	if err := k.KeyDown(key); err != nil {
+		return err
+	}
+	return k.KeyUp(key)
+}
+
+func (k *tappedKeyboard) TypeString(text string) error {
+	if !k.tap.notices.Watched() {
+		return k.KeyboardOutput.TypeString(text)
+	}
+	return vdev.TypeText(k, k.layout, text)
+}
+
+func (k *tappedKeyboard) SendFrame(events []vdev.Event) error {
+	var keys []vdev.Event
+	for _, e := range events {
+		if e.Type != EvSyn && e.Type != EvMsc {
+			keys = append(keys, e)
+		}
+	}
+	return k.tap.publish(k.KeyboardOutput.SendFrame(events), keys...)
+}
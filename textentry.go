@@ -0,0 +1,121 @@
+package main
+
+import (
+	"strings"
+	"time"
+
+	"github.com/goFlipMouse/keymaps"
+	evdev "github.com/grafov/evdev"
+)
+
+// keyBackspace erases a letter that is being cycled
+const keyBackspace = 14
+
+// multiTapLetters lists the characters each digit cycles through, like a
+// classic phone keypad
+var multiTapLetters = [10]string{
+	" 0", ".,?!'-1", "abc2", "def3", "ghi4", "jkl5", "mno6", "pqrs7", "tuv8", "wxyz9",
+}
+
+// MultiTap turns the digit keys into multi-tap letter entry while text mode
+// is on. A long press of TextModeKey toggles text mode outside mouse mode;
+// TextCaseKey toggles upper case.
+type MultiTap struct {
+	ep     *EventProcessor
+	active bool
+	upper  bool
+
+	// The digit being cycled and when it was last tapped
+	lastCode uint16
+	lastTap  time.Time
+	index    int
+
+	modeKeyDown time.Time
+}
+
+// NewMultiTap creates the text entry middleware
+func NewMultiTap(ep *EventProcessor) *MultiTap {
+	return &MultiTap{ep: ep}
+}
+
+// Middleware handles text mode keys outside mouse mode
+func (t *MultiTap) Middleware(event *evdev.InputEvent, device *InputDevice) Decision {
+	km := t.ep.KeyMappingProvider.GetMapping(device.KeyboardType)
+	if event.Type != EvKey || event.Code == keymaps.Unbound || t.ep.inMouseMode(device) {
+		return Continue
+	}
+
+	if event.Code == km.TextModeKey {
+		switch event.Value {
+		case KeyPressed:
+			t.modeKeyDown = time.Now()
+		case KeyReleased:
+			if time.Since(t.modeKeyDown) > t.ep.Config.LongPressDuration.Duration {
+				t.toggle()
+			} else {
+				t.tap(event.Code)
+			}
+		}
+		return Mute
+	}
+
+	if !t.active {
+		return Continue
+	}
+
+	if event.Code == km.TextCaseKey {
+		if event.Value == KeyPressed {
+			t.upper = !t.upper
+		}
+		return Mute
+	}
+
+	digit := km.TextDigit(event.Code)
+	if digit < 0 {
+		// Any other key commits the letter being cycled
+		t.lastCode = keymaps.Unbound
+		return Continue
+	}
+	if event.Value != KeyPressed {
+		return Mute
+	}
+
+	letters := []rune(multiTapLetters[digit])
+	cycling := event.Code == t.lastCode && time.Since(t.lastTap) < t.ep.Config.MultiTapTimeout.Duration
+	if cycling {
+		t.index = (t.index + 1) % len(letters)
+		t.tap(keyBackspace)
+	} else {
+		t.index = 0
+	}
+	t.lastCode = event.Code
+	t.lastTap = time.Now()
+
+	letter := string(letters[t.index])
+	if t.upper {
+		letter = strings.ToUpper(letter)
+	}
+	kbd := t.ep.VirtualKeyboard
+	t.ep.Emitter.Do(func() error {
+		return kbd.TypeString(letter)
+	})
+	return Mute
+}
+
+func (t *MultiTap) toggle() {
+	t.active = !t.active
+	t.lastCode = keymaps.Unbound
+	if t.active {
+		t.ep.Logger.Info("Text mode activated\n")
+	} else {
+		t.ep.Logger.Info("Text mode deactivated\n")
+	}
+}
+
+// tap queues a full key press on the virtual keyboard
+func (t *MultiTap) tap(code uint16) {
+	kbd := t.ep.VirtualKeyboard
+	t.ep.Emitter.Do(func() error {
+		return kbd.KeyPress(int(code))
+	})
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	evdev "github.com/grafov/evdev"
+)
+
+// traceMagic starts a trace file and names its format version
+const traceMagic = "GFMTRACE1\n"
+
+// Record kinds in a trace file
+const (
+	traceDevice = 'D'
+	traceEvent  = 'E'
+)
+
+// A trace file is traceMagic followed by records, all numbers varints:
+//
+//	'D' index keyboard-type name-length name   a device seen for the first time
+//	'E' device-index microseconds type code value decision
+//
+// microseconds is the event's kernel time since the previous event and
+// decision is what ProcessEvent returned, e.g. PassThruEvent.
+
+// TraceRecorder writes every input event and the decision taken on it to a
+// compact trace file, to be attached to bug reports
+type TraceRecorder struct {
+	file    *os.File
+	w       *bufio.Writer
+	devices map[*InputDevice]int64
+	last    int64
+	buf     []byte
+}
+
+// CreateTrace starts a trace file at path
+func CreateTrace(path string) (*TraceRecorder, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create trace: %v", err)
+	}
+	r := &TraceRecorder{
+		file:    file,
+		w:       bufio.NewWriter(file),
+		devices: map[*InputDevice]int64{},
+	}
+	r.w.WriteString(traceMagic)
+	return r, nil
+}
+
+// Record appends an event and its decision. Frames are flushed as they end,
+// so a trace of a session that crashed is complete up to the last one.
+func (r *TraceRecorder) Record(device *InputDevice, event *evdev.InputEvent, decision int) error {
+	index, ok := r.devices[device]
+	if !ok {
+		index = int64(len(r.devices))
+		r.devices[device] = index
+		r.put(traceDevice, index, int64(device.KeyboardType), int64(len(device.Name)))
+		r.buf = append(r.buf, device.Name...)
+	}
+
+	micros := int64(event.Time.Sec)*1000000 + int64(event.Time.Usec)
+	delta := micros - r.last
+	if r.last == 0 {
+		delta = 0
+	}
+	r.last = micros
+	r.put(traceEvent, index, delta, int64(event.Type), int64(event.Code), int64(event.Value), int64(decision))
+
+	if _, err := r.w.Write(r.buf); err != nil {
+		return fmt.Errorf("failed to write trace: %v", err)
+	}
+	r.buf = r.buf[:0]
+	if event.Type == EvSyn {
+		return r.w.Flush()
+	}
+	return nil
+}
+
+// put adds a record to buf
+func (r *TraceRecorder) put(kind byte, values ...int64) {
+	r.buf = append(r.buf, kind)
+	for _, v := range values {
+		r.buf = binary.AppendVarint(r.buf, v)
+	}
+}
+
+// Close flushes and closes the trace file
+func (r *TraceRecorder) Close() error {
+	if err := r.w.Flush(); err != nil {
+		r.file.Close()
+		return err
+	}
+	return r.file.Close()
+}
+
+// TraceDevice is a device announced in a trace
+type TraceDevice struct {
+	Name         string
+	KeyboardType int
+}
+
+// TraceEvent is an event read back from a trace
+type TraceEvent struct {
+	// Device indexes TraceReader.Devices
+	Device int
+	// Delta is the time since the previous event
+	Delta    time.Duration
+	Event    evdev.InputEvent
+	Decision int
+}
+
+// TraceReader reads back a trace file written by TraceRecorder
+type TraceReader struct {
+	file *os.File
+	r    *bufio.Reader
+	// Devices holds the devices announced so far, by index
+	Devices []TraceDevice
+}
+
+// OpenTrace opens the trace file at path and checks its format
+func OpenTrace(path string) (*TraceReader, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open trace: %v", err)
+	}
+	t := &TraceReader{file: file, r: bufio.NewReader(file)}
+	magic := make([]byte, len(traceMagic))
+	if _, err := io.ReadFull(t.r, magic); err != nil || string(magic) != traceMagic {
+		file.Close()
+		return nil, fmt.Errorf("%s is not a trace recorded with -record", path)
+	}
+	return t, nil
+}
+
+// Next returns the next event, or io.EOF after the last one. A trace cut
+// short by a crash ends at its last complete record.
+func (t *TraceReader) Next() (TraceEvent, error) {
+	for {
+		kind, err := t.r.ReadByte()
+		if err != nil {
+			return TraceEvent{}, io.EOF
+		}
+		switch kind {
+		case traceDevice:
+			v, err := t.values(3)
+			if err != nil {
+				return TraceEvent{}, err
+			}
+			if v[0] != int64(len(t.Devices)) || v[2] < 0 {
+				return TraceEvent{}, errors.New("corrupt trace: bad device record")
+			}
+			name := make([]byte, v[2])
+			if _, err := io.ReadFull(t.r, name); err != nil {
+				return TraceEvent{}, io.EOF
+			}
+			t.Devices = append(t.Devices, TraceDevice{Name: string(name), KeyboardType: int(v[1])})
+		case traceEvent:
+			v, err := t.values(6)
+			if err != nil {
+				return TraceEvent{}, err
+			}
+			if v[0] < 0 || v[0] >= int64(len(t.Devices)) {
+				return TraceEvent{}, errors.New("corrupt trace: event from an unknown device")
+			}
+			return TraceEvent{
+				Device: int(v[0]),
+				Delta:  time.Duration(v[1]) * time.Microsecond,
+				Event: evdev.InputEvent{
+					Type:  uint16(v[2]),
+					Code:  uint16(v[3]),
+					Value: int32(v[4]),
+				},
+				Decision: int(v[5]),
+			}, nil
+		default:
+			return TraceEvent{}, fmt.Errorf("corrupt trace: unknown record %q", kind)
+		}
+	}
+}
+
+// values reads the n varints of a record; a record cut short ends the trace
+func (t *TraceReader) values(n int) ([]int64, error) {
+	v := make([]int64, n)
+	for i := range v {
+		var err error
+		if v[i], err = binary.ReadVarint(t.r); err != nil {
+			return nil, io.EOF
+		}
+	}
+	return v, nil
+}
+
+// Close closes the trace file
+func (t *TraceReader) Close() error {
+	return t.file.Close()
+}
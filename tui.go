@@ -0,0 +1,198 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	evdev "github.com/grafov/evdev"
+)
+
+// Dashboard layout and refresh rate
+const (
+	tuiInterval = 100 * time.Millisecond
+	tuiEvents   = 15
+)
+
+// ANSI sequences used by the dashboard; adb shell and every terminal
+// emulator understand them
+const (
+	ansiAltScreen  = "\x1b[?1049h\x1b[?25l"
+	ansiMainScreen = "\x1b[?25h\x1b[?1049l"
+	ansiHome       = "\x1b[H"
+	ansiClearLine  = "\x1b[K"
+	ansiClearBelow = "\x1b[J"
+	ansiBold       = "\x1b[1m"
+	ansiReset      = "\x1b[0m"
+)
+
+// TUI redraws a live view of devices, pointer state and recent events on a
+// terminal. The rest of the program's output has to go elsewhere meanwhile.
+type TUI struct {
+	app  *Application
+	term io.Writer
+
+	// mu keeps Close from interleaving with a redraw
+	mu     sync.Mutex
+	closed bool
+	cancel func()
+
+	events []string
+}
+
+// tuiDevice is the part of an InputDevice the dashboard shows, copied on
+// the event loop
+type tuiDevice struct {
+	name, path string
+}
+
+// StartTUI switches term to the dashboard and keeps it up to date until Close
+func StartTUI(app *Application, term io.Writer) *TUI {
+	notices, cancel := app.Notices.Subscribe()
+	t := &TUI{app: app, term: term, cancel: cancel}
+	fmt.Fprint(term, ansiAltScreen)
+	go t.run(notices)
+	return t
+}
+
+func (t *TUI) run(notices <-chan Notice) {
+	ticker := time.NewTicker(tuiInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case n, ok := <-notices:
+			if !ok {
+				return
+			}
+			t.record(n)
+		case <-ticker.C:
+			t.draw()
+		}
+	}
+}
+
+// record keeps the latest notices, newest first
+func (t *TUI) record(n Notice) {
+	var line string
+	switch {
+	case n.IsEvent() && n.Type == EvSyn:
+		return
+	case n.IsEvent():
+		line = fmt.Sprintf("%-6s %-20.20s %s", n.Kind, n.Device, eventName(n.Type, n.Code, n.Value))
+	default:
+		line = fmt.Sprintf("%-6s %s", n.Kind, t.app.Messages.Notice(n))
+	}
+	t.events = append([]string{time.Now().Format("15:04:05.000 ") + line}, t.events...)
+	if len(t.events) > tuiEvents {
+		t.events = t.events[:tuiEvents]
+	}
+}
+
+// eventName describes an event with the kernel's names where known
+func eventName(typ, code uint16, value int32) string {
+	kind, ok := evdev.EV[int(typ)]
+	if !ok {
+		kind = fmt.Sprintf("type %d", typ)
+	}
+	name := fmt.Sprintf("%d", code)
+	if typ == EvKey {
+		name = keyName(code)
+	}
+	return fmt.Sprintf("%s %s %d", kind, name, value)
+}
+
+// keyName is a key's KEY_ or BTN_ name, or its code
+func keyName(code uint16) string {
+	if name, ok := evdev.KEY[int(code)]; ok {
+		return name
+	}
+	return fmt.Sprintf("%d", code)
+}
+
+func (t *TUI) draw() {
+	app := t.app
+	mc := app.MouseController
+
+	// Devices belong to the event loop
+	var devices []tuiDevice
+	app.DeviceManager.OnLoop(func() {
+		for _, dev := range app.DeviceManager.Devices {
+			devices = append(devices, tuiDevice{name: dev.Name, path: dev.Path})
+		}
+	})
+
+	mc.mu.Lock()
+	s := *mc.State
+	profile := mc.Profile
+	mc.mu.Unlock()
+
+	var b strings.Builder
+	line := func(format string, args ...interface{}) {
+		fmt.Fprintf(&b, format, args...)
+		b.WriteString(ansiClearLine + "\n")
+	}
+
+	line("%sgoFlipMouse%s  mouse mode %s  profile %s  up %s", ansiBold, ansiReset,
+		onOff(s.MouseMode), profile, time.Since(app.Started).Round(time.Second))
+	line("")
+	line("position  %6.0f %6.0f   speed %.1f (scroll %.2f)", s.PosX, s.PosY, s.MaxSpeed, s.ScrollMaxSpeed)
+	line("velocity  %6.2f %6.2f   scroll %5.2f %5.2f", s.VelocityX, s.VelocityY, s.ScrollVelocityX, s.ScrollVelocityY)
+	line("keys      %s", setNames(map[string]bool{
+		"up": s.UpKeyActive, "down": s.DownKeyActive, "left": s.LeftKeyActive, "right": s.RightKeyActive,
+		"wheel-up": s.ScrollUpActive, "wheel-down": s.ScrollDownActive,
+		"wheel-left": s.ScrollLeftActive, "wheel-right": s.ScrollRightActive,
+		"precision": s.PrecisionActive, "turbo": s.TurboActive,
+	}))
+	line("buttons   %s", setNames(map[string]bool{
+		"left": s.LeftBtnPressed, "right": s.RightBtnPressed, "middle": s.MiddleBtnPressed,
+		"side": s.SideBtnPressed, "extra": s.ExtraBtnPressed, "drag": s.DragButton != 0,
+	}))
+	line("layers    %s", setNames(map[string]bool{"scroll": s.ScrollLayerActive, "grid": s.GridMode}))
+	line("")
+
+	line("%sdevices%s", ansiBold, ansiReset)
+	for _, d := range devices {
+		line("  %-24.24s %s", d.name, d.path)
+	}
+	line("")
+
+	line("%srecent events%s", ansiBold, ansiReset)
+	for _, e := range t.events {
+		line("  %s", e)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.closed {
+		io.WriteString(t.term, ansiHome+b.String()+ansiClearBelow)
+	}
+}
+
+// setNames lists the names that are set, or "-" for none
+func setNames(set map[string]bool) string {
+	var names []string
+	for name, on := range set {
+		if on {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return "-"
+	}
+	sort.Strings(names)
+	return strings.Join(names, " ")
+}
+
+// Close gives the terminal back
+func (t *TUI) Close() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.closed {
+		t.closed = true
+		t.cancel()
+		fmt.Fprint(t.term, ansiMainScreen)
+	}
+}
@@ -0,0 +1,78 @@
+package vdev
+
+import "fmt"
+
+// Highest codes defined by the kernel for each forwarded event type
+const (
+	KeyMax = 0x2ff
+	MscMax = 0x07
+	SwMax  = 0x10
+	LedMax = 0x0f
+)
+
+// Keyboard is a key event device covering the full kernel key range, so
+// vendor specific keypad codes can be passed through unchanged. It also
+// carries the misc (MSC_SCAN), switch and LED events keypads send alongside
+// their keys, for the codes the forwarded devices report.
+//
+// EV_REP is deliberately left out: the kernel would otherwise generate its
+// own repeats on top of the forwarded ones.
+type Keyboard struct {
+	*Device
+
+	// Layout is used by TypeString
+	Layout Layout
+}
+
+// CreateKeyboard creates a keyboard that can emit every key, and the misc,
+// switch and LED codes in forwarded. Those should be the codes of the
+// devices it passes events on from: advertising SW_LID or a jack switch
+// would have the system take it for a lid or a headset.
+func CreateKeyboard(path string, id Identity, forwarded Capabilities) (*Keyboard, error) {
+	caps := Capabilities{EvKey: codeRange(1, KeyMax)}
+	for evType, max := range map[uint16]uint16{EvMsc: MscMax, EvSw: SwMax, EvLed: LedMax} {
+		for _, code := range forwarded[evType] {
+			if code <= max {
+				caps[evType] = append(caps[evType], code)
+			}
+		}
+	}
+
+	dev, err := Create(path, id, caps, nil)
+	if err != nil {
+		return nil, fmt.Errorf("could not create virtual keyboard: %v", err)
+	}
+	return &Keyboard{Device: dev, Layout: USLayout()}, nil
+}
+
+func codeRange(first, last uint16) []uint16 {
+	codes := make([]uint16, 0, last-first+1)
+	for code := first; code <= last; code++ {
+		codes = append(codes, code)
+	}
+	return codes
+}
+
+// KeyDown presses and holds a key
+func (k *Keyboard) KeyDown(key int) error {
+	if err := k.Emit(EvKey, uint16(key), 1); err != nil {
+		return err
+	}
+	return k.Sync()
+}
+
+// KeyUp releases a key
+func (k *Keyboard) KeyUp(key int) error {
+	if err := k.Emit(EvKey, uint16(key), 0); err != nil {
+		return err
+	}
+	return k.Sync()
+}
+
+// KeyPress presses and immediately releases a key
+func (k *Keyboard) KeyPress(key int) error {
+	if err := k.KeyDown(key); err != nil {
+		return err
+	}
+	return k.KeyUp(key)
+}
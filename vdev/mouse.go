@@ -0,0 +1,190 @@
+package vdev
+
+import "fmt"
+
+// Pointer event codes from input-event-codes.h
+const (
+	BtnLeft   = 0x110
+	BtnRight  = 0x111
+	BtnMiddle = 0x112
+	BtnSide   = 0x113 // back
+	BtnExtra  = 0x114 // forward
+
+	RelX      = 0x00
+	RelY      = 0x01
+	RelHWheel = 0x06
+	RelWheel  = 0x08
+
+	AbsX = 0x00
+	AbsY = 0x01
+)
+
+// pointer holds the button and wheel handling shared by relative and absolute mice
+type pointer struct {
+	*Device
+}
+
+func (p pointer) rel(code uint16, value int32) error {
+	if err := p.Emit(EvRel, code, value); err != nil {
+		return err
+	}
+	return p.Sync()
+}
+
+func (p pointer) button(code uint16, value int32) error {
+	if err := p.Emit(EvKey, code, value); err != nil {
+		return err
+	}
+	return p.Sync()
+}
+
+func (p pointer) click(code uint16) error {
+	if err := p.button(code, 1); err != nil {
+		return err
+	}
+	return p.button(code, 0)
+}
+
+// LeftClick presses and releases the left button
+func (p pointer) LeftClick() error { return p.click(BtnLeft) }
+
+// RightClick presses and releases the right button
+func (p pointer) RightClick() error { return p.click(BtnRight) }
+
+// MiddleClick presses and releases the middle button
+func (p pointer) MiddleClick() error { return p.click(BtnMiddle) }
+
+// LeftPress holds the left button down
+func (p pointer) LeftPress() error { return p.button(BtnLeft, 1) }
+
+// LeftRelease releases the left button
+func (p pointer) LeftRelease() error { return p.button(BtnLeft, 0) }
+
+// RightPress holds the right button down
+func (p pointer) RightPress() error { return p.button(BtnRight, 1) }
+
+// RightRelease releases the right button
+func (p pointer) RightRelease() error { return p.button(BtnRight, 0) }
+
+// MiddlePress holds the middle button down
+func (p pointer) MiddlePress() error { return p.button(BtnMiddle, 1) }
+
+// MiddleRelease releases the middle button
+func (p pointer) MiddleRelease() error { return p.button(BtnMiddle, 0) }
+
+// ButtonPress holds any registered button down
+func (p pointer) ButtonPress(code uint16) error { return p.button(code, 1) }
+
+// ButtonRelease releases any registered button
+func (p pointer) ButtonRelease(code uint16) error { return p.button(code, 0) }
+
+// Wheel scrolls the vertical or horizontal wheel by delta
+func (p pointer) Wheel(horizontal bool, delta int32) error {
+	if horizontal {
+		return p.rel(RelHWheel, delta)
+	}
+	return p.rel(RelWheel, delta)
+}
+
+// Mouse is a relative pointer device. It satisfies uinput.Mouse.
+type Mouse struct {
+	pointer
+}
+
+// CreateMouse creates a relative pointer with left, right, middle, back and
+// forward buttons and both scroll wheels
+func CreateMouse(path string, id Identity) (*Mouse, error) {
+	dev, err := Create(path, id, Capabilities{
+		EvKey: {BtnLeft, BtnRight, BtnMiddle, BtnSide, BtnExtra},
+		EvRel: {RelX, RelY, RelWheel, RelHWheel},
+	}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("could not create virtual mouse: %v", err)
+	}
+	return &Mouse{pointer{dev}}, nil
+}
+
+// MoveLeft moves the cursor left by the given number of pixels
+func (m *Mouse) MoveLeft(pixel int32) error { return m.rel(RelX, -pixel) }
+
+// MoveRight moves the cursor right by the given number of pixels
+func (m *Mouse) MoveRight(pixel int32) error { return m.rel(RelX, pixel) }
+
+// MoveUp moves the cursor up by the given number of pixels
+func (m *Mouse) MoveUp(pixel int32) error { return m.rel(RelY, -pixel) }
+
+// MoveDown moves the cursor down by the given number of pixels
+func (m *Mouse) MoveDown(pixel int32) error { return m.rel(RelY, pixel) }
+
+// Move moves the cursor by x and y in a single frame
+func (m *Mouse) Move(x, y int32) error {
+	if err := m.Emit(EvRel, RelX, x); err != nil {
+		return err
+	}
+	if err := m.Emit(EvRel, RelY, y); err != nil {
+		return err
+	}
+	return m.Sync()
+}
+
+// AbsMouse is a pointer that reports absolute ABS_X/ABS_Y coordinates,
+// so the cursor can be placed at an exact position
+type AbsMouse struct {
+	pointer
+	width, height int32
+	x, y          int32
+}
+
+// CreateAbsMouse creates an absolute pointer whose axes span width x height
+func CreateAbsMouse(path string, id Identity, width, height int32) (*AbsMouse, error) {
+	if width <= 0 || height <= 0 {
+		return nil, fmt.Errorf("invalid absolute range %dx%d", width, height)
+	}
+
+	dev, err := Create(path, id, Capabilities{
+		EvKey: {BtnLeft, BtnRight, BtnMiddle, BtnSide, BtnExtra},
+		EvRel: {RelWheel, RelHWheel},
+		EvAbs: {AbsX, AbsY},
+	}, map[uint16]AbsRange{
+		AbsX: {Min: 0, Max: width - 1},
+		AbsY: {Min: 0, Max: height - 1},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not create virtual absolute mouse: %v", err)
+	}
+	return &AbsMouse{pointer: pointer{dev}, width: width, height: height}, nil
+}
+
+// MoveTo places the cursor at x, y, clamped to the axis range
+func (m *AbsMouse) MoveTo(x, y int32) error {
+	m.x = clamp(x, 0, m.width-1)
+	m.y = clamp(y, 0, m.height-1)
+
+	if err := m.Emit(EvAbs, AbsX, m.x); err != nil {
+		return err
+	}
+	if err := m.Emit(EvAbs, AbsY, m.y); err != nil {
+		return err
+	}
+	return m.Sync()
+}
+
+// Move shifts the cursor relative to its last absolute position
+func (m *AbsMouse) Move(x, y int32) error {
+	return m.MoveTo(m.x+x, m.y+y)
+}
+
+// Position returns the last reported coordinates
+func (m *AbsMouse) Position() (int32, int32) {
+	return m.x, m.y
+}
+
+func clamp(v, min, max int32) int32 {
+	if v < min {
+		return min
+	}
+	if v > max {
+		return max
+	}
+	return v
+}
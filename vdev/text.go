@@ -0,0 +1,99 @@
+package vdev
+
+import "fmt"
+
+// Modifier keys used while typing text
+const (
+	KeyLeftShift = 42
+	KeyRightAlt  = 100 // AltGr on international layouts
+)
+
+// KeyStroke is how a character is typed on a layout
+type KeyStroke struct {
+	Code  uint16 `json:"code"`
+	Shift bool   `json:"shift"`
+	AltGr bool   `json:"altgr"`
+}
+
+// Layout maps characters to the key strokes that type them
+type Layout map[rune]KeyStroke
+
+// USLayout returns the US QWERTY layout for printable ASCII
+func USLayout() Layout {
+	layout := Layout{
+		' ': {Code: 57}, '\n': {Code: 28}, '\t': {Code: 15},
+	}
+	rows := []struct {
+		plain, shifted string
+		first          uint16
+	}{
+		{"1234567890-=", "!@#$%^&*()_+", 2},
+		{"qwertyuiop[]", "QWERTYUIOP{}", 16},
+		{"asdfghjkl;'`", "ASDFGHJKL:\"~", 30},
+		{"\\zxcvbnm,./", "|ZXCVBNM<>?", 43},
+	}
+	for _, row := range rows {
+		for i, r := range row.plain {
+			layout[r] = KeyStroke{Code: row.first + uint16(i)}
+		}
+		for i, r := range row.shifted {
+			layout[r] = KeyStroke{Code: row.first + uint16(i), Shift: true}
+		}
+	}
+	return layout
+}
+
+// KeySender presses and releases keys by Linux key code
+type KeySender interface {
+	KeyDown(key int) error
+	KeyUp(key int) error
+	KeyPress(key int) error
+}
+
+// TypeString types text using the keyboard's Layout. Characters the layout
+// cannot type are skipped and reported in the returned error.
+func (k *Keyboard) TypeString(text string) error {
+	return TypeText(k, k.Layout, text)
+}
+
+// TypeText types text on any KeySender using layout, like TypeString
+func TypeText(keys KeySender, layout Layout, text string) error {
+	var missing []rune
+	for _, r := range text {
+		stroke, ok := layout[r]
+		if !ok {
+			missing = append(missing, r)
+			continue
+		}
+		if err := typeStroke(keys, stroke); err != nil {
+			return fmt.Errorf("failed to type %q: %v", r, err)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("no key for %q on the keyboard layout", string(missing))
+	}
+	return nil
+}
+
+func typeStroke(keys KeySender, stroke KeyStroke) error {
+	var mods []int
+	if stroke.Shift {
+		mods = append(mods, KeyLeftShift)
+	}
+	if stroke.AltGr {
+		mods = append(mods, KeyRightAlt)
+	}
+
+	for _, mod := range mods {
+		if err := keys.KeyDown(mod); err != nil {
+			return err
+		}
+	}
+	err := keys.KeyPress(int(stroke.Code))
+	for _, mod := range mods {
+		if upErr := keys.KeyUp(mod); err == nil {
+			err = upErr
+		}
+	}
+	return err
+}
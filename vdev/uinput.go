@@ -0,0 +1,239 @@
+// Package vdev creates virtual input devices through /dev/uinput.
+//
+// Unlike the uinput library used elsewhere, devices created here can carry a
+// caller supplied identity (name, bus, vendor, product) and an arbitrary set
+// of event capabilities.
+package vdev
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// DefaultPath is the usual location of the uinput device node
+const DefaultPath = "/dev/uinput"
+
+// ioctl requests and limits from uinput.h
+const (
+	uinputMaxNameSize = 80
+	absSize           = 64
+
+	uiDevCreate  = 0x5501
+	uiDevDestroy = 0x5502
+	uiGetSysname = 0x8041552c
+	uiSetEvBit   = 0x40045564
+	uiSetKeyBit  = 0x40045565
+	uiSetRelBit  = 0x40045566
+	uiSetAbsBit  = 0x40045567
+	uiSetMscBit  = 0x40045568
+	uiSetLedBit  = 0x40045569
+	uiSetSndBit  = 0x4004556a
+	uiSetSwBit   = 0x4004556d
+)
+
+// Event types from input-event-codes.h
+const (
+	EvSyn = 0x00
+	EvKey = 0x01
+	EvRel = 0x02
+	EvAbs = 0x03
+	EvMsc = 0x04
+	EvSw  = 0x05
+	EvLed = 0x11
+	EvSnd = 0x12
+	EvRep = 0x14
+
+	SynReport  = 0
+	SynDropped = 3
+
+	BusUSB     = 0x03
+	BusVirtual = 0x06
+)
+
+// Identity describes how a virtual device presents itself to the system
+type Identity struct {
+	Name    string `json:"name"`
+	Bustype uint16 `json:"bustype"`
+	Vendor  uint16 `json:"vendor"`
+	Product uint16 `json:"product"`
+	Version uint16 `json:"version"`
+}
+
+// Capabilities lists the event codes a device may emit, keyed by event type
+type Capabilities map[uint16][]uint16
+
+// AbsRange describes the value range of an absolute axis
+type AbsRange struct {
+	Min int32
+	Max int32
+}
+
+type inputID struct {
+	Bustype uint16
+	Vendor  uint16
+	Product uint16
+	Version uint16
+}
+
+// translated to go from uinput.h
+type uinputUserDev struct {
+	Name       [uinputMaxNameSize]byte
+	ID         inputID
+	EffectsMax uint32
+	Absmax     [absSize]int32
+	Absmin     [absSize]int32
+	Absfuzz    [absSize]int32
+	Absflat    [absSize]int32
+}
+
+// Event is a single input event, laid out like struct input_event from input.h
+type Event struct {
+	Time  syscall.Timeval
+	Type  uint16
+	Code  uint16
+	Value int32
+}
+
+// Device is a raw virtual input device
+type Device struct {
+	Identity Identity
+	file     *os.File
+}
+
+var codeBitRequests = map[uint16]uintptr{
+	EvKey: uiSetKeyBit,
+	EvRel: uiSetRelBit,
+	EvAbs: uiSetAbsBit,
+	EvMsc: uiSetMscBit,
+	EvLed: uiSetLedBit,
+	EvSnd: uiSetSndBit,
+	EvSw:  uiSetSwBit,
+}
+
+// Create registers a new virtual device with the given identity and capabilities.
+// absRanges is only consulted for EV_ABS axes and may be nil otherwise.
+func Create(path string, id Identity, caps Capabilities, absRanges map[uint16]AbsRange) (*Device, error) {
+	if id.Name == "" {
+		return nil, errors.New("device name may not be empty")
+	}
+	if len(id.Name) > uinputMaxNameSize {
+		return nil, fmt.Errorf("device name %s is too long (maximum of %d characters allowed)", id.Name, uinputMaxNameSize)
+	}
+
+	file, err := os.OpenFile(path, syscall.O_WRONLY|syscall.O_NONBLOCK, 0660)
+	if err != nil {
+		return nil, fmt.Errorf("could not open %s: %v", path, err)
+	}
+
+	dev := uinputUserDev{
+		ID: inputID{
+			Bustype: id.Bustype,
+			Vendor:  id.Vendor,
+			Product: id.Product,
+			Version: id.Version,
+		},
+	}
+	copy(dev.Name[:], id.Name)
+
+	for evType, codes := range caps {
+		if err := ioctl(file, uiSetEvBit, uintptr(evType)); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("failed to register event type %d: %v", evType, err)
+		}
+
+		request, ok := codeBitRequests[evType]
+		if !ok {
+			continue
+		}
+		for _, code := range codes {
+			if err := ioctl(file, request, uintptr(code)); err != nil {
+				file.Close()
+				return nil, fmt.Errorf("failed to register event %d/%d: %v", evType, code, err)
+			}
+			if evType == EvAbs && int(code) < absSize {
+				r := absRanges[code]
+				dev.Absmin[code] = r.Min
+				dev.Absmax[code] = r.Max
+			}
+		}
+	}
+
+	buf := new(bytes.Buffer)
+	if err := binary.Write(buf, binary.LittleEndian, dev); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to write user device buffer: %v", err)
+	}
+	if _, err := file.Write(buf.Bytes()); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to write uidev struct to device file: %v", err)
+	}
+
+	if err := ioctl(file, uiDevCreate, 0); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to create device: %v", err)
+	}
+
+	// Give userspace (udev, Android's EventHub) time to pick up the new node
+	time.Sleep(200 * time.Millisecond)
+
+	return &Device{Identity: id, file: file}, nil
+}
+
+// SendEvent writes a single event with an explicit timestamp
+func (d *Device) SendEvent(t syscall.Timeval, typ uint16, code uint16, value int32) error {
+	return d.SendFrame([]Event{{Time: t, Type: typ, Code: code, Value: value}})
+}
+
+// SendFrame writes several events with a single write, so readers never see
+// a partial frame. The caller is expected to end the frame with SYN_REPORT.
+func (d *Device) SendFrame(events []Event) error {
+	buf := bytes.NewBuffer(make([]byte, 0, 24*len(events)))
+	if err := binary.Write(buf, binary.LittleEndian, events); err != nil {
+		return fmt.Errorf("failed to write input events to buffer: %v", err)
+	}
+	if _, err := d.file.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("failed to write events to device file: %v", err)
+	}
+	return nil
+}
+
+// Emit writes a single event; the kernel fills in the timestamp
+func (d *Device) Emit(typ uint16, code uint16, value int32) error {
+	return d.SendEvent(syscall.Timeval{}, typ, code, value)
+}
+
+// Sync terminates the current event frame with SYN_REPORT
+func (d *Device) Sync() error {
+	return d.Emit(EvSyn, SynReport, 0)
+}
+
+// FetchSyspath returns the sysfs path of the device
+func (d *Device) FetchSyspath() (string, error) {
+	// 64 for name + 1 for null byte
+	path := make([]byte, 65)
+	err := ioctl(d.file, uiGetSysname, uintptr(unsafe.Pointer(&path[0])))
+	return "/sys/devices/virtual/input/" + string(bytes.TrimRight(path, "\x00")), err
+}
+
+// Close destroys the virtual device
+func (d *Device) Close() error {
+	if err := ioctl(d.file, uiDevDestroy, 0); err != nil {
+		d.file.Close()
+		return fmt.Errorf("failed to destroy device: %v", err)
+	}
+	return d.file.Close()
+}
+
+func ioctl(file *os.File, cmd, ptr uintptr) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, file.Fd(), cmd, ptr)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"github.com/goFlipMouse/vdev"
+)
+
+// Vibrators exposed by the kernel: the old Android timed_output class, the
+// LED class used by newer kernels, and force feedback on an input device
+const (
+	timedOutputVibrator = "/sys/class/timed_output/vibrator"
+	ledVibrator         = "/sys/class/leds/vibrator"
+)
+
+// Force feedback constants and ioctls from input.h
+const (
+	evFF     = 0x15
+	ffRumble = 0x50
+	ffMax    = 0x7f
+	// EVIOCGBIT(EV_FF, len) and EVIOCSFF, sized for struct ff_effect: a
+	// 16 byte header and a union ending in a pointer
+	eviocgbitFF   = 2<<30 | (ffMax/8+1)<<16 | 'E'<<8 | (0x20 + evFF)
+	ffEffectSize  = 16 + 24 + unsafe.Sizeof(uintptr(0))
+	eviocsff      = 1<<30 | ffEffectSize<<16 | 'E'<<8 | 0x80
+	ffRumbleLevel = 0xc000
+)
+
+// errorBuzzInterval keeps a burst of errors from buzzing continuously
+const errorBuzzInterval = 5 * time.Second
+
+// Length of each buzz and the pause after it when counting, see Count
+const (
+	countBuzz  = 25 * time.Millisecond
+	countPause = 175 * time.Millisecond
+)
+
+// vibratePatterns alternate buzz and pause lengths for the events named
+// by feedbackEvent
+var vibratePatterns = map[string][]time.Duration{
+	"mode_on":    {60 * time.Millisecond},
+	"mode_off":   {30 * time.Millisecond, 80 * time.Millisecond, 30 * time.Millisecond},
+	"drag_start": {20 * time.Millisecond},
+	"drag_stop":  {20 * time.Millisecond},
+	"dragging":   {15 * time.Millisecond},
+	"error":      {400 * time.Millisecond},
+	"edge":       {40 * time.Millisecond},
+}
+
+// VibrateConfig buzzes the vibration motor on mode and drag changes and errors
+type VibrateConfig struct {
+	Enabled bool `json:"enabled"`
+	// Device is a sysfs vibrator directory or an input device with
+	// FF_RUMBLE; by default the sysfs vibrators and then every input
+	// device are tried
+	Device string `json:"device"`
+}
+
+// Vibrator drives one kind of vibration motor
+type Vibrator struct {
+	// buzz starts a vibration of the given length without waiting for it
+	buzz  func(d time.Duration) error
+	close func() error
+}
+
+// OpenVibrator finds the vibrator to use
+func OpenVibrator(config VibrateConfig) (*Vibrator, error) {
+	if config.Device != "" {
+		return openVibrator(config.Device)
+	}
+	for _, path := range []string{timedOutputVibrator, ledVibrator} {
+		if _, err := os.Stat(path); err == nil {
+			return openVibrator(path)
+		}
+	}
+	paths, _ := filepath.Glob("/dev/input/event*")
+	for _, path := range paths {
+		if v, err := openRumble(path); err == nil {
+			return v, nil
+		}
+	}
+	return nil, fmt.Errorf("no vibrator found")
+}
+
+func openVibrator(path string) (*Vibrator, error) {
+	if _, err := os.Stat(filepath.Join(path, "enable")); err == nil {
+		return &Vibrator{
+			buzz: func(d time.Duration) error {
+				return writeSysfs(filepath.Join(path, "enable"), d.Milliseconds())
+			},
+			close: func() error { return nil },
+		}, nil
+	}
+	if _, err := os.Stat(filepath.Join(path, "activate")); err == nil {
+		return &Vibrator{
+			buzz: func(d time.Duration) error {
+				if err := writeSysfs(filepath.Join(path, "duration"), d.Milliseconds()); err != nil {
+					return err
+				}
+				return writeSysfs(filepath.Join(path, "activate"), 1)
+			},
+			close: func() error { return nil },
+		}, nil
+	}
+	return openRumble(path)
+}
+
+func writeSysfs(path string, value int64) error {
+	if err := os.WriteFile(path, []byte(strconv.FormatInt(value, 10)), 0); err != nil {
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	return nil
+}
+
+// openRumble opens an input device that supports FF_RUMBLE. Each buzz
+// uploads the effect again with the new length and plays it once.
+func openRumble(path string) (*Vibrator, error) {
+	file, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var bits [ffMax/8 + 1]byte
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, file.Fd(), eviocgbitFF, uintptr(unsafe.Pointer(&bits[0])))
+	if errno != 0 || bits[ffRumble/8]&(1<<(ffRumble%8)) == 0 {
+		file.Close()
+		return nil, fmt.Errorf("%s has no rumble effect", path)
+	}
+
+	id := int16(-1)
+	return &Vibrator{
+		buzz: func(d time.Duration) error {
+			// struct ff_effect: type, id, direction, trigger, replay, then the
+			// union, whose rumble member holds the strong and weak magnitudes
+			var effect [ffEffectSize]byte
+			binary.NativeEndian.PutUint16(effect[0:], ffRumble)
+			binary.NativeEndian.PutUint16(effect[2:], uint16(id))
+			binary.NativeEndian.PutUint16(effect[10:], uint16(min(d.Milliseconds(), 0xffff)))
+			binary.NativeEndian.PutUint16(effect[16:], ffRumbleLevel)
+			binary.NativeEndian.PutUint16(effect[18:], ffRumbleLevel)
+			_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, file.Fd(), eviocsff, uintptr(unsafe.Pointer(&effect[0])))
+			if errno != 0 {
+				return fmt.Errorf("failed to upload rumble effect to %s: %v", path, errno)
+			}
+			id = int16(binary.NativeEndian.Uint16(effect[2:]))
+
+			play := vdev.Event{Type: evFF, Code: uint16(id), Value: 1}
+			if err := binary.Write(file, binary.NativeEndian, play); err != nil {
+				return fmt.Errorf("failed to play rumble effect on %s: %v", path, err)
+			}
+			return nil
+		},
+		close: file.Close,
+	}, nil
+}
+
+// Vibrate plays the pattern for an event and returns once it is over
+func (v *Vibrator) Vibrate(event string) error {
+	for i, d := range vibratePatterns[event] {
+		if i%2 == 0 {
+			if err := v.buzz(d); err != nil {
+				return err
+			}
+		}
+		time.Sleep(d)
+	}
+	return nil
+}
+
+// Count buzzes n times, up to maxCount, and returns once it is over
+func (v *Vibrator) Count(n int) error {
+	for i := 0; i < min(n, maxCount); i++ {
+		if err := v.buzz(countBuzz); err != nil {
+			return err
+		}
+		time.Sleep(countBuzz + countPause)
+	}
+	return nil
+}
+
+// Close releases the vibrator's device
+func (v *Vibrator) Close() error {
+	return v.close()
+}
+
+// vibrateChanges buzzes for each notice that has a pattern, and with
+// SpeedTicks once per speed step, until the subscription ends
+func (app *Application) vibrateChanges(notices <-chan Notice) {
+	var lastError time.Time
+	for first := range notices {
+		for _, n := range queuedNotices(notices, first) {
+			if !app.routed(n, SinkVibrate) {
+				continue
+			}
+			var err error
+			switch event := feedbackEvent(n); {
+			case event == "speed" && app.Config.SpeedTicks:
+				err = app.Vibrator.Count(int(math.Round(n.Speed)))
+			case event == "error" && time.Since(lastError) < errorBuzzInterval:
+				continue
+			default:
+				if event == "error" {
+					lastError = time.Now()
+				}
+				err = app.Vibrator.Vibrate(event)
+			}
+			if err != nil {
+				app.Logger.Printf("Failed to vibrate: %v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// WarpTo jumps the pointer to one of nine anchor points laid out like a phone
+// keypad: 1 is the top-left corner, 2 the top edge, 5 the centre, 9 the
+// bottom-right corner and so on
+func (mc *MouseController) WarpTo(target int) {
+	if target < 1 || target > 9 {
+		return
+	}
+
+	w, h := mc.Screen.Logical()
+	fx := float64((target-1)%3) / 2
+	fy := float64((target-1)/3) / 2
+	x := int32(fx * float64(w-1))
+	y := int32(fy * float64(h-1))
+
+	if err := mc.MoveTo(x, y); err != nil {
+		mc.Logger.Printf("Warp failed: %v", err)
+		return
+	}
+	mc.Logger.Debug("Warped to %d (%d, %d)\n", target, x, y)
+}
+
+// warpRelative emulates absolute positioning on a relative pointer: an
+// oversized move pins the cursor in the top-left corner, then a second move
+// travels the known distance from there. This is only as exact as the OS
+// pointer acceleration allows.
+func (mc *MouseController) warpRelative(x, y int32) error {
+	w, h := mc.Screen.Logical()
+	if w <= 0 || h <= 0 {
+		return fmt.Errorf("screen geometry unknown, cannot warp a relative pointer")
+	}
+
+	overshoot := int32(math.Max(float64(w), float64(h))) * 2
+	if err := mc.Mouse.Move(-overshoot, -overshoot); err != nil {
+		return err
+	}
+	return mc.Mouse.Move(x, y)
+}
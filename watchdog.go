@@ -0,0 +1,89 @@
+package main
+
+import (
+	"time"
+
+	"github.com/goFlipMouse/vdev"
+)
+
+// clearHeldFlags forgets every key that is held to keep an action going
+func (mc *MouseController) clearHeldFlags() {
+	s := mc.State
+	s.UpKeyActive = false
+	s.DownKeyActive = false
+	s.LeftKeyActive = false
+	s.RightKeyActive = false
+	s.ScrollUpActive = false
+	s.ScrollDownActive = false
+	s.ScrollLeftActive = false
+	s.ScrollRightActive = false
+	s.PrecisionActive = false
+	s.TurboActive = false
+}
+
+// heldButtons lists the buttons currently held by a key rather than by a
+// drag toggle or a pending HoldClick
+func (mc *MouseController) heldButtons() []uint16 {
+	s := mc.State
+	pressed := map[uint16]bool{
+		vdev.BtnLeft:   s.LeftBtnPressed && s.HoldTimer == nil,
+		vdev.BtnRight:  s.RightBtnPressed,
+		vdev.BtnMiddle: s.MiddleBtnPressed,
+		vdev.BtnSide:   s.SideBtnPressed,
+		vdev.BtnExtra:  s.ExtraBtnPressed,
+	}
+
+	var held []uint16
+	for code, down := range pressed {
+		if down && code != s.DragButton {
+			held = append(held, code)
+		}
+	}
+	return held
+}
+
+// hasHeldKeys reports whether any key is currently holding an action
+func (mc *MouseController) hasHeldKeys() bool {
+	s := mc.State
+	return s.UpKeyActive || s.DownKeyActive || s.LeftKeyActive || s.RightKeyActive ||
+		s.ScrollUpActive || s.ScrollDownActive || s.ScrollLeftActive || s.ScrollRightActive ||
+		s.PrecisionActive || s.TurboActive || len(mc.heldButtons()) > 0
+}
+
+// ReleaseHeldKeys clears held actions and releases key-held buttons, as if
+// every key had been let go
+func (mc *MouseController) ReleaseHeldKeys() {
+	mc.clearHeldFlags()
+	for _, code := range mc.heldButtons() {
+		mc.setButton(code, false)
+	}
+}
+
+// watchStuckKeys releases held keys on pointers that have not seen a key
+// press, repeat or release for the configured timeout. Held keys repeat, so
+// silence means a release was lost and the cursor would drift forever.
+func (dm *DeviceManager) watchStuckKeys() {
+	timeout := dm.Config.StuckKeyTimeout.Duration
+	if timeout <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(timeout / 2)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		for _, mc := range dm.Controllers {
+			mc.mu.Lock()
+			stuck := mc.State.MouseMode && mc.hasHeldKeys() && time.Since(mc.State.LastKeyTime) > timeout
+			if stuck {
+				dm.Logger.Printf("No key activity for %v, releasing stuck keys", timeout)
+				mc.ReleaseHeldKeys()
+			}
+			mc.mu.Unlock()
+
+			if stuck {
+				dm.Wake()
+			}
+		}
+	}
+}
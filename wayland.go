@@ -0,0 +1,273 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/goFlipMouse/vdev"
+)
+
+// Object ids this client allocates, in the order the protocol creates them
+const (
+	wlDisplayID = 1 + iota
+	wlRegistryID
+	wlSyncID
+	wlManagerID
+	wlPointerID
+	wlSync2ID
+)
+
+// Request opcodes
+const (
+	wlDisplaySync        = 0
+	wlDisplayGetRegistry = 1
+	wlRegistryBind       = 0
+	wlManagerCreate      = 0
+
+	wlPointerMotion       = 0
+	wlPointerButton       = 2
+	wlPointerFrame        = 4
+	wlPointerAxisSource   = 5
+	wlPointerAxisDiscrete = 7
+	wlPointerDestroy      = 8
+)
+
+const (
+	virtualPointerManager = "zwlr_virtual_pointer_manager_v1"
+	// wheelStep is the axis value of one wheel notch, as libinput reports it
+	wheelStep = 15
+)
+
+// WaylandPointer is a PointerOutput speaking the wlr virtual pointer protocol
+// to the compositor, so no access to /dev/uinput is needed. The wire protocol
+// is simple enough to write directly instead of pulling in a client library.
+type WaylandPointer struct {
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+// ConnectWayland creates a virtual pointer on the compositor of the current
+// session, found through WAYLAND_DISPLAY and XDG_RUNTIME_DIR
+func ConnectWayland() (*WaylandPointer, error) {
+	display := os.Getenv("WAYLAND_DISPLAY")
+	if display == "" {
+		display = "wayland-0"
+	}
+	if !filepath.IsAbs(display) {
+		display = filepath.Join(os.Getenv("XDG_RUNTIME_DIR"), display)
+	}
+	conn, err := net.Dial("unix", display)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to Wayland display %s: %v", display, err)
+	}
+
+	p := &WaylandPointer{conn: conn}
+	if err := p.setup(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	go p.discard()
+	return p, nil
+}
+
+// setup binds the virtual pointer manager and creates the pointer
+func (p *WaylandPointer) setup() error {
+	p.request(wlDisplayID, wlDisplayGetRegistry, wlUint(wlRegistryID))
+	p.request(wlDisplayID, wlDisplaySync, wlUint(wlSyncID))
+
+	name, version := uint32(0), uint32(0)
+	err := p.readUntil(wlSyncID, func(obj uint32, opcode uint16, args []byte) {
+		// wl_registry.global(name, interface, version)
+		if obj != wlRegistryID || opcode != 0 || len(args) < 8 {
+			return
+		}
+		iface, rest := wlReadString(args[4:])
+		if iface == virtualPointerManager && len(rest) >= 4 {
+			name = binary.NativeEndian.Uint32(args)
+			version = min(binary.NativeEndian.Uint32(rest), 2)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	if name == 0 {
+		return fmt.Errorf("the compositor does not offer %s", virtualPointerManager)
+	}
+
+	// wl_registry.bind has an untyped new_id, so the interface is spelled out
+	bind := append(wlUint(name), wlString(virtualPointerManager)...)
+	bind = append(bind, wlUint(version)...)
+	p.request(wlRegistryID, wlRegistryBind, append(bind, wlUint(wlManagerID)...))
+	// A null seat lets the compositor pick its default one
+	p.request(wlManagerID, wlManagerCreate, append(wlUint(0), wlUint(wlPointerID)...))
+
+	// A round trip makes refusals show up now rather than on first use
+	p.request(wlDisplayID, wlDisplaySync, wlUint(wlSync2ID))
+	return p.readUntil(wlSync2ID, nil)
+}
+
+// readUntil handles events until the wl_callback with id fires. Protocol
+// errors sent by the compositor are returned.
+func (p *WaylandPointer) readUntil(callback uint32, handle func(obj uint32, opcode uint16, args []byte)) error {
+	for {
+		obj, opcode, args, err := p.readEvent()
+		if err != nil {
+			return fmt.Errorf("failed to read from the compositor: %v", err)
+		}
+		switch {
+		case obj == wlDisplayID && opcode == 0 && len(args) >= 8:
+			msg, _ := wlReadString(args[8:])
+			return fmt.Errorf("compositor error %d: %s", binary.NativeEndian.Uint32(args[4:]), msg)
+		case obj == callback:
+			return nil
+		case handle != nil:
+			handle(obj, opcode, args)
+		}
+	}
+}
+
+// discard drains events after setup; none of them matter to a pointer
+func (p *WaylandPointer) discard() {
+	for {
+		if _, _, _, err := p.readEvent(); err != nil {
+			return
+		}
+	}
+}
+
+func (p *WaylandPointer) readEvent() (uint32, uint16, []byte, error) {
+	header := make([]byte, 8)
+	if _, err := io.ReadFull(p.conn, header); err != nil {
+		return 0, 0, nil, err
+	}
+	obj := binary.NativeEndian.Uint32(header)
+	word := binary.NativeEndian.Uint32(header[4:])
+	size := int(word >> 16)
+	if size < 8 {
+		return 0, 0, nil, fmt.Errorf("bad message size %d", size)
+	}
+	args := make([]byte, size-8)
+	if _, err := io.ReadFull(p.conn, args); err != nil {
+		return 0, 0, nil, err
+	}
+	return obj, uint16(word), args, nil
+}
+
+// request sends one message
+func (p *WaylandPointer) request(obj uint32, opcode uint16, args []byte) error {
+	msg := make([]byte, 8, 8+len(args))
+	binary.NativeEndian.PutUint32(msg, obj)
+	binary.NativeEndian.PutUint32(msg[4:], uint32(8+len(args))<<16|uint32(opcode))
+	_, err := p.conn.Write(append(msg, args...))
+	return err
+}
+
+// send issues pointer requests followed by a frame, which makes the
+// compositor apply them together
+func (p *WaylandPointer) send(requests ...func() error) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, r := range requests {
+		if err := r(); err != nil {
+			return err
+		}
+	}
+	return p.request(wlPointerID, wlPointerFrame, nil)
+}
+
+func (p *WaylandPointer) pointer(opcode uint16, args ...[]byte) func() error {
+	return func() error {
+		var buf []byte
+		for _, a := range args {
+			buf = append(buf, a...)
+		}
+		return p.request(wlPointerID, opcode, buf)
+	}
+}
+
+func (p *WaylandPointer) Move(x, y int32) error {
+	return p.send(p.pointer(wlPointerMotion, wlTime(), wlFixed(x), wlFixed(y)))
+}
+
+// Wheel scrolls by notches. Positive REL_WHEEL is up, but a positive
+// vertical axis is down, so the vertical direction is flipped.
+func (p *WaylandPointer) Wheel(horizontal bool, delta int32) error {
+	axis := uint32(0)
+	if horizontal {
+		axis = 1
+	} else {
+		delta = -delta
+	}
+	return p.send(
+		p.pointer(wlPointerAxisSource, wlUint(0)),
+		p.pointer(wlPointerAxisDiscrete, wlTime(), wlUint(axis), wlFixed(delta*wheelStep), wlUint(uint32(delta))),
+	)
+}
+
+func (p *WaylandPointer) ButtonPress(code uint16) error {
+	return p.send(p.pointer(wlPointerButton, wlTime(), wlUint(uint32(code)), wlUint(1)))
+}
+
+func (p *WaylandPointer) ButtonRelease(code uint16) error {
+	return p.send(p.pointer(wlPointerButton, wlTime(), wlUint(uint32(code)), wlUint(0)))
+}
+
+func (p *WaylandPointer) LeftPress() error     { return p.ButtonPress(vdev.BtnLeft) }
+func (p *WaylandPointer) LeftRelease() error   { return p.ButtonRelease(vdev.BtnLeft) }
+func (p *WaylandPointer) RightPress() error    { return p.ButtonPress(vdev.BtnRight) }
+func (p *WaylandPointer) RightRelease() error  { return p.ButtonRelease(vdev.BtnRight) }
+func (p *WaylandPointer) MiddlePress() error   { return p.ButtonPress(vdev.BtnMiddle) }
+func (p *WaylandPointer) MiddleRelease() error { return p.ButtonRelease(vdev.BtnMiddle) }
+
+// Close destroys the virtual pointer and disconnects
+func (p *WaylandPointer) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.request(wlPointerID, wlPointerDestroy, nil)
+	return p.conn.Close()
+}
+
+// Argument encoders for the Wayland wire format
+
+func wlUint(v uint32) []byte {
+	return binary.NativeEndian.AppendUint32(nil, v)
+}
+
+// wlFixed encodes an integer as 24.8 fixed point
+func wlFixed(v int32) []byte {
+	return wlUint(uint32(v << 8))
+}
+
+func wlTime() []byte {
+	return wlUint(uint32(time.Now().UnixMilli()))
+}
+
+// wlString encodes a string with its length, a NUL and padding to 4 bytes
+func wlString(s string) []byte {
+	buf := wlUint(uint32(len(s) + 1))
+	buf = append(buf, s...)
+	buf = append(buf, 0)
+	for len(buf)%4 != 0 {
+		buf = append(buf, 0)
+	}
+	return buf
+}
+
+// wlReadString decodes a string and returns it with the rest of args
+func wlReadString(args []byte) (string, []byte) {
+	if len(args) < 4 {
+		return "", nil
+	}
+	n := int(binary.NativeEndian.Uint32(args))
+	padded := (n + 3) &^ 3
+	if n == 0 || 4+padded > len(args) {
+		return "", nil
+	}
+	return string(args[4 : 4+n-1]), args[4+padded:]
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// wsWriteTimeout drops debugger clients that stop reading
+const wsWriteTimeout = 5 * time.Second
+
+// The upgrader keeps the default same-origin check: the stream carries every
+// key pressed, so only the debug page served next to it may read it. The
+// route also goes through local, for pages on a domain rebound to 127.0.0.1.
+var upgrader = websocket.Upgrader{}
+
+// events streams every Notice as a JSON text message until the client goes
+// away. Input notices are events read from devices, output notices what was
+// written to the virtual devices.
+func (s *HTTPServer) events(w http.ResponseWriter, r *http.Request) {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// The upgrader has replied already
+		return
+	}
+	defer conn.Close()
+
+	notices, cancel := s.app.Notices.Subscribe()
+	defer cancel()
+
+	// Reading is only for noticing the close
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case <-closed:
+			return
+		case n, ok := <-notices:
+			if !ok {
+				return
+			}
+			conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+			if err := conn.WriteJSON(n); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// debugPage serves a bare live view of the event stream
+func (s *HTTPServer) debugPage(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(debugHTML))
+}
+
+const debugHTML = `<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>goFlipMouse events</title>
+<style>
+body { font: 13px monospace; margin: 0 }
+table { border-collapse: collapse; width: 100% }
+td { padding: 1px 6px }
+.input { color: #06c } .output { color: #080 } .state { color: #a50 }
+</style></head>
+<body>
+<table><tbody id="log"></tbody></table>
+<script>
+const log = document.getElementById("log");
+const ws = new WebSocket((location.protocol === "https:" ? "wss://" : "ws://") + location.host + "/events");
+ws.onmessage = (m) => {
+	const n = JSON.parse(m.data);
+	const row = log.insertRow(0);
+	const event = n.kind === "input" || n.kind === "output";
+	row.className = event ? n.kind : "state";
+	const cells = [new Date().toISOString().slice(11, 23), n.kind, n.device || ""];
+	if (event) {
+		cells.push("type " + (n.type || 0), "code " + (n.code || 0), "value " + (n.value || 0));
+	} else {
+		cells.push(JSON.stringify(n));
+	}
+	for (const c of cells) row.insertCell().textContent = c;
+	while (log.rows.length > 500) log.deleteRow(-1);
+};
+ws.onclose = () => log.insertRow(0).insertCell().textContent = "disconnected";
+</script>
+</body>
+</html>
+`
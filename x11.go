@@ -0,0 +1,210 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/goFlipMouse/vdev"
+	"github.com/jezek/xgb"
+	"github.com/jezek/xgb/xproto"
+	"github.com/jezek/xgb/xtest"
+)
+
+// X core button numbers; the wheel is buttons 4 to 7
+const (
+	xButtonLeft   = 1
+	xButtonMiddle = 2
+	xButtonRight  = 3
+	xWheelUp      = 4
+	xWheelDown    = 5
+	xWheelLeft    = 6
+	xWheelRight   = 7
+	xButtonSide   = 8
+	xButtonExtra  = 9
+)
+
+// XTestPointer is a PointerOutput that fakes input on an X server with
+// the XTEST extension. It needs no uinput access, which makes it handy for
+// working on keymaps and pointer physics on a workstation.
+type XTestPointer struct {
+	conn *xgb.Conn
+	root xproto.Window
+}
+
+// ConnectXTest connects to the X server named by DISPLAY
+func ConnectXTest() (*XTestPointer, error) {
+	conn, root, err := connectXTest()
+	if err != nil {
+		return nil, err
+	}
+	return &XTestPointer{conn: conn, root: root}, nil
+}
+
+// connectXTest opens a connection with XTEST initialised
+func connectXTest() (*xgb.Conn, xproto.Window, error) {
+	conn, err := xgb.NewConn()
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to connect to the X server: %v", err)
+	}
+	if err := xtest.Init(conn); err != nil {
+		conn.Close()
+		return nil, 0, fmt.Errorf("the X server lacks XTEST: %v", err)
+	}
+	return conn, xproto.Setup(conn).DefaultScreen(conn).Root, nil
+}
+
+// fake sends one fake event and waits for the server to accept it
+func (p *XTestPointer) fake(kind byte, detail byte, x, y int16) error {
+	return xtest.FakeInputChecked(p.conn, kind, detail, 0, p.root, x, y, 0).Check()
+}
+
+// Move moves the cursor relative to its position; detail 1 marks the motion relative
+func (p *XTestPointer) Move(x, y int32) error {
+	return p.fake(xproto.MotionNotify, 1, int16(x), int16(y))
+}
+
+// Wheel clicks the wheel buttons once per notch
+func (p *XTestPointer) Wheel(horizontal bool, delta int32) error {
+	button := byte(xWheelUp)
+	switch {
+	case horizontal && delta > 0:
+		button = xWheelRight
+	case horizontal:
+		button = xWheelLeft
+	case delta < 0:
+		button = xWheelDown
+	}
+	for i := int32(0); i < max(delta, -delta); i++ {
+		if err := p.click(button); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *XTestPointer) click(button byte) error {
+	if err := p.fake(xproto.ButtonPress, button, 0, 0); err != nil {
+		return err
+	}
+	return p.fake(xproto.ButtonRelease, button, 0, 0)
+}
+
+// xButton maps a BTN_* code to an X button
+func xButton(code uint16) (byte, error) {
+	switch code {
+	case vdev.BtnLeft:
+		return xButtonLeft, nil
+	case vdev.BtnRight:
+		return xButtonRight, nil
+	case vdev.BtnMiddle:
+		return xButtonMiddle, nil
+	case vdev.BtnSide:
+		return xButtonSide, nil
+	case vdev.BtnExtra:
+		return xButtonExtra, nil
+	}
+	return 0, fmt.Errorf("no X button for %#x", code)
+}
+
+func (p *XTestPointer) ButtonPress(code uint16) error {
+	button, err := xButton(code)
+	if err != nil {
+		return err
+	}
+	return p.fake(xproto.ButtonPress, button, 0, 0)
+}
+
+func (p *XTestPointer) ButtonRelease(code uint16) error {
+	button, err := xButton(code)
+	if err != nil {
+		return err
+	}
+	return p.fake(xproto.ButtonRelease, button, 0, 0)
+}
+
+func (p *XTestPointer) LeftPress() error     { return p.ButtonPress(vdev.BtnLeft) }
+func (p *XTestPointer) LeftRelease() error   { return p.ButtonRelease(vdev.BtnLeft) }
+func (p *XTestPointer) RightPress() error    { return p.ButtonPress(vdev.BtnRight) }
+func (p *XTestPointer) RightRelease() error  { return p.ButtonRelease(vdev.BtnRight) }
+func (p *XTestPointer) MiddlePress() error   { return p.ButtonPress(vdev.BtnMiddle) }
+func (p *XTestPointer) MiddleRelease() error { return p.ButtonRelease(vdev.BtnMiddle) }
+
+// Close disconnects from the X server
+func (p *XTestPointer) Close() error {
+	p.conn.Close()
+	return nil
+}
+
+// xKeycodeOffset is the distance between Linux key codes and the keycodes
+// of the X evdev and libinput drivers
+const xKeycodeOffset = 8
+
+// XTestKeyboard is a KeyboardOutput faking key events with XTEST. Text is
+// typed with Linux key codes, so the X keymap has to match Layout.
+type XTestKeyboard struct {
+	conn   *xgb.Conn
+	root   xproto.Window
+	Layout vdev.Layout
+}
+
+// ConnectXTestKeyboard connects a keyboard to the X server named by DISPLAY
+func ConnectXTestKeyboard(layout vdev.Layout) (*XTestKeyboard, error) {
+	conn, root, err := connectXTest()
+	if err != nil {
+		return nil, err
+	}
+	return &XTestKeyboard{conn: conn, root: root, Layout: layout}, nil
+}
+
+func (k *XTestKeyboard) fake(kind byte, key int) error {
+	keycode := key + xKeycodeOffset
+	if key <= 0 || keycode > 255 {
+		return fmt.Errorf("no X keycode for key %d", key)
+	}
+	return xtest.FakeInputChecked(k.conn, kind, byte(keycode), 0, k.root, 0, 0, 0).Check()
+}
+
+// KeyDown presses and holds a key
+func (k *XTestKeyboard) KeyDown(key int) error { return k.fake(xproto.KeyPress, key) }
+
+// KeyUp releases a key
+func (k *XTestKeyboard) KeyUp(key int) error { return k.fake(xproto.KeyRelease, key) }
+
+// KeyPress presses and immediately releases a key
+func (k *XTestKeyboard) KeyPress(key int) error {
+	if err := k.KeyDown(key); err != nil {
+		return err
+	}
+	return k.KeyUp(key)
+}
+
+// TypeString types text using the keyboard's Layout
+func (k *XTestKeyboard) TypeString(text string) error {
+	return vdev.TypeText(k, k.Layout, text)
+}
+
+// SendFrame replays the key events of a frame. Repeats are left to the X
+// server's own autorepeat and everything else is dropped.
+func (k *XTestKeyboard) SendFrame(events []vdev.Event) error {
+	for _, e := range events {
+		if e.Type != EvKey {
+			continue
+		}
+		var err error
+		switch e.Value {
+		case KeyPressed:
+			err = k.KeyDown(int(e.Code))
+		case KeyReleased:
+			err = k.KeyUp(int(e.Code))
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close disconnects from the X server
+func (k *XTestKeyboard) Close() error {
+	k.conn.Close()
+	return nil
+}